@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/sero-cash/go-czero-import/c_type"
+	"github.com/sero-cash/go-sero/cmd/utils"
+	"github.com/sero-cash/go-sero/common/address"
+	"github.com/sero-cash/go-sero/core"
+	"github.com/sero-cash/go-sero/zero/txtool"
+	"github.com/sero-cash/go-sero/zero/wallet/exchange"
+	"github.com/sero-cash/go-sero/zero/zconfig"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	exchangeCommand = cli.Command{
+		Name:     "exchange",
+		Usage:    "Maintain the exchange index",
+		Category: "EXCHANGE COMMANDS",
+		Subcommands: []cli.Command{
+			{
+				Name:      "reindex",
+				Usage:     "Rescan the exchange index of accounts",
+				Action:    utils.MigrateFlags(exchangeReindex),
+				ArgsUsage: "[<pk> (<pk 2> ... <pk N>)]",
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.CacheFlag,
+					utils.KeyStoreDirFlag,
+					utils.FromBlockFlag,
+				},
+				Description: `
+    gero exchange reindex --fromBlock <num> [<pk> ...]
+
+Drops what the exchange indexed for the given accounts, or for every account
+when none is given, from the block --fromBlock on and indexes those blocks
+again. The index entries of the other accounts are kept. The node must not be
+running.`,
+			},
+			{
+				Name:      "export",
+				Usage:     "Export the tx history of an account",
+				Action:    utils.MigrateFlags(exchangeExport),
+				ArgsUsage: "<pk> [<filename>]",
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.CacheFlag,
+					utils.KeyStoreDirFlag,
+					utils.FromBlockFlag,
+					utils.ToBlockFlag,
+					utils.ExportFormatFlag,
+				},
+				Description: `
+    gero exchange export --format csv|json [--fromBlock <num>] [--toBlock <num>] <pk> [<filename>]
+
+Writes the txs of the account indexed by the exchange, grouped by tx hash with
+their type, net amount per currency, fee and block time. The output goes to
+stdout when no file is given. The node must not be running.`,
+			},
+		},
+	}
+)
+
+// openExchange opens the chain and the exchange index of a stopped node.
+func openExchange(ctx *cli.Context) (*core.BlockChain, *exchange.Exchange) {
+	stack := makeFullNode(ctx)
+	chain, _ := utils.MakeChain(ctx, stack)
+	txtool.Ref_inst.SetBC(&core.State1BlockChain{Bc: chain})
+
+	ex, err := exchange.OpenExchange(zconfig.Exchange_dir(), stack.AccountManager())
+	if err != nil {
+		utils.Fatalf("Could not open the exchange index: %v", err)
+	}
+	return chain, ex
+}
+
+func exchangeReindex(ctx *cli.Context) error {
+	chain, ex := openExchange(ctx)
+	defer chain.Stop()
+	defer ex.Close()
+
+	var pks []c_type.Uint512
+	for _, arg := range ctx.Args() {
+		pks = append(pks, address.StringToPk(arg).ToUint512())
+	}
+	if len(pks) == 0 {
+		pks = ex.Pks()
+	}
+
+	start := time.Now()
+	count, err := ex.Rescan(pks, ctx.GlobalUint64(utils.FromBlockFlag.Name))
+	if err != nil {
+		utils.Fatalf("Reindex error: %v", err)
+	}
+	fmt.Printf("Reindexed %d blocks of %d accounts in %v\n", count, len(pks), time.Since(start))
+	return nil
+}
+
+func exchangeExport(ctx *cli.Context) error {
+	if len(ctx.Args()) < 1 {
+		utils.Fatalf("This command requires an argument.")
+	}
+	format := ctx.GlobalString(utils.ExportFormatFlag.Name)
+	if format != "csv" && format != "json" {
+		utils.Fatalf("Unknown export format %q", format)
+	}
+	chain, ex := openExchange(ctx)
+	defer chain.Stop()
+	defer ex.Close()
+
+	pk := address.StringToPk(ctx.Args().First()).ToUint512()
+	end := ctx.GlobalUint64(utils.ToBlockFlag.Name)
+	if end == 0 {
+		end = ex.GetCurrencyNumber(pk) + 1
+	}
+	txs, err := ex.GetHistory(pk, ctx.GlobalUint64(utils.FromBlockFlag.Name), end)
+	if err != nil {
+		utils.Fatalf("Export error: %v", err)
+	}
+
+	out := os.Stdout
+	if len(ctx.Args()) > 1 {
+		if out, err = os.Create(ctx.Args().Get(1)); err != nil {
+			utils.Fatalf("Export error: %v", err)
+		}
+		defer out.Close()
+	}
+	if format == "csv" {
+		err = exchange.WriteHistoryCSV(out, txs)
+	} else {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(txs)
+	}
+	if err != nil {
+		utils.Fatalf("Export error: %v", err)
+	}
+	return nil
+}
@@ -116,9 +116,24 @@ var (
 		utils.ExchangeFlag,
 		utils.ExchangeValueStrFlag,
 		utils.StakeFlag,
+		utils.StakeRenewDryRunFlag,
+		utils.StakeIndexFromFlag,
+		utils.StakeRecordProofsFlag,
+		utils.VoteSignerFlag,
+		utils.VoteSignerTokenFlag,
+		utils.VoteAlertLevelFlag,
+		utils.VoteAlertExecFlag,
+		utils.VoteAlertURLFlag,
+		utils.VoteAlertDeadlineFlag,
 		utils.AutoMergeFlag,
+		utils.CoinSelectorFlag,
+		utils.WebhookURLFlag,
+		utils.WebhookSecretFlag,
+		utils.WebhookConfirmationsFlag,
+		utils.PendingTxTimeoutFlag,
 		utils.ConfirmedBlockFlag,
 		utils.RecordBlockShareNumber,
+		utils.RecordStakeEventsFlag,
 		utils.LightNodeFlag,
 		utils.ResetBlockNumber,
 
@@ -193,8 +208,12 @@ func init() {
 		copydbCommand,
 		removedbCommand,
 		//dumpCommand,
+		// See exchangecmd.go:
+		exchangeCommand,
 		// See monitorcmd.go:
 		monitorCommand,
+		// See stakecmd.go:
+		stakeCommand,
 		// See accountcmd.go:
 		accountCommand,
 		// See consolecmd.go:
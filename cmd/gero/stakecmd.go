@@ -0,0 +1,318 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/sero-cash/go-sero/cmd/utils"
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/common/hexutil"
+	"github.com/sero-cash/go-sero/node"
+	"github.com/sero-cash/go-sero/zero/stake/sim"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	stakeCommandAttachFlag = cli.StringFlag{
+		Name:  "attach",
+		Value: node.DefaultIPCEndpoint(clientIdentifier),
+		Usage: "API endpoint to attach to",
+	}
+	stakeCommandWindowFlag = cli.Uint64Flag{
+		Name:  "window",
+		Value: 256,
+		Usage: "Number of recent blocks to check the votes of",
+	}
+	stakeSimulateTicketsFlag = cli.Uint64Flag{
+		Name:  "tickets",
+		Value: 100,
+		Usage: "Number of shares bought",
+	}
+	stakeSimulatePoolFlag = cli.StringFlag{
+		Name:  "pool",
+		Usage: "Stake pool voting the shares, its fee is used",
+	}
+	stakeSimulateFeeFlag = cli.Float64Flag{
+		Name:  "fee",
+		Usage: "Fee in percent of a pool voting the shares",
+	}
+	stakeSimulateMissRateFlag = cli.Float64Flag{
+		Name:  "missrate",
+		Usage: "Chance in percent that a selected share misses its vote",
+	}
+	stakeSimulateRoundsFlag = cli.IntFlag{
+		Name:  "rounds",
+		Value: sim.DefaultRounds,
+		Usage: "Number of rounds to simulate",
+	}
+	stakeSimulateBlocksFlag = cli.Uint64Flag{
+		Name:  "blocks",
+		Usage: "Blocks the shares wait to be selected (default: out of date window)",
+	}
+	stakeSimulateSeedFlag = cli.StringFlag{
+		Name:  "seed",
+		Usage: "Seed of the rounds (default: head block hash)",
+	}
+	stakeSimulatePoolSizeFlag = cli.Uint64Flag{
+		Name:  "poolsize",
+		Usage: "Simulate offline against a synthetic pool of this many tickets",
+	}
+	stakeSimulateStartFlag = cli.Uint64Flag{
+		Name:  "start",
+		Usage: "Block number the shares are bought at in a synthetic pool",
+	}
+	stakeCommand = cli.Command{
+		Name:     "stake",
+		Usage:    "Inspect the stake pools of a running node",
+		Category: "STAKE COMMANDS",
+		Subcommands: []cli.Command{
+			{
+				Name:      "status",
+				Usage:     "Show the operator status of a stake pool",
+				Action:    utils.MigrateFlags(stakeStatus),
+				ArgsUsage: "<poolId>",
+				Flags: []cli.Flag{
+					stakeCommandAttachFlag,
+					stakeCommandWindowFlag,
+				},
+				Description: `
+    gero stake status [--attach <endpoint>] [--window <blocks>] <poolId>
+
+Prints whether the node can sign the votes of the pool, the votes the pool
+cast against the votes expected in the last blocks, the income waiting for
+the next payment and whether the pool is closed or still locked.`,
+			},
+			{
+				Name:   "simulate",
+				Usage:  "Estimate the return of a share purchase",
+				Action: utils.MigrateFlags(stakeSimulate),
+				Flags: []cli.Flag{
+					stakeCommandAttachFlag,
+					stakeSimulateTicketsFlag,
+					stakeSimulatePoolFlag,
+					stakeSimulateFeeFlag,
+					stakeSimulateMissRateFlag,
+					stakeSimulateRoundsFlag,
+					stakeSimulateBlocksFlag,
+					stakeSimulateSeedFlag,
+					stakeSimulatePoolSizeFlag,
+					stakeSimulateStartFlag,
+				},
+				Description: `
+    gero stake simulate [--tickets <n>] [--pool <poolId> | --fee <percent>] [--missrate <percent>]
+                        [--rounds <n>] [--blocks <n>] [--seed <hex>] [--attach <endpoint> | --poolsize <n>]
+
+Runs rounds of the share selection and the rewards against the share pool of
+the node, or a synthetic pool of --poolsize tickets without a node, and prints
+the expected return, its variance and the chance of missing the rewards of a
+purchase. The rounds are deterministic for a seed.`,
+			},
+		},
+	}
+)
+
+type voteKeyStatus struct {
+	VoteKeyLocal     bool  `json:"voteKeyLocal"`
+	VoteKeyUnlocked  bool  `json:"voteKeyUnlocked"`
+	VoteKeyAvailable *bool `json:"voteKeyAvailable"`
+}
+
+type poolOperatorStatus struct {
+	Id            common.Hash     `json:"id"`
+	BlockNumber   hexutil.Uint64  `json:"blockNumber"`
+	Fee           hexutil.Uint    `json:"fee"`
+	ShareNum      hexutil.Uint64  `json:"shareNum"`
+	WishVoteNum   hexutil.Uint64  `json:"wishVoteNum"`
+	Closed        bool            `json:"closed"`
+	LockedUntil   hexutil.Uint64  `json:"lockedUntil"`
+	CanClose      bool            `json:"canClose"`
+	Amount        *hexutil.Big    `json:"amount"`
+	VoteAddress   string          `json:"voteAddress"`
+	Window        hexutil.Uint64  `json:"window"`
+	ExpectedVotes hexutil.Uint64  `json:"expectedVotes"`
+	PoolVotes     hexutil.Uint64  `json:"poolVotes"`
+	SoloVotes     hexutil.Uint64  `json:"soloVotes"`
+	MissedVotes   hexutil.Uint64  `json:"missedVotes"`
+	SignedVotes   hexutil.Uint64  `json:"signedVotes"`
+	PendingIncome *hexutil.Big    `json:"pendingIncome"`
+	NextPayBlock  *hexutil.Uint64 `json:"nextPayBlock"`
+	LastPayTime   hexutil.Uint64  `json:"lastPayTime"`
+}
+
+func seroAmount(value *hexutil.Big) string {
+	if value == nil {
+		return "0"
+	}
+	amount := new(big.Float).Quo(new(big.Float).SetInt(value.ToInt()), big.NewFloat(1e18))
+	return amount.Text('f', 6) + " SERO"
+}
+
+func stakeStatus(ctx *cli.Context) error {
+	if len(ctx.Args()) < 1 {
+		utils.Fatalf("This command requires an argument.")
+	}
+	client, err := dialRPC(ctx.String(stakeCommandAttachFlag.Name))
+	if err != nil {
+		utils.Fatalf("Unable to attach to gero node: %v", err)
+	}
+	defer client.Close()
+
+	var status poolOperatorStatus
+	poolId := common.HexToHash(ctx.Args().First())
+	window := hexutil.Uint64(ctx.Uint64(stakeCommandWindowFlag.Name))
+	if err := client.Call(&status, "stake_poolOperatorStatus", poolId, window); err != nil {
+		utils.Fatalf("Unable to get the pool status: %v", err)
+	}
+
+	fmt.Printf("Pool:            %v\n", status.Id.Hex())
+	fmt.Printf("Block:           %d\n", status.BlockNumber)
+	fmt.Printf("Fee:             %.2f%%\n", float64(status.Fee)/100)
+	fmt.Printf("Shares:          %d waiting, %d selected\n", status.ShareNum, status.WishVoteNum)
+	fmt.Printf("Vote address:    %v\n", status.VoteAddress)
+	// the key state is only served on the private API, an http endpoint
+	// does not have it
+	var key voteKeyStatus
+	if err := client.Call(&key, "stake_voteKeyStatus", poolId); err != nil {
+		fmt.Printf("Vote key:        unknown (%v)\n", err)
+	} else {
+		fmt.Printf("Vote key:        local=%v unlocked=%v", key.VoteKeyLocal, key.VoteKeyUnlocked)
+		if key.VoteKeyAvailable != nil {
+			fmt.Printf(" signer=%v", *key.VoteKeyAvailable)
+		}
+		fmt.Println()
+	}
+	fmt.Printf("Votes (%d blocks): %d expected, %d by the pool, %d solo, %d missed, %d signed here\n",
+		status.Window, status.ExpectedVotes, status.PoolVotes, status.SoloVotes, status.MissedVotes, status.SignedVotes)
+	fmt.Printf("Pending income:  %v", seroAmount(status.PendingIncome))
+	if status.NextPayBlock != nil {
+		fmt.Printf(", paid at block %d", *status.NextPayBlock)
+	}
+	fmt.Println()
+	fmt.Printf("Last payment:    %d\n", status.LastPayTime)
+	switch {
+	case status.Closed:
+		fmt.Printf("State:           closed, %v still held\n", seroAmount(status.Amount))
+	case status.CanClose:
+		fmt.Printf("State:           open, can be closed\n")
+	default:
+		fmt.Printf("State:           open, locked until block %d\n", status.LockedUntil)
+	}
+	return nil
+}
+
+type simulateResult struct {
+	BlockNumber     hexutil.Uint64 `json:"blockNumber"`
+	Rounds          hexutil.Uint64 `json:"rounds"`
+	PoolSize        hexutil.Uint64 `json:"poolSize"`
+	Tickets         hexutil.Uint64 `json:"tickets"`
+	Fee             hexutil.Uint   `json:"fee"`
+	IsPool          bool           `json:"isPool"`
+	Cost            *hexutil.Big   `json:"cost"`
+	ExpectedReward  *hexutil.Big   `json:"expectedReward"`
+	MinReward       *hexutil.Big   `json:"minReward"`
+	MaxReward       *hexutil.Big   `json:"maxReward"`
+	Return          float64        `json:"return"`
+	Variance        float64        `json:"variance"`
+	MissProbability float64        `json:"missProbability"`
+	ExpireRate      float64        `json:"expireRate"`
+}
+
+func stakeSimulate(ctx *cli.Context) error {
+	args := map[string]interface{}{
+		"tickets":  hexutil.Uint64(ctx.Uint64(stakeSimulateTicketsFlag.Name)),
+		"missRate": ctx.Float64(stakeSimulateMissRateFlag.Name) / 100,
+		"rounds":   hexutil.Uint64(ctx.Int(stakeSimulateRoundsFlag.Name)),
+	}
+	if ctx.IsSet(stakeSimulatePoolFlag.Name) {
+		args["pool"] = common.HexToHash(ctx.String(stakeSimulatePoolFlag.Name))
+	}
+	if ctx.IsSet(stakeSimulateFeeFlag.Name) {
+		if fee := ctx.Float64(stakeSimulateFeeFlag.Name); fee < 0 || fee > 100 {
+			utils.Fatalf("The pool fee must be between 0 and 100 percent")
+		}
+		args["fee"] = hexutil.Uint(ctx.Float64(stakeSimulateFeeFlag.Name) * 100)
+	}
+	if ctx.IsSet(stakeSimulateBlocksFlag.Name) {
+		args["blocks"] = hexutil.Uint64(ctx.Uint64(stakeSimulateBlocksFlag.Name))
+	}
+	if ctx.IsSet(stakeSimulateSeedFlag.Name) {
+		args["seed"] = common.HexToHash(ctx.String(stakeSimulateSeedFlag.Name))
+	}
+
+	var result simulateResult
+	if ctx.IsSet(stakeSimulatePoolSizeFlag.Name) {
+		result = simulateOffline(ctx, args)
+	} else {
+		client, err := dialRPC(ctx.String(stakeCommandAttachFlag.Name))
+		if err != nil {
+			utils.Fatalf("Unable to attach to gero node: %v", err)
+		}
+		defer client.Close()
+		if err := client.Call(&result, "stake_simulate", args); err != nil {
+			utils.Fatalf("Unable to simulate: %v", err)
+		}
+	}
+
+	fmt.Printf("Pool size:       %d tickets at block %d\n", result.PoolSize, result.BlockNumber)
+	fmt.Printf("Purchase:        %d shares for %v", result.Tickets, seroAmount(result.Cost))
+	if result.IsPool {
+		fmt.Printf(", pool fee %.2f%%", float64(result.Fee)/100)
+	}
+	fmt.Println()
+	fmt.Printf("Rounds:          %d\n", result.Rounds)
+	fmt.Printf("Expected reward: %v (%v to %v)\n", seroAmount(result.ExpectedReward), seroAmount(result.MinReward), seroAmount(result.MaxReward))
+	fmt.Printf("Expected return: %.4f%% (variance %.6g)\n", result.Return*100, result.Variance)
+	fmt.Printf("Missed votes:    %.4f%% of the shares\n", result.MissProbability*100)
+	fmt.Printf("Expired:         %.4f%% of the shares\n", result.ExpireRate*100)
+	return nil
+}
+
+// simulateOffline runs the simulation against a synthetic pool, like
+// stake_simulate with a poolSize.
+func simulateOffline(ctx *cli.Context, args map[string]interface{}) (result simulateResult) {
+	if ctx.IsSet(stakeSimulatePoolFlag.Name) {
+		utils.Fatalf("A synthetic pool needs --fee instead of --pool")
+	}
+	params := sim.Params{
+		Tickets:  uint32(ctx.Uint64(stakeSimulateTicketsFlag.Name)),
+		MissRate: args["missRate"].(float64),
+		Start:    ctx.Uint64(stakeSimulateStartFlag.Name),
+		Blocks:   ctx.Uint64(stakeSimulateBlocksFlag.Name),
+		Rounds:   ctx.Int(stakeSimulateRoundsFlag.Name),
+	}
+	if fee, ok := args["fee"]; ok {
+		params.Pool, params.Fee = true, uint16(fee.(hexutil.Uint))
+	}
+	if seed, ok := args["seed"]; ok {
+		params.Seed = seed.(common.Hash)
+	}
+	poolSize := ctx.Uint64(stakeSimulatePoolSizeFlag.Name)
+	if poolSize > sim.MaxSyntheticSize {
+		utils.Fatalf("A synthetic pool holds at most %d tickets", sim.MaxSyntheticSize)
+	}
+	base, err := sim.Synthetic(uint32(poolSize), sim.SyntheticShareSize)
+	if err != nil {
+		utils.Fatalf("Unable to simulate: %v", err)
+	}
+	r, err := sim.Simulate(context.Background(), base, params)
+	if err != nil {
+		utils.Fatalf("Unable to simulate: %v", err)
+	}
+	return simulateResult{
+		BlockNumber:     hexutil.Uint64(params.Start),
+		Rounds:          hexutil.Uint64(r.Rounds),
+		PoolSize:        hexutil.Uint64(r.PoolSize),
+		Tickets:         hexutil.Uint64(r.Tickets),
+		Fee:             hexutil.Uint(params.Fee),
+		IsPool:          params.Pool,
+		Cost:            (*hexutil.Big)(r.Cost),
+		ExpectedReward:  (*hexutil.Big)(r.ExpectedReward),
+		MinReward:       (*hexutil.Big)(r.MinReward),
+		MaxReward:       (*hexutil.Big)(r.MaxReward),
+		Return:          r.Return,
+		Variance:        r.Variance,
+		MissProbability: r.MissProbability,
+		ExpireRate:      r.ExpireRate,
+	}
+}
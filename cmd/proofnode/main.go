@@ -1,14 +1,18 @@
 package main
 
 import (
+	"crypto/ecdsa"
+	"encoding/hex"
 	"flag"
 	"fmt"
 	"github.com/btcsuite/btcutil/base58"
 	"github.com/sero-cash/go-czero-import/c_type"
+	"github.com/sero-cash/go-sero/crypto"
 	"github.com/sero-cash/go-sero/internal/ethapi"
 	"github.com/sero-cash/go-sero/rpc"
 	"github.com/sero-cash/go-sero/zero/proofservice"
 	"github.com/sero-cash/go-sero/zero/utils"
+	"io/ioutil"
 	"log"
 	"os"
 	"runtime"
@@ -19,18 +23,64 @@ import (
 func main() {
 	runtime.GOMAXPROCS(runtime.NumCPU())
 	endpoint := flag.String("addr", ":9765", "listen address")
+	workerIPC := flag.String("workerIPC", "", "ipc path the worker processes prove the jobs over, in process when empty")
+	worker := flag.String("worker", "", "run as a worker of the proof node at the ipc path")
+	adminIPC := flag.String("adminIPC", "", "ipc path serving the proofadmin api listing the jobs and the workers")
 	rpc, config, tomeOut := initConfig()
-	if err := startNode(*endpoint, rpc, config, tomeOut); err != nil {
+	if *worker != "" {
+		if err := runWorker(*worker); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	config.Workers = *workerIPC != ""
+	if err := startNode(*endpoint, *workerIPC, *adminIPC, rpc, config, tomeOut); err != nil {
 		os.Exit(0)
 	}
 	select {}
 }
 
-func startNode(endpoint, rpcAddr string, config *proofservice.Config, timeout rpc.HTTPTimeouts) (error) {
+func runWorker(ipcPath string) error {
+	client, err := rpc.Dial(ipcPath)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	name, _ := os.Hostname()
+	return proofservice.NewWorker(client, fmt.Sprintf("%s/%d", name, os.Getpid()), nil).Run(make(chan struct{}))
+}
+
+func startNode(endpoint, workerIPC, adminIPC, rpcAddr string, config *proofservice.Config, timeout rpc.HTTPTimeouts) (error) {
 	if endpoint == "" {
 		return nil
 	}
-	proofservice.NewProofService(rpcAddr, nil, config)
+	service := proofservice.NewProofService(rpcAddr, nil, config)
+
+	if workerIPC != "" {
+		workerApis := []rpc.API{
+			{
+				Namespace: "proofworker",
+				Version:   "1.0",
+				Service:   proofservice.NewWorkerAPI(service),
+			}}
+		if _, _, err := rpc.StartIPCEndpoint(workerIPC, workerApis); err != nil {
+			return err
+		}
+		log.Printf("IPC endpoint opened for the workers, path %s", workerIPC)
+	}
+
+	if adminIPC != "" {
+		adminApis := []rpc.API{
+			{
+				Namespace: "proofadmin",
+				Version:   "1.0",
+				Service:   ethapi.NewProofAdminApi(),
+			}}
+		if _, _, err := rpc.StartIPCEndpoint(adminIPC, adminApis); err != nil {
+			return err
+		}
+		log.Printf("IPC endpoint opened for the operator, path %s", adminIPC)
+	}
 
 	apis := []rpc.API{
 		{
@@ -66,11 +116,22 @@ func initConfig() (string, *proofservice.Config, rpc.HTTPTimeouts) {
 
 		pkrString = flag.String("pkr", "0sero", "outFee")
 
+		dataDir = flag.String("datadir", "", "directory keeping the jobs over restarts, in memory when empty")
+		jobKey  = flag.String("jobKey", "", "file of the hex AES key sealing the unfinished jobs in datadir, they are not resumed without it")
+		jobTTL  = flag.Duration("jobTTL", 2*time.Hour, "how long the finished jobs are kept")
+
+		leaseTimeout = flag.Duration("leaseTimeout", 30*time.Second, "how long a worker keeps a job without a heartbeat")
+
+		quoteKey = flag.String("quoteKey", "", "file of the key signing the fee quotes, a new key at every start when empty")
+		quoteTTL = flag.Duration("quoteTTL", 10*time.Minute, "how long a fee quote holds")
+		credit   = flag.Bool("credit", false, "let the clients pay the jobs from a prepaid credit")
+
 		// endpoint = flag.String("redis", "127.0.0.1:6379", "redis endpoint")
 		// password = flag.String("password", "", "redis password")
 		// database = flag.Int64("database", 0, "redis database")
 		// poolSize = flag.Int("poolSize", 10, "redis poolSize")
 	)
+	flag.Parse()
 
 	if strings.TrimSpace(*pkrString) == "" {
 		panic("pkr is empty")
@@ -93,8 +154,41 @@ func initConfig() (string, *proofservice.Config, rpc.HTTPTimeouts) {
 		panic(err);
 	}
 
+	var key *ecdsa.PrivateKey
+	if *quoteKey != "" {
+		if key, err = crypto.LoadECDSA(*quoteKey); err != nil {
+			panic(err)
+		}
+	}
+
 	pkr := c_type.NewPKrByBytes(base58.Decode(*pkrString))
 	timeout := rpc.HTTPTimeouts{*readTimeout, *writeTimeout, *idleTimeout}
-	fee := proofservice.ServiceFee{zinFeeAmount, oinFeeAmount, outFeeAmount, fixedFeeAmount}
-	return *rpcAddr, &proofservice.Config{pkr, *maxWorkNumber, *maxQueueNumber, fee}, timeout
+	fee := proofservice.ServiceFee{
+		ZinFee:   zinFeeAmount,
+		OinFee:   oinFeeAmount,
+		OutFee:   outFeeAmount,
+		FixedFee: fixedFeeAmount,
+	}
+	config := &proofservice.Config{
+		PKr:            pkr,
+		MaxWorkNumber:  *maxWorkNumber,
+		MaxQueueNumber: *maxQueueNumber,
+		Fee:            fee,
+		DataDir:        *dataDir,
+		JobTTL:         *jobTTL,
+		LeaseTimeout:   *leaseTimeout,
+		QuoteKey:       key,
+		QuoteTTL:       *quoteTTL,
+		Credit:         *credit,
+	}
+	if *jobKey != "" {
+		data, err := ioutil.ReadFile(*jobKey)
+		if err != nil {
+			panic(err)
+		}
+		if config.JobKey, err = hex.DecodeString(strings.TrimSpace(string(data))); err != nil {
+			panic(err)
+		}
+	}
+	return *rpcAddr, config, timeout
 }
\ No newline at end of file
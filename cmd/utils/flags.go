@@ -329,11 +329,99 @@ var (
 		Usage: "start stake",
 	}
 
+	StakeRenewDryRunFlag = cli.BoolFlag{
+		Name:  "stakeRenewDryRun",
+		Usage: "only log the share purchases of the stake renew rules",
+	}
+	StakeIndexFromFlag = cli.Uint64Flag{
+		Name:  "stakeIndexFrom",
+		Usage: "first block of the stake index, the first block with stake records by default",
+	}
+	StakeRecordProofsFlag = cli.BoolFlag{
+		Name:  "stakeRecordProofs",
+		Usage: "keep the state proofs of the indexed shares for stake_proveShare after the state is pruned",
+	}
+
+	VoteSignerFlag = cli.StringFlag{
+		Name:  "voteSigner",
+		Usage: "http url or IPC path of the remote signer of the PoS votes",
+	}
+
+	VoteSignerTokenFlag = cli.StringFlag{
+		Name:  "voteSignerToken",
+		Usage: "bearer token authenticating to a vote signer served over http",
+	}
+
+	VoteAlertLevelFlag = cli.StringFlag{
+		Name:  "voteAlertLevel",
+		Usage: "log level of the alert for a vote not produced in time (error, warn, info, debug, trace)",
+		Value: "warn",
+	}
+
+	VoteAlertExecFlag = cli.StringFlag{
+		Name:  "voteAlertExec",
+		Usage: "command run with the alert for a vote not produced in time",
+	}
+
+	VoteAlertURLFlag = cli.StringFlag{
+		Name:  "voteAlertURL",
+		Usage: "url the alert for a vote not produced in time is posted to",
+	}
+
+	VoteAlertDeadlineFlag = cli.DurationFlag{
+		Name:  "voteAlertDeadline",
+		Usage: "time after the selection of a share of the node to produce its vote",
+		Value: 20 * time.Second,
+	}
+
 	AutoMergeFlag = cli.BoolFlag{
 		Name:  "autoMerge",
 		Usage: "autoMerge outs",
 	}
 
+	CoinSelectorFlag = cli.StringFlag{
+		Name:  "coinSelector",
+		Usage: "default utxo selection strategy of exchange (default, largest-first, branch-and-bound, random, min-inputs)",
+	}
+
+	WebhookURLFlag = cli.StringFlag{
+		Name:  "webhookUrl",
+		Usage: "URL that receives the exchange events as signed JSON POSTs",
+	}
+
+	WebhookSecretFlag = cli.StringFlag{
+		Name:  "webhookSecret",
+		Usage: "HMAC-SHA256 key signing the exchange webhook bodies",
+	}
+
+	WebhookConfirmationsFlag = cli.Uint64Flag{
+		Name:  "webhookConfirmations",
+		Usage: "block confirmations before an exchange event is posted to the webhook",
+		Value: 0,
+	}
+
+	PendingTxTimeoutFlag = cli.DurationFlag{
+		Name:  "pendingTxTimeout",
+		Usage: "time after which an unmined exchange tx is dropped and its utxos are released",
+		Value: time.Hour,
+	}
+
+	FromBlockFlag = cli.Uint64Flag{
+		Name:  "fromBlock",
+		Usage: "first block number of the exchange command",
+	}
+
+	ToBlockFlag = cli.Uint64Flag{
+		Name:  "toBlock",
+		Usage: "block number the exchange export stops before (default: all indexed blocks)",
+	}
+
+	ExportFormatFlag = cli.StringFlag{
+		Name:  "format",
+		Usage: "format of the exchange export (csv, json)",
+		Value: "csv",
+	}
+
 	LightNodeFlag = cli.BoolFlag{
 		Name:  "lightNode",
 		Usage: "start light node",
@@ -353,6 +441,10 @@ var (
 		Name:  "recordBlockShareNumber",
 		Usage: "",
 	}
+	RecordStakeEventsFlag = cli.BoolFlag{
+		Name:  "recordStakeEvents",
+		Usage: "Record the stake reward events of the imported blocks",
+	}
 
 	// Miner settings
 	MiningEnabledFlag = cli.BoolFlag{
@@ -1025,6 +1117,10 @@ func SetP2PConfig(ctx *cli.Context, cfg *p2p.Config) {
 		zconfig.Init_RecordShareNum()
 	}
 
+	if ctx.GlobalIsSet(RecordStakeEventsFlag.Name) {
+		zconfig.Init_RecordStakeEvents()
+	}
+
 	if ctx.GlobalIsSet(ResetBlockNumber.Name) {
 		blockNumber := ctx.GlobalUint64(ResetBlockNumber.Name)
 		seroparam.InitCurrentBlockNumber(blockNumber)
@@ -1274,6 +1370,17 @@ func SetSeroConfig(ctx *cli.Context, stack *node.Node, cfg *sero.Config) {
 		if ctx.GlobalIsSet(AutoMergeFlag.Name) {
 			cfg.AutoMerge = true
 		}
+		if ctx.GlobalIsSet(CoinSelectorFlag.Name) {
+			cfg.Exchange.CoinSelector = ctx.GlobalString(CoinSelectorFlag.Name)
+		}
+		if ctx.GlobalIsSet(WebhookURLFlag.Name) {
+			cfg.Exchange.WebhookURL = ctx.GlobalString(WebhookURLFlag.Name)
+			cfg.Exchange.WebhookSecret = ctx.GlobalString(WebhookSecretFlag.Name)
+			cfg.Exchange.WebhookConfirmations = ctx.GlobalUint64(WebhookConfirmationsFlag.Name)
+		}
+		if ctx.GlobalIsSet(PendingTxTimeoutFlag.Name) {
+			cfg.Exchange.PendingTimeout = ctx.GlobalDuration(PendingTxTimeoutFlag.Name)
+		}
 	}
 
 	if ctx.GlobalIsSet(ExchangeValueStrFlag.Name) {
@@ -1284,6 +1391,33 @@ func SetSeroConfig(ctx *cli.Context, stack *node.Node, cfg *sero.Config) {
 		cfg.StartStake = true
 	}
 
+	if ctx.GlobalIsSet(StakeRenewDryRunFlag.Name) {
+		cfg.Stake.DryRun = true
+	}
+	if ctx.GlobalIsSet(StakeIndexFromFlag.Name) {
+		cfg.Stake.IndexFrom = ctx.GlobalUint64(StakeIndexFromFlag.Name)
+	}
+	if ctx.GlobalIsSet(StakeRecordProofsFlag.Name) {
+		cfg.Stake.RecordProofs = true
+	}
+
+	if ctx.GlobalIsSet(VoteSignerFlag.Name) {
+		cfg.Voter.SignerURL = ctx.GlobalString(VoteSignerFlag.Name)
+		cfg.Voter.SignerToken = ctx.GlobalString(VoteSignerTokenFlag.Name)
+	}
+	if ctx.GlobalIsSet(VoteAlertLevelFlag.Name) {
+		cfg.Voter.Alert.Level = ctx.GlobalString(VoteAlertLevelFlag.Name)
+	}
+	if ctx.GlobalIsSet(VoteAlertExecFlag.Name) {
+		cfg.Voter.Alert.Exec = ctx.GlobalString(VoteAlertExecFlag.Name)
+	}
+	if ctx.GlobalIsSet(VoteAlertURLFlag.Name) {
+		cfg.Voter.Alert.URL = ctx.GlobalString(VoteAlertURLFlag.Name)
+	}
+	if ctx.GlobalIsSet(VoteAlertDeadlineFlag.Name) {
+		cfg.Voter.Alert.Deadline = ctx.GlobalDuration(VoteAlertDeadlineFlag.Name)
+	}
+
 	if ctx.GlobalIsSet(LightNodeFlag.Name) {
 		cfg.StartLight = true
 	}
@@ -0,0 +1,125 @@
+// votesigner signs the PoS votes of a gero node with keys that stay off the
+// node. Start gero with --voteSigner pointing at its IPC path, or at its http
+// address together with --voteSignerToken.
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/sero-cash/go-czero-import/c_type"
+	"github.com/sero-cash/go-czero-import/superzk"
+	"github.com/sero-cash/go-sero/accounts"
+	"github.com/sero-cash/go-sero/accounts/keystore"
+	"github.com/sero-cash/go-sero/log"
+	"github.com/sero-cash/go-sero/rpc"
+	"github.com/sero-cash/go-sero/serodb"
+	"github.com/sero-cash/go-sero/voter"
+)
+
+var (
+	keystoreDir  = flag.String("keystore", "", "directory of the vote keys")
+	passwordFile = flag.String("password", "", "file holding the password of the vote keys")
+	dataDir      = flag.String("datadir", "votesigner", "directory of the signing history")
+	ipcPath      = flag.String("ipc", "", "IPC path, votesigner.ipc in the datadir by default")
+	httpAddr     = flag.String("http", "", "http listen address, disabled when empty")
+	tokenFile    = flag.String("tokenFile", "", "file holding the bearer token the http clients must send")
+	alpha        = flag.Bool("alpha", false, "sign for the alpha network")
+	dev          = flag.Bool("dev", false, "sign for the dev network")
+	verbosity    = flag.Int("verbosity", int(log.LvlInfo), "log level 0-5")
+)
+
+func readFile(path string) string {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		log.Crit("Failed to read file", "path", path, "err", err)
+	}
+	return strings.TrimSpace(string(data))
+}
+
+func openKeys() *accounts.Manager {
+	if *keystoreDir == "" {
+		log.Crit("The keystore is required")
+	}
+	ks := keystore.NewKeyStore(*keystoreDir, keystore.StandardScryptN, keystore.StandardScryptP)
+	password := ""
+	if *passwordFile != "" {
+		password = readFile(*passwordFile)
+	}
+	for _, account := range ks.Accounts() {
+		if err := ks.Unlock(account, password); err != nil {
+			log.Crit("Failed to unlock vote key", "account", account.Address, "err", err)
+		}
+		log.Info("Unlocked vote key", "account", account.Address)
+	}
+	return accounts.NewManager(ks)
+}
+
+func main() {
+	flag.Parse()
+	log.Root().SetHandler(log.LvlFilterHandler(log.Lvl(*verbosity), log.StreamHandler(os.Stderr, log.TerminalFormat(true))))
+
+	netType := c_type.NET_Beta
+	switch {
+	case *alpha:
+		netType = c_type.NET_Alpha
+	case *dev:
+		netType = c_type.NET_Dev
+	}
+	superzk.ZeroInit(*keystoreDir, netType)
+
+	am := openKeys()
+	db, err := serodb.NewLDBDatabase(filepath.Join(*dataDir, "signed"), 16, 16)
+	if err != nil {
+		log.Crit("Failed to open the signing history", "err", err)
+	}
+	defer db.Close()
+
+	apis := []rpc.API{{
+		Namespace: "votesigner",
+		Version:   "1.0",
+		Service:   voter.NewSignerService(voter.NewLocalSigner(am), db),
+		Public:    true,
+	}}
+
+	if *ipcPath == "" {
+		*ipcPath = filepath.Join(*dataDir, "votesigner.ipc")
+	}
+	listener, _, err := rpc.StartIPCEndpoint(*ipcPath, apis)
+	if err != nil {
+		log.Crit("Failed to start the IPC endpoint", "err", err)
+	}
+	defer listener.Close()
+	log.Info("IPC endpoint opened", "url", *ipcPath)
+
+	if *httpAddr != "" {
+		if *tokenFile == "" {
+			log.Crit("The http endpoint needs a token file")
+		}
+		server := rpc.NewServer()
+		for _, api := range apis {
+			if err := server.RegisterName(api.Namespace, api.Service); err != nil {
+				log.Crit("Failed to register the API", "err", err)
+			}
+		}
+		httpListener, err := net.Listen("tcp", *httpAddr)
+		if err != nil {
+			log.Crit("Failed to start the http endpoint", "err", err)
+		}
+		defer httpListener.Close()
+		go http.Serve(httpListener, voter.TokenHandler(readFile(*tokenFile), server))
+		log.Info("HTTP endpoint opened", "url", "http://"+*httpAddr)
+	}
+
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, syscall.SIGINT, syscall.SIGTERM)
+	<-sigc
+	log.Info("Vote signer stopped")
+}
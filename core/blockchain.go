@@ -1342,8 +1342,9 @@ func (bc *BlockChain) insertChain(chain types.Blocks, local bool) (int, []interf
 			}
 		}
 
+		var stakeState *stake.StakeState
 		if seroparam.SIP4() <= block.NumberU64() {
-			stakeState := stake.NewStakeState(state)
+			stakeState = stake.NewStakeState(state)
 			err = stakeState.ProcessBeforeApply(bc, block.Header())
 			if err != nil {
 				log.Error("insert chain pos block processBeforeApply", "err", err)
@@ -1379,6 +1380,11 @@ func (bc *BlockChain) insertChain(chain types.Blocks, local bool) (int, []interf
 		if err != nil {
 			return i, events, coalescedLogs, err
 		}
+		if stakeState != nil && zconfig.RecordStakeEvents() {
+			if err := stake.WriteBlockEvents(bc.db, block.Hash(), stakeState.Events()); err != nil {
+				log.Error("Failed to write stake events", "number", block.Number(), "err", err)
+			}
+		}
 		switch status {
 		case CanonStatTy:
 			log.Debug("Inserted new block", "number", block.Number(), "hash", block.Hash(),
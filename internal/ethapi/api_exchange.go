@@ -109,6 +109,7 @@ type ReceptionArgs struct {
 	Addr     MixAdrress
 	Currency Smbol
 	Value    *Big
+	Memo     c_type.Uint512
 }
 
 func MixAdrressToPkr(addr MixAdrress) c_type.PKr {
@@ -131,11 +132,24 @@ func (s *PublicExchangeAPI) GenTx(ctx context.Context, param GenTxArgs) (*txtool
 	return s.b.GenTx(param.toTxParam())
 }
 
+// watchOnlyError hands the unsigned tx param of a watch-only account back in
+// the error data, the roots stay locked like exchange_genTx does.
+type watchOnlyError struct {
+	param *txtool.GTxParam
+}
+
+func (e *watchOnlyError) Error() string { return exchange.ErrWatchOnly.Error() }
+
+func (e *watchOnlyError) ErrorData() interface{} { return e.param }
+
 func (s *PublicExchangeAPI) GenTxWithSign(ctx context.Context, param GenTxArgs) (*txtool.GTx, error) {
 	if err := param.check(); err != nil {
 		return nil, err
 	}
 	txParam, tx, e := exchange.CurrentExchange().GenTxWithSign(param.toTxParam())
+	if e == exchange.ErrWatchOnly {
+		return nil, &watchOnlyError{txParam}
+	}
 	if tx != nil {
 		for _, in := range txParam.Ins {
 			tx.Roots = append(tx.Roots, in.Out.Root)
@@ -144,6 +158,58 @@ func (s *PublicExchangeAPI) GenTxWithSign(ctx context.Context, param GenTxArgs)
 	return tx, e
 }
 
+func (s *PublicExchangeAPI) CompareSelectors(ctx context.Context, param GenTxArgs) ([]prepare.Selection, error) {
+	if err := param.check(); err != nil {
+		return nil, err
+	}
+	exchangeInstance := exchange.CurrentExchange()
+	if exchangeInstance == nil {
+		return nil, errors.New("exchange mode no start")
+	}
+	return exchangeInstance.CompareSelectors(param.toTxParam()), nil
+}
+
+type BatchPayArgs struct {
+	From       address.PKAddress
+	RefundTo   *PKrAddress
+	Receptions []ReceptionArgs
+	Gas        uint64
+	GasPrice   *Big
+	Selector   string
+}
+
+// BatchPay signs and commits the payouts in as few txs as possible, the
+// results follow the order of the receptions.
+func (s *PublicExchangeAPI) BatchPay(ctx context.Context, args BatchPayArgs) ([]exchange.BatchPayResult, error) {
+	if args.Gas == 0 {
+		args.Gas = exchange.DefaultGas
+	}
+	genArgs := GenTxArgs{
+		From:       args.From,
+		RefundTo:   args.RefundTo,
+		Receptions: args.Receptions,
+		Gas:        args.Gas,
+		GasPrice:   args.GasPrice,
+		Selector:   args.Selector,
+	}
+	if err := genArgs.check(); err != nil {
+		return nil, err
+	}
+	exchangeInstance := exchange.CurrentExchange()
+	if exchangeInstance == nil {
+		return nil, errors.New("exchange mode no start")
+	}
+	param := genArgs.toTxParam()
+	return exchangeInstance.BatchPay(exchange.BatchPayParam{
+		From:       param.From,
+		RefundTo:   param.RefundTo,
+		Receptions: param.Receptions,
+		Gas:        args.Gas,
+		GasPrice:   param.GasPrice,
+		Selector:   param.Selector,
+	})
+}
+
 func pkrToPKrAddress(pkr c_type.PKr) PKrAddress {
 	pkrAddress := PKrAddress{}
 	copy(pkrAddress[:], pkr[:])
@@ -350,6 +416,19 @@ func (s *PublicExchangeAPI) Merge(ctx context.Context, pk *address.PKAddress, cy
 	}, nil
 }
 
+// MergeStatus reports the last and next auto merge of each currency of the
+// merge policy and why the last check skipped it.
+func (s *PublicExchangeAPI) MergeStatus(ctx context.Context, pk *address.PKAddress) ([]exchange.MergeStatus, error) {
+	exchangeInstance := exchange.CurrentExchange()
+	if exchangeInstance == nil {
+		return nil, errors.New("exchange mode no start")
+	}
+	if pk == nil {
+		return exchangeInstance.GetMergeStatus(nil)
+	}
+	return exchangeInstance.GetMergeStatus(pk.ToUint512().NewRef())
+}
+
 func validAddress(addr MixAdrress) (bool, error) {
 	if len(addr) != 64 && len(addr) != 96 {
 		return false, errors.Errorf("invalid addr %v", hexutil.Encode(addr[:]))
@@ -396,7 +475,81 @@ func (s *PublicExchangeAPI) ValidAddress(ctx context.Context, addr address.MixBa
 }
 
 func (s *PublicExchangeAPI) CommitTx(ctx context.Context, args *txtool.GTx) error {
-	return s.b.CommitTx(args)
+	if err := s.b.CommitTx(args); err != nil {
+		return err
+	}
+	if exchangeInstance := exchange.CurrentExchange(); exchangeInstance != nil {
+		if err := exchangeInstance.TrackTx(args, args.Roots); err != nil {
+			log.Error("Exchange track tx", "txhash", hexutil.Encode(args.Hash[:]), "error", err)
+		}
+	}
+	return nil
+}
+
+func (s *PublicExchangeAPI) AddWatchTk(ctx context.Context, tk address.TKAddress, at *uint64) (ret address.PKAddress, e error) {
+	exchangeInstance := exchange.CurrentExchange()
+	if exchangeInstance == nil {
+		return ret, errors.New("exchange mode no start")
+	}
+	num := uint64(0)
+	if at != nil {
+		num = *at
+	}
+	var pk c_type.Uint512
+	if pk, e = exchangeInstance.AddWatchTk(tk.ToTk(), num); e != nil {
+		return
+	}
+	copy(ret[:], pk[:])
+	return
+}
+
+func (s *PublicExchangeAPI) RemoveWatchTk(ctx context.Context, pk address.PKAddress) error {
+	exchangeInstance := exchange.CurrentExchange()
+	if exchangeInstance == nil {
+		return errors.New("exchange mode no start")
+	}
+	return exchangeInstance.RemoveWatchTk(pk.ToUint512())
+}
+
+func (s *PublicExchangeAPI) GetWatchAccounts(ctx context.Context) ([]address.PKAddress, error) {
+	exchangeInstance := exchange.CurrentExchange()
+	if exchangeInstance == nil {
+		return nil, errors.New("exchange mode no start")
+	}
+	return exchangeInstance.GetWatchAccounts(), nil
+}
+
+// GetTxHistory returns the txs of pk in the blocks [begin, end), end 0 means
+// up to the last indexed block.
+func (s *PublicExchangeAPI) GetTxHistory(ctx context.Context, pk address.PKAddress, begin, end uint64) ([]exchange.HistoryTx, error) {
+	exchangeInstance := exchange.CurrentExchange()
+	if exchangeInstance == nil {
+		return nil, errors.New("exchange mode no start")
+	}
+	if end == 0 {
+		end = exchangeInstance.GetCurrencyNumber(pk.ToUint512()) + 1
+	}
+	return exchangeInstance.GetHistory(pk.ToUint512(), begin, end)
+}
+
+// Rescan indexes the blocks of pk again from fromBlock on in the background.
+func (s *PublicExchangeAPI) Rescan(ctx context.Context, pk address.PKAddress, fromBlock uint64) error {
+	exchangeInstance := exchange.CurrentExchange()
+	if exchangeInstance == nil {
+		return errors.New("exchange mode no start")
+	}
+	return exchangeInstance.StartRescan([]c_type.Uint512{pk.ToUint512()}, fromBlock)
+}
+
+func (s *PublicExchangeAPI) GetPendingTxs(ctx context.Context, pk *address.PKAddress) ([]exchange.PendingTx, error) {
+	exchangeInstance := exchange.CurrentExchange()
+	if exchangeInstance == nil {
+		return nil, errors.New("exchange mode no start")
+	}
+	if pk == nil {
+		return exchangeInstance.GetPendingTxs(nil), nil
+	}
+	return exchangeInstance.GetPendingTxs(pk.ToUint512().NewRef()), nil
 }
 
 func (s *PublicExchangeAPI) ClearUsedFlag(ctx context.Context, pk address.PKAddress) (count int, e error) {
@@ -591,3 +744,78 @@ func (s *PublicExchangeAPI) SetBalancePkr(ctx context.Context, pkr PKrAddress) e
 func (s *PublicExchangeAPI) IgnorePkrUtxos(ctx context.Context, pkr PKrAddress, ignore bool) (utxos []exchange.Utxo, e error) {
 	return exchange.CurrentExchange().IgnorePkrUtxos(*pkr.ToPKr(), ignore)
 }
+
+type EventArgs struct {
+	Types         []string
+	Addresses     []MixAdrress
+	Confirmations uint64
+}
+
+func (args *EventArgs) toCriteria() (crit exchange.EventCriteria, e error) {
+	for _, typ := range args.Types {
+		switch typ {
+		case exchange.EventUtxoIn, exchange.EventUtxoSpent, exchange.EventTxConfirmed, exchange.EventPkgReceived, exchange.EventRollback:
+			crit.Types = append(crit.Types, typ)
+		default:
+			e = errors.Errorf("unknown event type: %v", typ)
+			return
+		}
+	}
+	for _, addr := range args.Addresses {
+		if len(addr) == 64 {
+			var pk c_type.Uint512
+			copy(pk[:], addr[:])
+			crit.PKs = append(crit.PKs, pk)
+		} else if len(addr) == 96 {
+			var pkr c_type.PKr
+			copy(pkr[:], addr[:])
+			crit.Pkrs = append(crit.Pkrs, pkr)
+		} else {
+			e = errors.New("address is error")
+			return
+		}
+	}
+	crit.Confirmations = args.Confirmations
+	return
+}
+
+// Events streams the exchange events, exchange_subscribe("events", {Types, Addresses, Confirmations}).
+func (s *PublicExchangeAPI) Events(ctx context.Context, args EventArgs) (*rpc.Subscription, error) {
+	exchangeInstance := exchange.CurrentExchange()
+	if exchangeInstance == nil {
+		return nil, errors.New("exchange mode no start")
+	}
+	crit, err := args.toCriteria()
+	if err != nil {
+		return nil, err
+	}
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		events := make(chan exchange.Event)
+		sub := exchangeInstance.SubscribeEvents(crit, events)
+
+		for {
+			select {
+			case ev := <-events:
+				notifier.Notify(rpcSub.ID, ev)
+			case err := <-sub.Err():
+				log.Warn("Exchange events subscription ended", "id", rpcSub.ID, "error", err)
+				return
+			case <-rpcSub.Err():
+				sub.Unsubscribe()
+				return
+			case <-notifier.Closed():
+				sub.Unsubscribe()
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
@@ -0,0 +1,128 @@
+package ethapi
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/sero-cash/go-czero-import/seroparam"
+	"github.com/sero-cash/go-sero/common/hexutil"
+	"github.com/sero-cash/go-sero/core"
+	"github.com/sero-cash/go-sero/zero/txs/assets"
+	"github.com/sero-cash/go-sero/zero/txtool/prepare"
+	"github.com/sero-cash/go-sero/zero/utils"
+	"github.com/sero-cash/go-sero/zero/wallet/exchange"
+)
+
+// feePriorities are the percentiles of the lowest prices recent blocks
+// included that the low, medium and high fee levels pay.
+var feePriorities = []struct {
+	name       string
+	percentile int
+}{
+	{"low", 20},
+	{"medium", 50},
+	{"high", 80},
+}
+
+type FeeLevel struct {
+	Priority    string
+	Gas         hexutil.Uint64
+	GasPrice    *hexutil.Big
+	Fee         *hexutil.Big
+	InputCount  int
+	OutputCount int
+	Selection   prepare.Selection
+}
+
+// FeeEstimate is the fee of a tx at each priority. Gas is the intrinsic gas
+// the nodes charge, the inputs, outputs and SZK only tell the tx it builds.
+type FeeEstimate struct {
+	Gas    hexutil.Uint64
+	SZK    bool
+	Levels []FeeLevel
+}
+
+// txGas is the gas the tx of args needs before any contract code runs.
+func txGas(args *GenTxArgs) (uint64, error) {
+	var data []byte
+	create := false
+	if args.Cmds != nil && args.Cmds.Contract != nil {
+		data = args.Cmds.Contract.Data
+		create = args.Cmds.Contract.To == nil
+	}
+	return core.IntrinsicGas(data, create)
+}
+
+// txCounts guesses the inputs and outputs of the tx of args when there is
+// no selection: the given roots or one utxo and one change per currency.
+func txCounts(args *GenTxArgs) (ins, outs int) {
+	currencies := map[string]bool{"SERO": true}
+	for _, rec := range args.Receptions {
+		currencies[string(rec.Currency)] = true
+	}
+	ins = len(args.Roots)
+	if ins == 0 {
+		ins = len(currencies)
+	}
+	return ins, len(args.Receptions) + len(currencies)
+}
+
+// EstimateTxFee returns the fee of the tx for each priority. With the
+// exchange running the levels carry the utxos it would select, no utxo gets
+// locked.
+func (s *PublicEthereumAPI) EstimateTxFee(ctx context.Context, args GenTxArgs) (*FeeEstimate, error) {
+	if args.GasPrice == nil {
+		args.GasPrice = new(Big)
+	}
+	if err := args.check(); err != nil {
+		return nil, err
+	}
+
+	gas, err := txGas(&args)
+	if err != nil {
+		return nil, err
+	}
+	if args.Gas > gas {
+		gas = args.Gas
+	}
+	percentiles := make([]int, len(feePriorities))
+	for i, priority := range feePriorities {
+		percentiles[i] = priority.percentile
+	}
+	prices, err := s.b.SuggestPrices(ctx, percentiles)
+	if err != nil {
+		return nil, err
+	}
+	exchangeInstance := exchange.CurrentExchange()
+
+	param := args.toTxParam()
+	estimate := &FeeEstimate{
+		Gas: hexutil.Uint64(gas),
+		SZK: s.b.CurrentBlock().NumberU64()+1 >= seroparam.SIP5(),
+	}
+	for i, priority := range feePriorities {
+		fee := new(big.Int).Mul(new(big.Int).SetUint64(gas), prices[i])
+		level := FeeLevel{
+			Priority: priority.name,
+			Gas:      hexutil.Uint64(gas),
+			GasPrice: (*hexutil.Big)(prices[i]),
+			Fee:      (*hexutil.Big)(fee),
+		}
+		level.InputCount, level.OutputCount = txCounts(&args)
+		if exchangeInstance != nil {
+			param.GasPrice = prices[i]
+			param.Fee = assets.Token{
+				Currency: utils.CurrencyToUint256("SERO"),
+				Value:    utils.U256(*fee),
+			}
+			if level.Selection, err = exchangeInstance.EstimateSelection(param); err != nil {
+				level.Selection.Error = err.Error()
+			} else {
+				level.InputCount = level.Selection.InputCount
+				level.OutputCount = len(args.Receptions) + len(level.Selection.Change)
+			}
+		}
+		estimate.Levels = append(estimate.Levels, level)
+	}
+	return estimate, nil
+}
@@ -33,3 +33,13 @@ func (plna PublicLightNodeApi) CheckNil(Nils []c_type.Uint256) (nilResps []light
 
 	return plna.b.CheckNil(Nils)
 }
+
+// GetFilters returns the compact filters of count blocks from start, the
+// wallets match them locally and fetch the matching blocks by number.
+func (plna PublicLightNodeApi) GetFilters(start, count uint64) (filters []light.BlockFilter, e error) {
+	return plna.b.GetFilters(start, count)
+}
+
+func (plna PublicLightNodeApi) GetBlockOuts(num uint64) (outs light.BlockOuts, e error) {
+	return plna.b.GetBlockOuts(num)
+}
@@ -1,6 +1,8 @@
 package ethapi
 
 import (
+	"errors"
+
 	"github.com/sero-cash/go-sero/common"
 	"github.com/sero-cash/go-sero/common/hexutil"
 	"github.com/sero-cash/go-sero/zero/proofservice"
@@ -22,10 +24,99 @@ func (nodeApi *ProofServiceApi) Fee() map[string]hexutil.Big {
 	return ret
 }
 
-func (nodeApi *ProofServiceApi) SubmitProofWork(tx *stx.T, param *txtool.GTxParam) error {
-	return proofservice.Instance().SubmitWork(tx, param)
+// SubmitProofWork queues the proof of the tx, charged the fee of the quote
+// when one is given.
+func (nodeApi *ProofServiceApi) SubmitProofWork(tx *stx.T, param *txtool.GTxParam, quote *proofservice.Quote) error {
+	return proofservice.Instance().SubmitWork(tx, param, quote)
+}
+
+// Quote returns a signed fee for the proof of the tx of the param.
+func (nodeApi *ProofServiceApi) Quote(param *txtool.GTxParam) (*proofservice.Quote, error) {
+	service := proofservice.Instance()
+	if service == nil {
+		return nil, errors.New("proof service no start")
+	}
+	return service.Quote(param)
+}
+
+// Credit returns the prepaid credit of the client and what its jobs spent.
+func (nodeApi *ProofServiceApi) Credit(client PKrAddress) (map[string]interface{}, error) {
+	service := proofservice.Instance()
+	if service == nil {
+		return nil, errors.New("proof service no start")
+	}
+	account := service.Account(*client.ToPKr())
+	return map[string]interface{}{
+		"balance":   (*hexutil.Big)(account.Balance),
+		"deposited": (*hexutil.Big)(account.Deposited),
+		"spent":     (*hexutil.Big)(account.Spent),
+		"jobs":      hexutil.Uint64(account.Jobs),
+	}, nil
 }
 
 func (nodeApi *ProofServiceApi) FindTxHash(hash common.Hash) common.Hash {
 	return proofservice.Instance().FindTxHash(hash)
 }
+
+func newRPCProofJob(job *proofservice.Job) map[string]interface{} {
+	ret := map[string]interface{}{}
+	ret["hash"] = job.Hash
+	ret["state"] = job.State
+	if job.Reason != "" {
+		ret["reason"] = job.Reason
+	}
+	if job.State == proofservice.JobCommitted {
+		ret["txHash"] = job.TxHash
+	}
+	ret["timestamp"] = hexutil.Uint64(job.Timestamp.Unix())
+	ret["updated"] = hexutil.Uint64(job.Updated.Unix())
+	return ret
+}
+
+// JobStatus returns the state of the job proving the tx with the hash.
+func (nodeApi *ProofServiceApi) JobStatus(hash common.Hash) (map[string]interface{}, error) {
+	service := proofservice.Instance()
+	if service == nil {
+		return nil, errors.New("proof service no start")
+	}
+	job := service.Job(hash)
+	if job == nil {
+		return nil, errors.New("job not found")
+	}
+	return newRPCProofJob(job), nil
+}
+
+// ProofAdminApi lists the jobs of all the clients and the workers, it is
+// served to the operator only.
+type ProofAdminApi struct {
+}
+
+func NewProofAdminApi() *ProofAdminApi {
+	return &ProofAdminApi{}
+}
+
+// ListJobs returns the jobs in the state, all jobs when it is not given.
+func (adminApi *ProofAdminApi) ListJobs(state *string) ([]map[string]interface{}, error) {
+	service := proofservice.Instance()
+	if service == nil {
+		return nil, errors.New("proof service no start")
+	}
+	filter := ""
+	if state != nil {
+		filter = *state
+	}
+	ret := []map[string]interface{}{}
+	for _, job := range service.Jobs(filter) {
+		ret = append(ret, newRPCProofJob(job))
+	}
+	return ret, nil
+}
+
+// Workers returns the throughput of the worker processes proving the jobs.
+func (adminApi *ProofAdminApi) Workers() ([]proofservice.WorkerStats, error) {
+	service := proofservice.Instance()
+	if service == nil {
+		return nil, errors.New("proof service no start")
+	}
+	return service.Workers(), nil
+}
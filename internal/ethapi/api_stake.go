@@ -14,7 +14,9 @@ import (
 	"github.com/sero-cash/go-czero-import/superzk"
 	"github.com/sero-cash/go-sero/crypto"
 	"github.com/sero-cash/go-sero/log"
+	"github.com/sero-cash/go-sero/rlp"
 	"github.com/sero-cash/go-sero/rpc"
+	"github.com/sero-cash/go-sero/voter"
 
 	"github.com/sero-cash/go-sero/accounts"
 
@@ -31,7 +33,9 @@ import (
 	"github.com/pkg/errors"
 	"github.com/sero-cash/go-sero/common"
 	"github.com/sero-cash/go-sero/common/hexutil"
+	"github.com/sero-cash/go-sero/core/types"
 	"github.com/sero-cash/go-sero/zero/stake"
+	"github.com/sero-cash/go-sero/zero/stake/sim"
 )
 
 type PublicStakeApI struct {
@@ -46,6 +50,45 @@ func NewPublicStakeApI(b Backend, nonceLock *AddrLocker) *PublicStakeApI {
 	}
 }
 
+// PrivateStakeAPI tells the state of the local keys of the stake pools.
+type PrivateStakeAPI struct {
+	b Backend
+}
+
+func NewPrivateStakeAPI(b Backend) *PrivateStakeAPI {
+	return &PrivateStakeAPI{b}
+}
+
+// VoteKeyStatus reports whether the vote key of the pool is in a local wallet,
+// whether that wallet is unlocked and whether the voter can sign with it.
+func (s *PrivateStakeAPI) VoteKeyStatus(ctx context.Context, poolId common.Hash) (map[string]interface{}, error) {
+	state, _, err := s.b.StateAndHeaderByNumber(ctx, -1)
+	if err != nil {
+		return nil, err
+	}
+	pool := stake.NewStakeState(state).GetStakePool(poolId)
+	if pool == nil {
+		return nil, errors.New("stake pool not exists")
+	}
+	local, unlocked := false, false
+	for _, w := range s.b.AccountManager().Wallets() {
+		if w.IsMine(pool.VotePKr) {
+			local = true
+			seed, err := w.GetSeed()
+			unlocked = err == nil && seed != nil
+			break
+		}
+	}
+	ret := map[string]interface{}{}
+	ret["voteAddress"] = PkrToString(pool.VotePKr)
+	ret["voteKeyLocal"] = local
+	ret["voteKeyUnlocked"] = unlocked
+	if voter := s.b.GetVoter(); voter != nil {
+		ret["voteKeyAvailable"] = voter.HasVoteKey(pool.VotePKr)
+	}
+	return ret, nil
+}
+
 type BuyShareTxArg struct {
 	From     address.MixBase58Adrress  `json:"from"`
 	Vote     *address.MixBase58Adrress `json:"vote"`
@@ -828,3 +871,654 @@ func (s *PublicStakeApI) GetShareAtNumber(ctx context.Context, shareId common.Ha
 	share = stake.GetShareByBlockNumber(s.b.ChainDb(), shareId, header.Hash(), header.Number.Uint64())
 	return
 }
+
+// ProveShare returns the share, and its pool, at the block with the RLP
+// encoded stake.StakeProof of their records against the state root of the
+// block, stake.VerifyStakeProof checks it with the header alone. Once the
+// state is pruned the proof recorded by the stake service at the last block
+// writing the share is returned, blockNumber is then that block.
+func (s *PublicStakeApI) ProveShare(ctx context.Context, shareId common.Hash, blockNumber hexutil.Uint64) (map[string]interface{}, error) {
+	var proof *stake.StakeProof
+	state, header, err := s.b.StateAndHeaderByNumber(ctx, rpc.BlockNumber(blockNumber))
+	if state != nil && err == nil {
+		if proof, err = stake.NewStakeState(state).ProveShare(shareId); err != nil {
+			return nil, err
+		}
+	} else if service := stakeservice.CurrentStakeService(); service != nil && service.RecordsProofs() {
+		var at uint64
+		if proof, at, err = service.ShareProof(shareId, uint64(blockNumber)); err != nil {
+			return nil, err
+		}
+		if header, err = s.b.HeaderByNumber(ctx, rpc.BlockNumber(at)); header == nil || err != nil {
+			return nil, fmt.Errorf("header %v of the proof not found", at)
+		}
+	} else {
+		if err == nil {
+			err = errors.New("state of the block not found")
+		}
+		return nil, err
+	}
+	data, err := rlp.EncodeToBytes(proof)
+	if err != nil {
+		return nil, err
+	}
+	wallets := s.b.AccountManager().Wallets()
+	ret := map[string]interface{}{}
+	ret["blockNumber"] = hexutil.Uint64(header.Number.Uint64())
+	ret["blockHash"] = header.Hash()
+	ret["stateRoot"] = header.Root
+	ret["share"] = newRPCShare(wallets, *proof.Share, 0)
+	if proof.Pool != nil {
+		ret["pool"] = newRPCStakePool(wallets, *proof.Pool, 0)
+	}
+	ret["proof"] = hexutil.Bytes(data)
+	return ret, nil
+}
+
+// RewardHistory returns the rewards and refunds of a share or a pool, the
+// node must run with --recordStakeEvents.
+func (s *PublicStakeApI) RewardHistory(ctx context.Context, id common.Hash, fromBlock, toBlock hexutil.Uint64) ([]map[string]interface{}, error) {
+	service := stakeservice.CurrentStakeService()
+	if service == nil {
+		return nil, errors.New("stake service no start")
+	}
+	ret := []map[string]interface{}{}
+	for _, event := range service.RewardHistory(id, uint64(fromBlock), uint64(toBlock)) {
+		each := map[string]interface{}{}
+		each["blockNumber"] = hexutil.Uint64(event.Block)
+		each["kind"] = event.Kind.String()
+		each["shareId"] = event.Id
+		if event.PoolId != nil {
+			each["poolId"] = event.PoolId
+		}
+		each["amount"] = (*hexutil.Big)(event.Amount)
+		each["count"] = hexutil.Uint64(event.Count)
+		ret = append(ret, each)
+	}
+	return ret, nil
+}
+
+// PoolStats returns the rewards, the miss rate and the APR of a pool over
+// each window of blocks, a day, a week and a month when windows is empty.
+func (s *PublicStakeApI) PoolStats(ctx context.Context, poolId common.Hash, windows []hexutil.Uint64) ([]map[string]interface{}, error) {
+	service := stakeservice.CurrentStakeService()
+	if service == nil {
+		return nil, errors.New("stake service no start")
+	}
+	nums := []uint64{}
+	for _, window := range windows {
+		if window == 0 {
+			return nil, errors.New("window must be positive")
+		}
+		nums = append(nums, uint64(window))
+	}
+	ret := []map[string]interface{}{}
+	for _, stats := range service.PoolStats(poolId, nums) {
+		each := map[string]interface{}{}
+		each["window"] = hexutil.Uint64(stats.Window)
+		each["fromBlock"] = hexutil.Uint64(stats.From)
+		each["toBlock"] = hexutil.Uint64(stats.To)
+		each["poolVotes"] = hexutil.Uint64(stats.PoolVotes)
+		each["soloVotes"] = hexutil.Uint64(stats.SoloVotes)
+		each["missed"] = hexutil.Uint64(stats.Missed)
+		each["expired"] = hexutil.Uint64(stats.Expired)
+		each["rewards"] = (*hexutil.Big)(stats.Rewards)
+		each["fees"] = (*hexutil.Big)(stats.Fees)
+		each["missRate"] = stats.MissRate
+		each["apr"] = stats.APR
+		ret = append(ret, each)
+	}
+	return ret, nil
+}
+
+const (
+	// defaultStatusWindow is how many blocks PoolOperatorStatus checks the
+	// votes of.
+	defaultStatusWindow = 256
+	// maxStatusWindow bounds the headers one PoolOperatorStatus call reads.
+	maxStatusWindow = 4096
+)
+
+// poolChain reads the headers and the stake records poolVotes and
+// nextPayBlock look at.
+type poolChain interface {
+	header(num uint64) *types.Header
+	selectedShares(header *types.Header) []*stake.Share
+	poolChanged(poolId common.Hash, header *types.Header) bool
+	signedVotes(from, to uint64) []voter.SignedVote
+}
+
+type backendPoolChain struct {
+	ctx context.Context
+	b   Backend
+}
+
+func (self backendPoolChain) header(num uint64) *types.Header {
+	header, _ := self.b.HeaderByNumber(self.ctx, rpc.BlockNumber(num))
+	return header
+}
+
+func (self backendPoolChain) selectedShares(header *types.Header) []*stake.Share {
+	_, shares := stake.SeleteBlockShare(self.b.ChainDb(), header.Hash())
+	return shares
+}
+
+func (self backendPoolChain) poolChanged(poolId common.Hash, header *types.Header) bool {
+	return stake.GetStakePoolByBlockNumber(self.b.ChainDb(), poolId, header.Hash(), header.Number.Uint64()) != nil
+}
+
+func (self backendPoolChain) signedVotes(from, to uint64) []voter.SignedVote {
+	if voter := self.b.GetVoter(); voter != nil {
+		return voter.SignedVotes(from, to)
+	}
+	return nil
+}
+
+type poolVoteStats struct {
+	expected  uint64
+	poolVotes uint64
+	soloVotes uint64
+	signed    uint64
+}
+
+// poolVotes compares the shares of the pool selected at the blocks from to
+// with the votes of the headers and the votes signed by the local voter.
+func poolVotes(chain poolChain, poolId common.Hash, from, to uint64) (stats poolVoteStats, err error) {
+	selected := map[uint64]map[common.Hash]int{}
+	child := chain.header(to + 1)
+	for num := to; num >= from && num > 0; num-- {
+		header := chain.header(num)
+		if header == nil {
+			err = fmt.Errorf("header %v not found", num)
+			return
+		}
+		parentVotes := []types.HeaderVote{}
+		if child != nil {
+			parentVotes = child.ParentVotes
+		}
+		child = header
+
+		ids := map[common.Hash]int{}
+		for _, share := range chain.selectedShares(header) {
+			if share.PoolId != nil && *share.PoolId == poolId {
+				ids[common.BytesToHash(share.Id())]++
+				stats.expected++
+			}
+		}
+		if len(ids) == 0 {
+			continue
+		}
+		selected[num] = ids
+
+		votes := append(append([]types.HeaderVote{}, header.CurrentVotes...), parentVotes...)
+		left := map[common.Hash]int{}
+		for id, count := range ids {
+			left[id] = count
+		}
+		for _, vote := range votes {
+			if left[vote.Id] == 0 {
+				continue
+			}
+			left[vote.Id]--
+			if vote.IsPool {
+				stats.poolVotes++
+			} else {
+				stats.soloVotes++
+			}
+		}
+	}
+	for _, vote := range chain.signedVotes(from, to) {
+		if vote.IsPool && selected[vote.Block][vote.Share] > 0 {
+			stats.signed++
+		}
+	}
+	return
+}
+
+// nextPayBlock estimates the block payIncome pays the pending income of the
+// pool at, zero when nothing is pending or no change of the pool schedules it.
+// The pay after the last one follows from LastPayTime, otherwise the first
+// change in the pay period is looked for in at most maxStatusWindow headers.
+func nextPayBlock(chain poolChain, poolId common.Hash, pool *stake.StakePool, head uint64) uint64 {
+	if pool.Income == nil || pool.Income.Sign() == 0 {
+		return 0
+	}
+	period := stake.GetPayPeriod()
+	base := pool.LastPayTime
+	if base == 0 {
+		base = pool.BlockNumber
+	}
+	if base+period > head {
+		return base + period
+	}
+	end := head
+	if head+1-period+maxStatusWindow <= head {
+		end = head - period + maxStatusWindow
+	}
+	for num := head + 1 - period; num <= end; num++ {
+		header := chain.header(num)
+		if header == nil {
+			return 0
+		}
+		if chain.poolChanged(poolId, header) {
+			return num + period
+		}
+	}
+	return 0
+}
+
+// PoolOperatorStatus reports how the pool voted in the last window blocks,
+// its pending income and its closing state. The state of its vote key is on
+// the private stake_voteKeyStatus.
+func (s *PublicStakeApI) PoolOperatorStatus(ctx context.Context, poolId common.Hash, window *hexutil.Uint64) (map[string]interface{}, error) {
+	state, header, err := s.b.StateAndHeaderByNumber(ctx, -1)
+	if err != nil {
+		return nil, err
+	}
+	pool := stake.NewStakeState(state).GetStakePool(poolId)
+	if pool == nil {
+		return nil, errors.New("stake pool not exists")
+	}
+	head := header.Number.Uint64()
+	size := uint64(defaultStatusWindow)
+	if window != nil && *window > 0 {
+		size = uint64(*window)
+	}
+	if size > maxStatusWindow {
+		size = maxStatusWindow
+	}
+	if size > head {
+		size = head
+	}
+
+	ret := map[string]interface{}{}
+	ret["id"] = poolId
+	ret["blockNumber"] = hexutil.Uint64(head)
+	ret["fee"] = hexutil.Uint(pool.Fee)
+	ret["shareNum"] = hexutil.Uint64(pool.CurrentShareNum)
+	ret["wishVoteNum"] = hexutil.Uint64(pool.WishVoteNum)
+	ret["closed"] = pool.Closed
+	ret["lockedUntil"] = hexutil.Uint64(pool.BlockNumber + stake.GetLockingBlockNum())
+	ret["canClose"] = !pool.Closed && pool.BlockNumber+stake.GetLockingBlockNum() <= head
+	if pool.Amount != nil {
+		ret["amount"] = (*hexutil.Big)(pool.Amount)
+	}
+
+	ret["voteAddress"] = PkrToString(pool.VotePKr)
+
+	chain := backendPoolChain{ctx, s.b}
+	stats, err := poolVotes(chain, poolId, head+1-size, head)
+	if err != nil {
+		return nil, err
+	}
+	missed := uint64(0)
+	if stats.expected > stats.poolVotes+stats.soloVotes {
+		missed = stats.expected - stats.poolVotes - stats.soloVotes
+	}
+	ret["window"] = hexutil.Uint64(size)
+	ret["expectedVotes"] = hexutil.Uint64(stats.expected)
+	ret["poolVotes"] = hexutil.Uint64(stats.poolVotes)
+	ret["soloVotes"] = hexutil.Uint64(stats.soloVotes)
+	ret["missedVotes"] = hexutil.Uint64(missed)
+	ret["signedVotes"] = hexutil.Uint64(stats.signed)
+
+	if pool.Income != nil {
+		ret["pendingIncome"] = (*hexutil.Big)(pool.Income)
+	}
+	if next := nextPayBlock(chain, poolId, pool, head); next > 0 {
+		ret["nextPayBlock"] = hexutil.Uint64(next)
+	}
+	ret["lastPayTime"] = hexutil.Uint64(pool.LastPayTime)
+	return ret, nil
+}
+
+// RenewLog returns the newest entries of the audit log of the share renewal
+// of the account.
+func (s *PublicStakeApI) RenewLog(ctx context.Context, pk address.PKAddress, count *hexutil.Uint64) ([]map[string]interface{}, error) {
+	service := stakeservice.CurrentStakeService()
+	if service == nil {
+		return nil, errors.New("stake service no start")
+	}
+	limit := 100
+	if count != nil && *count > 0 {
+		limit = int(*count)
+	}
+	ret := []map[string]interface{}{}
+	for _, record := range service.RenewLog(pk.ToUint512(), limit) {
+		each := map[string]interface{}{}
+		each["time"] = hexutil.Uint64(record.Time)
+		each["blockNumber"] = hexutil.Uint64(record.Block)
+		each["reason"] = record.Reason
+		if record.Amount != nil {
+			each["amount"] = (*hexutil.Big)(record.Amount)
+		}
+		each["shares"] = hexutil.Uint64(record.Shares)
+		if record.AvgPrice != nil {
+			each["avgPrice"] = (*hexutil.Big)(record.AvgPrice)
+		}
+		if record.Pool != nil {
+			each["pool"] = record.Pool
+		}
+		if record.TxHash != nil {
+			each["tx"] = common.BytesToHash(record.TxHash[:])
+		}
+		each["dryRun"] = record.DryRun
+		if record.Error != "" {
+			each["error"] = record.Error
+		}
+		ret = append(ret, each)
+	}
+	return ret, nil
+}
+
+type SimulateArgs struct {
+	Tickets  *hexutil.Uint64 `json:"tickets"`
+	Amount   *hexutil.Big    `json:"amount"`
+	Pool     *common.Hash    `json:"pool"`
+	Fee      *hexutil.Uint   `json:"fee"`
+	MissRate float64         `json:"missRate"`
+	Rounds   *hexutil.Uint64 `json:"rounds"`
+	Blocks   *hexutil.Uint64 `json:"blocks"`
+	Seed     *common.Hash    `json:"seed"`
+	PoolSize *hexutil.Uint64 `json:"poolSize"`
+}
+
+// Simulate estimates the return of buying shares, voted by a pool when the
+// pool or a fee is given. The current share pool is used unless poolSize
+// asks for a synthetic one.
+func (s *PublicStakeApI) Simulate(ctx context.Context, args SimulateArgs) (map[string]interface{}, error) {
+	state, header, err := s.b.StateAndHeaderByNumber(ctx, -1)
+	if err != nil {
+		return nil, err
+	}
+	stakeState := stake.NewStakeState(state)
+
+	params := sim.Params{
+		MissRate: args.MissRate,
+		Start:    header.Number.Uint64() + 1,
+		Seed:     header.Hash(),
+	}
+	var base stake.State = stakeState
+	if args.PoolSize != nil {
+		if *args.PoolSize > sim.MaxSyntheticSize {
+			return nil, errors.New("synthetic pool too large")
+		}
+		if base, err = sim.Synthetic(uint32(*args.PoolSize), sim.SyntheticShareSize); err != nil {
+			return nil, err
+		}
+	}
+	switch {
+	case args.Tickets != nil:
+		params.Tickets = uint32(*args.Tickets)
+		if args.PoolSize == nil {
+			params.Cost = stakeState.SumAmount(int64(params.Tickets))
+		}
+	case args.Amount != nil:
+		if args.PoolSize != nil {
+			return nil, errors.New("a synthetic pool needs tickets")
+		}
+		params.Tickets, _, _ = stakeState.CaleAvgPrice(args.Amount.ToInt())
+		params.Cost = stakeState.SumAmount(int64(params.Tickets))
+	default:
+		return nil, errors.New("tickets or amount required")
+	}
+	if args.Pool != nil {
+		pool := stakeState.GetStakePool(*args.Pool)
+		if pool == nil {
+			return nil, errors.New("stake pool not exists")
+		}
+		params.Pool, params.Fee = true, pool.Fee
+	}
+	if args.Fee != nil {
+		if *args.Fee > 10000 {
+			return nil, errors.New("pool fee must not exceed 10000")
+		}
+		params.Pool, params.Fee = true, uint16(*args.Fee)
+	}
+	if args.Rounds != nil {
+		params.Rounds = int(*args.Rounds)
+	}
+	if args.Blocks != nil {
+		params.Blocks = uint64(*args.Blocks)
+	}
+	if args.Seed != nil {
+		params.Seed = *args.Seed
+	}
+
+	result, err := sim.Simulate(ctx, base, params)
+	if err != nil {
+		return nil, err
+	}
+	soloReward, reward := stakeState.StakeCurrentReward(header.Number)
+	ret := map[string]interface{}{}
+	ret["blockNumber"] = hexutil.Uint64(header.Number.Uint64())
+	ret["soloReward"] = (*hexutil.Big)(soloReward)
+	ret["poolReward"] = (*hexutil.Big)(reward)
+	ret["rounds"] = hexutil.Uint64(result.Rounds)
+	ret["poolSize"] = hexutil.Uint64(result.PoolSize)
+	ret["tickets"] = hexutil.Uint64(result.Tickets)
+	ret["fee"] = hexutil.Uint(params.Fee)
+	ret["isPool"] = params.Pool
+	ret["cost"] = (*hexutil.Big)(result.Cost)
+	ret["expectedReward"] = (*hexutil.Big)(result.ExpectedReward)
+	ret["minReward"] = (*hexutil.Big)(result.MinReward)
+	ret["maxReward"] = (*hexutil.Big)(result.MaxReward)
+	ret["return"] = result.Return
+	ret["variance"] = result.Variance
+	ret["missProbability"] = result.MissProbability
+	ret["expireRate"] = result.ExpireRate
+	ret["limits"] = result.Limits
+	return ret, nil
+}
+
+// operatorPool returns the account and the pool of the operator address.
+func (s *PublicStakeApI) operatorPool(ctx context.Context, from address.MixBase58Adrress) (account accounts.Account, pkr c_type.PKr, pool *stake.StakePool, e error) {
+	account, e = s.b.AccountManager().FindAccountByPkr(from.ToPkr())
+	if e != nil {
+		return
+	}
+	if from.IsPkr() {
+		pkr = from.ToPkr()
+	} else {
+		pkr = getStakePoolPkr(account)
+	}
+	state, _, err := s.b.StateAndHeaderByNumber(ctx, -1)
+	if err != nil {
+		e = err
+		return
+	}
+	pool = stake.NewStakeState(state).GetStakePool(getStakePoolId(pkr))
+	if pool == nil {
+		e = errors.New("stake pool not exists")
+		return
+	}
+	if pool.Closed {
+		e = errors.New("stake pool has closed")
+	}
+	return
+}
+
+func (s *PublicStakeApI) schedulePoolChange(ctx context.Context, from address.MixBase58Adrress, at hexutil.Uint64, fee *uint32, vote *c_type.PKr) (map[string]interface{}, error) {
+	service := stakeservice.CurrentStakeService()
+	if service == nil {
+		return nil, errors.New("stake service no start")
+	}
+	account, pkr, pool, err := s.operatorPool(ctx, from)
+	if err != nil {
+		return nil, err
+	}
+	change, err := service.SchedulePoolChange(stakeservice.ScheduledChange{
+		PoolId: common.BytesToHash(pool.Id()),
+		Pk:     account.Address.ToUint512(),
+		PKr:    pkr,
+		At:     uint64(at),
+		Fee:    fee,
+		Vote:   vote,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newRPCScheduledChange(*change), nil
+}
+
+// SchedulePoolFee changes the fee rate of the pool of the operator at the
+// block, the subscribers of poolChanges learn it now.
+func (s *PublicStakeApI) SchedulePoolFee(ctx context.Context, from address.MixBase58Adrress, fee hexutil.Uint64, at hexutil.Uint64) (map[string]interface{}, error) {
+	if uint32(fee) < seroparam.LOWEST_STAKING_NODE_FEE_RATE {
+		return nil, errors.New(fmt.Sprintf("fee rate can not less then %v", seroparam.LOWEST_STAKING_NODE_FEE_RATE))
+	}
+	if uint32(fee) > seroparam.HIGHEST_STAKING_NODE_FEE_RATE {
+		return nil, errors.New(fmt.Sprintf("fee rate can not large then  %v", seroparam.HIGHEST_STAKING_NODE_FEE_RATE))
+	}
+	rate := uint32(fee)
+	return s.schedulePoolChange(ctx, from, at, &rate, nil)
+}
+
+// SchedulePoolVote changes the vote PKr of the pool of the operator at the
+// block.
+func (s *PublicStakeApI) SchedulePoolVote(ctx context.Context, from address.MixBase58Adrress, vote address.MixBase58Adrress, at hexutil.Uint64) (map[string]interface{}, error) {
+	votePkr := vote.ToPkr()
+	if !superzk.IsPKrValid(&votePkr) {
+		return nil, errors.New("invalid vote address")
+	}
+	return s.schedulePoolChange(ctx, from, at, nil, &votePkr)
+}
+
+// CancelPoolChange drops the pending change of the pool of the operator at
+// the block.
+func (s *PublicStakeApI) CancelPoolChange(ctx context.Context, from address.MixBase58Adrress, at hexutil.Uint64) error {
+	service := stakeservice.CurrentStakeService()
+	if service == nil {
+		return errors.New("stake service no start")
+	}
+	account, err := s.b.AccountManager().FindAccountByPkr(from.ToPkr())
+	if err != nil {
+		return err
+	}
+	pkr := from.ToPkr()
+	if !from.IsPkr() {
+		pkr = getStakePoolPkr(account)
+	}
+	return service.CancelPoolChange(getStakePoolId(pkr), uint64(at))
+}
+
+func newRPCScheduledChange(change stakeservice.ScheduledChange) map[string]interface{} {
+	ret := map[string]interface{}{}
+	ret["poolId"] = change.PoolId
+	ret["at"] = hexutil.Uint64(change.At)
+	if change.Fee != nil {
+		ret["fee"] = hexutil.Uint64(*change.Fee)
+	}
+	if change.Vote != nil {
+		ret["voteAddress"] = PkrToString(*change.Vote)
+	}
+	ret["status"] = change.Status
+	if change.TxHash != nil {
+		ret["tx"] = change.TxHash
+	}
+	if change.Error != "" {
+		ret["error"] = change.Error
+	}
+	return ret
+}
+
+func newRPCPoolChange(change stakeservice.PoolChange) map[string]interface{} {
+	ret := map[string]interface{}{}
+	ret["blockNumber"] = hexutil.Uint64(change.Block)
+	if change.TxHash != nil {
+		ret["tx"] = change.TxHash
+	}
+	if change.OldFee != change.NewFee {
+		ret["oldFee"] = hexutil.Uint64(change.OldFee)
+		ret["newFee"] = hexutil.Uint64(change.NewFee)
+	}
+	if change.OldVote != change.NewVote {
+		ret["oldVoteAddress"] = PkrToString(change.OldVote)
+		ret["newVoteAddress"] = PkrToString(change.NewVote)
+	}
+	return ret
+}
+
+// ScheduledPoolChanges returns the changes scheduled for the pool on this
+// node.
+func (s *PublicStakeApI) ScheduledPoolChanges(ctx context.Context, poolId common.Hash) ([]map[string]interface{}, error) {
+	service := stakeservice.CurrentStakeService()
+	if service == nil {
+		return nil, errors.New("stake service no start")
+	}
+	ret := []map[string]interface{}{}
+	for _, change := range service.ScheduledChanges(poolId) {
+		ret = append(ret, newRPCScheduledChange(change))
+	}
+	return ret, nil
+}
+
+// PoolChangeHistory returns the fee rate and vote address changes of the
+// pool seen by the stake index.
+func (s *PublicStakeApI) PoolChangeHistory(ctx context.Context, poolId common.Hash) ([]map[string]interface{}, error) {
+	service := stakeservice.CurrentStakeService()
+	if service == nil {
+		return nil, errors.New("stake service no start")
+	}
+	ret := []map[string]interface{}{}
+	for _, change := range service.PoolChangeHistory(poolId) {
+		ret = append(ret, newRPCPoolChange(change))
+	}
+	return ret, nil
+}
+
+// PoolChanges streams the scheduled and applied changes of the pools, all
+// pools when none is given, stake_subscribe("poolChanges", [poolIds]).
+func (s *PublicStakeApI) PoolChanges(ctx context.Context, poolIds []common.Hash) (*rpc.Subscription, error) {
+	service := stakeservice.CurrentStakeService()
+	if service == nil {
+		return nil, errors.New("stake service no start")
+	}
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		events := make(chan stakeservice.PoolChangeEvent, 16)
+		sub := service.SubscribePoolChanges(events)
+
+		for {
+			select {
+			case ev := <-events:
+				if len(poolIds) > 0 && !containsHash(poolIds, ev.PoolId) {
+					continue
+				}
+				ret := map[string]interface{}{}
+				ret["type"] = ev.Type
+				ret["poolId"] = ev.PoolId
+				if ev.Scheduled != nil {
+					ret["scheduled"] = newRPCScheduledChange(*ev.Scheduled)
+				}
+				if ev.Change != nil {
+					ret["change"] = newRPCPoolChange(*ev.Change)
+				}
+				notifier.Notify(rpcSub.ID, ret)
+			case <-rpcSub.Err():
+				sub.Unsubscribe()
+				return
+			case <-notifier.Closed():
+				sub.Unsubscribe()
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
+
+// Reindex indexes the shares of pk again from fromBlock on in the background.
+func (s *PublicStakeApI) Reindex(ctx context.Context, pk address.PKAddress, fromBlock hexutil.Uint64) error {
+	service := stakeservice.CurrentStakeService()
+	if service == nil {
+		return errors.New("stake service no start")
+	}
+	return service.Reindex(pk.ToUint512(), uint64(fromBlock))
+}
@@ -0,0 +1,110 @@
+package ethapi
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/core/types"
+	"github.com/sero-cash/go-sero/voter"
+	"github.com/sero-cash/go-sero/zero/stake"
+)
+
+type testPoolChain struct {
+	headers map[uint64]*types.Header
+	shares  map[uint64][]*stake.Share
+	changed map[uint64]bool
+	signed  []voter.SignedVote
+}
+
+func (self *testPoolChain) header(num uint64) *types.Header {
+	return self.headers[num]
+}
+
+func (self *testPoolChain) selectedShares(header *types.Header) []*stake.Share {
+	return self.shares[header.Number.Uint64()]
+}
+
+func (self *testPoolChain) poolChanged(poolId common.Hash, header *types.Header) bool {
+	return self.changed[header.Number.Uint64()]
+}
+
+func (self *testPoolChain) signedVotes(from, to uint64) []voter.SignedVote {
+	return self.signed
+}
+
+func TestPoolVotes(t *testing.T) {
+	poolId, otherId := common.Hash{1}, common.Hash{2}
+	a := &stake.Share{PoolId: &poolId, Value: big.NewInt(1)}
+	b := &stake.Share{PoolId: &poolId, Value: big.NewInt(2)}
+	other := &stake.Share{PoolId: &otherId, Value: big.NewInt(3)}
+	solo := &stake.Share{Value: big.NewInt(4)}
+	idOf := func(share *stake.Share) common.Hash {
+		return common.BytesToHash(share.Id())
+	}
+
+	chain := &testPoolChain{headers: map[uint64]*types.Header{}, shares: map[uint64][]*stake.Share{}}
+	for num := uint64(1); num <= 4; num++ {
+		chain.headers[num] = &types.Header{Number: new(big.Int).SetUint64(num)}
+	}
+	// block 2 selects a, b and shares of others, a votes by the pool at 2
+	// and b alone at 3; block 3 selects a again, which no one votes for
+	chain.shares[2] = []*stake.Share{a, b, other, solo}
+	chain.shares[3] = []*stake.Share{a}
+	chain.headers[2].CurrentVotes = []types.HeaderVote{{Id: idOf(a), IsPool: true}, {Id: idOf(other), IsPool: true}}
+	chain.headers[3].ParentVotes = []types.HeaderVote{{Id: idOf(b)}, {Id: idOf(b)}}
+	chain.signed = []voter.SignedVote{
+		{Block: 2, Share: idOf(a), IsPool: true},
+		{Block: 3, Share: idOf(a), IsPool: true},
+		{Block: 2, Share: idOf(other), IsPool: true},
+		{Block: 4, Share: idOf(a), IsPool: true},
+	}
+
+	stats, err := poolVotes(chain, poolId, 1, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.expected != 3 || stats.poolVotes != 1 || stats.soloVotes != 1 || stats.signed != 2 {
+		t.Fatalf("stats %+v", stats)
+	}
+
+	delete(chain.headers, 1)
+	if _, err := poolVotes(chain, poolId, 1, 3); err == nil {
+		t.Fatal("missing header not reported")
+	}
+}
+
+func TestNextPayBlock(t *testing.T) {
+	poolId := common.Hash{1}
+	period := stake.GetPayPeriod()
+	head := 3 * period
+	chain := &testPoolChain{headers: map[uint64]*types.Header{}, changed: map[uint64]bool{}}
+	for num := head - period; num <= head; num++ {
+		chain.headers[num] = &types.Header{Number: new(big.Int).SetUint64(num)}
+	}
+
+	pool := &stake.StakePool{BlockNumber: 10}
+	if next := nextPayBlock(chain, poolId, pool, head); next != 0 {
+		t.Fatalf("next pay at %v without income", next)
+	}
+	pool.Income = big.NewInt(1)
+	pool.LastPayTime = head - 10
+	if next := nextPayBlock(chain, poolId, pool, head); next != head-10+period {
+		t.Fatalf("next pay at %v, want %v after the last", next, head-10+period)
+	}
+	pool.LastPayTime = 0
+	if next := nextPayBlock(chain, poolId, pool, head); next != 0 {
+		t.Fatalf("next pay at %v of an unchanged pool", next)
+	}
+	start := head + 1 - period
+	chain.changed[start+3] = true
+	if next := nextPayBlock(chain, poolId, pool, head); next != start+3+period {
+		t.Fatalf("next pay at %v, want %v after the change", next, start+3+period)
+	}
+	// the scan stops after maxStatusWindow headers
+	delete(chain.changed, start+3)
+	chain.changed[start+maxStatusWindow] = true
+	if next := nextPayBlock(chain, poolId, pool, head); next != 0 {
+		t.Fatalf("next pay at %v after a change beyond the scan", next)
+	}
+}
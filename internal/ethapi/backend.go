@@ -43,6 +43,7 @@ import (
 	"github.com/sero-cash/go-sero/rpc"
 	"github.com/sero-cash/go-sero/sero/downloader"
 	"github.com/sero-cash/go-sero/serodb"
+	"github.com/sero-cash/go-sero/voter"
 	"github.com/sero-cash/go-sero/zero/wallet/light"
 )
 
@@ -54,6 +55,7 @@ type Backend interface {
 	ProtocolVersion() int
 	PeerCount() uint
 	SuggestPrice(ctx context.Context) (*big.Int, error)
+	SuggestPrices(ctx context.Context, percentiles []int) ([]*big.Int, error)
 	ChainDb() serodb.Database
 	EventMux() *event.TypeMux
 	AccountManager() *accounts.Manager
@@ -84,6 +86,7 @@ type Backend interface {
 	CurrentBlock() *types.Block
 	GetEngin() consensus.Engine
 	GetMiner() *miner.Miner
+	GetVoter() *voter.Voter
 
 	GetBlocksInfo(start uint64, count uint64) ([]txtool.Block, error)
 	GetAnchor(roots []c_type.Uint256) ([]txtool.Witness, error)
@@ -102,6 +105,8 @@ type Backend interface {
 	//Light node api
 	GetOutByPKr(pkrs []c_type.PKr, start, end uint64) (br light.BlockOutResp, e error)
 	CheckNil(Nils []c_type.Uint256) (nilResps []light.NilValue, e error)
+	GetFilters(start, count uint64) (filters []light.BlockFilter, e error)
+	GetBlockOuts(num uint64) (outs light.BlockOuts, e error)
 }
 
 func GetAPIs(apiBackend Backend) []rpc.API {
@@ -119,6 +124,11 @@ func GetAPIs(apiBackend Backend) []rpc.API {
 			Service:   NewPublicStakeApI(apiBackend, nonceLock),
 			Public:    true,
 		},
+		{
+			Namespace: "stake",
+			Version:   "1.0",
+			Service:   NewPrivateStakeAPI(apiBackend),
+		},
 		{
 			Namespace: "sero",
 			Version:   "1.0",
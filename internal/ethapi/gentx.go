@@ -184,6 +184,7 @@ type GenTxArgs struct {
 	Gas        uint64
 	GasPrice   *Big
 	Roots      []c_type.Uint256
+	Selector   string
 }
 
 func (args GenTxArgs) check() error {
@@ -208,6 +209,10 @@ func (args GenTxArgs) check() error {
 		}
 	}
 
+	if _, err := prepare.GetCoinSelector(args.Selector); err != nil {
+		return err
+	}
+
 	for _, rec := range args.Receptions {
 		_, err := validAddress(rec.Addr)
 		if err != nil {
@@ -242,6 +247,7 @@ func (args GenTxArgs) toTxParam() prepare.PreTxParam {
 				Currency: currency,
 				Value:    utils.U256(*rec.Value.ToInt())},
 			},
+			rec.Memo,
 		})
 	}
 	var refundPkr *c_type.PKr
@@ -263,5 +269,6 @@ func (args GenTxArgs) toTxParam() prepare.PreTxParam {
 		},
 		gasPrice,
 		args.Roots,
+		args.Selector,
 	}
 }
@@ -486,6 +486,11 @@ web3._extend({
             call: 'sero_getShortAddress',	
 			params: 1
 		}),
+		new web3._extend.Method({
+			name: 'estimateTxFee',
+			call: 'sero_estimateTxFee',
+			params: 1
+		}),
 	],
 	properties: [
 		new web3._extend.Property({
@@ -834,6 +839,54 @@ web3._extend({
 			name: 'ignorePkrUtxos',
 			call: 'exchange_ignorePkrUtxos',
 			params: 2
+		}),
+		new web3._extend.Method({
+			name: 'compareSelectors',
+			call: 'exchange_compareSelectors',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'batchPay',
+			call: 'exchange_batchPay',
+			params: 1
+		}),
+       new web3._extend.Method({
+			name: 'getPendingTxs',
+			call: 'exchange_getPendingTxs',
+			params: 1,
+			inputFormatter: [null]
+		}),
+       new web3._extend.Method({
+			name: 'addWatchTk',
+			call: 'exchange_addWatchTk',
+			params: 2,
+			inputFormatter: [null, null]
+		}),
+       new web3._extend.Method({
+			name: 'removeWatchTk',
+			call: 'exchange_removeWatchTk',
+			params: 1
+		}),
+       new web3._extend.Method({
+			name: 'getWatchAccounts',
+			call: 'exchange_getWatchAccounts',
+			params: 0
+		}),
+       new web3._extend.Method({
+			name: 'rescan',
+			call: 'exchange_rescan',
+			params: 2
+		}),
+       new web3._extend.Method({
+			name: 'getTxHistory',
+			call: 'exchange_getTxHistory',
+			params: 3
+		}),
+       new web3._extend.Method({
+			name: 'mergeStatus',
+			call: 'exchange_mergeStatus',
+			params: 1,
+			inputFormatter: [null]
 		})
 	]
 });
@@ -912,6 +965,80 @@ web3._extend({
 			params:3,
             inputFormatter: [null,web3._extend.utils.toHex,web3._extend.utils.toHex],
             outputFormatter: web3._extend.formatters.outputStakeInfoFormatter
+		}),
+        new web3._extend.Method({
+			name: 'rewardHistory',
+			call: 'stake_rewardHistory',
+			params:3,
+            inputFormatter: [null,web3._extend.utils.toHex,web3._extend.utils.toHex]
+		}),
+        new web3._extend.Method({
+			name: 'poolStats',
+			call: 'stake_poolStats',
+			params:2,
+            inputFormatter: [null,null]
+		}),
+        new web3._extend.Method({
+			name: 'poolOperatorStatus',
+			call: 'stake_poolOperatorStatus',
+			params:2,
+            inputFormatter: [null,web3._extend.utils.toHex]
+		}),
+        new web3._extend.Method({
+			name: 'voteKeyStatus',
+			call: 'stake_voteKeyStatus',
+			params:1
+		}),
+        new web3._extend.Method({
+			name: 'renewLog',
+			call: 'stake_renewLog',
+			params:2,
+            inputFormatter: [null,web3._extend.utils.toHex]
+		}),
+        new web3._extend.Method({
+			name: 'simulate',
+			call: 'stake_simulate',
+			params:1
+		}),
+        new web3._extend.Method({
+			name: 'schedulePoolFee',
+			call: 'stake_schedulePoolFee',
+			params:3,
+            inputFormatter: [null,web3._extend.utils.toHex,web3._extend.utils.toHex]
+		}),
+        new web3._extend.Method({
+			name: 'schedulePoolVote',
+			call: 'stake_schedulePoolVote',
+			params:3,
+            inputFormatter: [null,null,web3._extend.utils.toHex]
+		}),
+        new web3._extend.Method({
+			name: 'cancelPoolChange',
+			call: 'stake_cancelPoolChange',
+			params:2,
+            inputFormatter: [null,web3._extend.utils.toHex]
+		}),
+        new web3._extend.Method({
+			name: 'scheduledPoolChanges',
+			call: 'stake_scheduledPoolChanges',
+			params:1
+		}),
+        new web3._extend.Method({
+			name: 'poolChangeHistory',
+			call: 'stake_poolChangeHistory',
+			params:1
+		}),
+        new web3._extend.Method({
+			name: 'reindex',
+			call: 'stake_reindex',
+			params:2,
+			inputFormatter: [null, web3._extend.utils.fromDecimal]
+		}),
+        new web3._extend.Method({
+			name: 'proveShare',
+			call: 'stake_proveShare',
+			params:2,
+			inputFormatter: [null, web3._extend.utils.fromDecimal]
 		})
 
 	],
@@ -944,6 +1071,16 @@ web3._extend({
 			call: 'light_checkNil',
 			params: 1
 		}),
+		new web3._extend.Method({
+			name: 'getFilters',
+			call: 'light_getFilters',
+			params: 2
+		}),
+		new web3._extend.Method({
+			name: 'getBlockOuts',
+			call: 'light_getBlockOuts',
+			params: 1
+		}),
 	]
 });
 `
@@ -313,6 +313,9 @@ func (s *Server) handle(ctx context.Context, codec ServerCodec, req *serverReque
 	if req.callb.errPos >= 0 { // test if method returned an error
 		if !reply[req.callb.errPos].IsNil() {
 			e := reply[req.callb.errPos].Interface().(error)
+			if de, ok := e.(DataError); ok {
+				return codec.CreateErrorResponseWithInfo(&req.id, &callbackError{e.Error()}, de.ErrorData()), nil
+			}
 			res := codec.CreateErrorResponse(&req.id, &callbackError{e.Error()})
 			return res, nil
 		}
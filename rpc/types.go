@@ -92,6 +92,13 @@ type Error interface {
 	ErrorCode() int // returns the code
 }
 
+// DataError is an error returned by a callback that carries data for the
+// caller, it is sent in the data field of the error response.
+type DataError interface {
+	Error() string          // returns the message
+	ErrorData() interface{} // returns the error data
+}
+
 // ServerCodec implements reading, parsing and writing RPC messages for the server side of
 // a RPC session. Implementations must be go-routine safe since the codec can be called in
 // multiple go-routines concurrently.
@@ -49,6 +49,7 @@ import (
 	"github.com/sero-cash/go-sero/sero/downloader"
 	"github.com/sero-cash/go-sero/sero/gasprice"
 	"github.com/sero-cash/go-sero/serodb"
+	"github.com/sero-cash/go-sero/voter"
 	"github.com/sero-cash/go-sero/zero/wallet/light"
 )
 
@@ -75,6 +76,10 @@ func (b *SeroAPIBackend) GetMiner() *miner.Miner {
 	return b.sero.miner
 }
 
+func (b *SeroAPIBackend) GetVoter() *voter.Voter {
+	return b.sero.voter
+}
+
 func (b *SeroAPIBackend) SetHead(number uint64) {
 	b.sero.protocolManager.downloader.Cancel()
 	b.sero.blockchain.SetHead(number, core.DelFn)
@@ -232,6 +237,10 @@ func (b *SeroAPIBackend) SuggestPrice(ctx context.Context) (*big.Int, error) {
 	return b.gpo.SuggestPrice(ctx)
 }
 
+func (b *SeroAPIBackend) SuggestPrices(ctx context.Context, percentiles []int) ([]*big.Int, error) {
+	return b.gpo.SuggestPrices(ctx, percentiles)
+}
+
 func (b *SeroAPIBackend) ChainDb() serodb.Database {
 	return b.sero.ChainDb()
 }
@@ -355,3 +364,19 @@ func (b *SeroAPIBackend) CheckNil(Nils []c_type.Uint256) (nilResps []light.NilVa
 	}
 	return b.sero.lightNode.CheckNil(Nils)
 }
+
+func (b *SeroAPIBackend) GetFilters(start, count uint64) (filters []light.BlockFilter, e error) {
+	if b.sero.lightNode == nil {
+		e = errors.New("not start light")
+		return
+	}
+	return b.sero.lightNode.GetFilters(start, count)
+}
+
+func (b *SeroAPIBackend) GetBlockOuts(num uint64) (outs light.BlockOuts, e error) {
+	if b.sero.lightNode == nil {
+		e = errors.New("not start light")
+		return
+	}
+	return b.sero.lightNode.GetBlockOuts(num)
+}
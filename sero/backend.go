@@ -182,6 +182,17 @@ func New(ctx *node.ServiceContext, config *Config) (*Sero, error) {
 	sero.txPool = core.NewTxPool(config.TxPool, sero.chainConfig, sero.blockchain)
 
 	sero.voter = voter.NewVoter(sero.chainConfig, sero.blockchain, sero)
+	if err := sero.voter.SetAlert(config.Voter.Alert); err != nil {
+		return nil, err
+	}
+	if config.Voter.SignerURL != "" {
+		signer, err := voter.DialRemoteSigner(config.Voter.SignerURL, config.Voter.SignerToken)
+		if err != nil {
+			return nil, err
+		}
+		sero.voter.SetSigner(signer)
+		log.Info("Votes are signed remotely", "signer", config.Voter.SignerURL)
+	}
 
 	if sero.protocolManager, err = NewProtocolManager(sero.chainConfig, config.SyncMode, config.NetworkId, sero.eventMux, sero.voter, sero.txPool, sero.engine, sero.blockchain, chainDb); err != nil {
 		return nil, err
@@ -200,11 +211,16 @@ func New(ctx *node.ServiceContext, config *Config) (*Sero, error) {
 
 	// init exchange
 	if config.StartExchange {
-		sero.exchange = exchange.NewExchange(zconfig.Exchange_dir(), sero.txPool, sero.accountManager, config.AutoMerge)
+		if sero.exchange, err = exchange.NewExchange(zconfig.Exchange_dir(), sero.txPool, sero.accountManager, config.AutoMerge, config.Exchange); err != nil {
+			return nil, err
+		}
 	}
 
 	if config.StartStake {
-		stakeservice.NewStakeService(zconfig.Stake_dir(), sero.blockchain, sero.accountManager)
+		if err := config.Stake.Validate(); err != nil {
+			return nil, err
+		}
+		stakeservice.NewStakeService(zconfig.Stake_dir(), sero.blockchain, sero.accountManager, config.Stake)
 	}
 
 	// init light
@@ -471,6 +487,9 @@ func (s *Sero) Stop() error {
 	}
 	s.txPool.Stop()
 	s.miner.Stop()
+	if s.exchange != nil {
+		s.exchange.Stop()
+	}
 	s.eventMux.Stop()
 
 	s.chainDb.Close()
@@ -24,7 +24,10 @@ import (
 	"runtime"
 	"time"
 
+	"github.com/sero-cash/go-sero/voter"
 	"github.com/sero-cash/go-sero/zero/proofservice"
+	"github.com/sero-cash/go-sero/zero/wallet/exchange"
+	"github.com/sero-cash/go-sero/zero/wallet/stakeservice"
 
 	"github.com/sero-cash/go-sero/common/hexutil"
 	"github.com/sero-cash/go-sero/consensus/ethash"
@@ -115,6 +118,15 @@ type Config struct {
 
 	Proof *proofservice.Config
 
+	// Exchange options
+	Exchange exchange.Config
+
+	// Voter options
+	Voter voter.Config
+
+	// Stake service options
+	Stake stakeservice.Config
+
 	// Gas Price Oracle options
 	GPO gasprice.Config
 
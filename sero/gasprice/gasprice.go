@@ -18,6 +18,7 @@ package gasprice
 
 import (
 	"context"
+	"errors"
 	"math/big"
 	"sort"
 	"sync"
@@ -99,7 +100,57 @@ func (gpo *Oracle) SuggestPrice(ctx context.Context) (*big.Int, error) {
 		return lastPrice, nil
 	}
 
-	blockNum := head.Number.Uint64()
+	blockPrices, err := gpo.recentPrices(ctx, head.Number.Uint64())
+	if err != nil {
+		return lastPrice, err
+	}
+	price := lastPrice
+	if len(blockPrices) > 0 {
+		price = blockPrices[(len(blockPrices)-1)*gpo.percentile/100]
+	}
+	if price.Cmp(maxPrice) > 0 {
+		price = new(big.Int).Set(maxPrice)
+	}
+
+	gpo.cacheLock.Lock()
+	gpo.lastHead = headHash
+	gpo.lastPrice = price
+	gpo.cacheLock.Unlock()
+	return price, nil
+}
+
+// SuggestPrices returns a gas price for each of the percentiles of the lowest
+// prices recent blocks included, the default price when there are none.
+func (gpo *Oracle) SuggestPrices(ctx context.Context, percentiles []int) ([]*big.Int, error) {
+	head, _ := gpo.backend.HeaderByNumber(ctx, rpc.LatestBlockNumber)
+	if head == nil {
+		return nil, errors.New("no head block")
+	}
+	blockPrices, err := gpo.recentPrices(ctx, head.Number.Uint64())
+	if err != nil {
+		return nil, err
+	}
+	gpo.cacheLock.RLock()
+	lastPrice := gpo.lastPrice
+	gpo.cacheLock.RUnlock()
+
+	prices := make([]*big.Int, len(percentiles))
+	for i, percentile := range percentiles {
+		price := lastPrice
+		if len(blockPrices) > 0 {
+			price = blockPrices[(len(blockPrices)-1)*percentile/100]
+		}
+		if price.Cmp(maxPrice) > 0 {
+			price = maxPrice
+		}
+		prices[i] = new(big.Int).Set(price)
+	}
+	return prices, nil
+}
+
+// recentPrices collects the lowest price of the blocks below blockNum in
+// ascending order, skipping up to maxEmpty empty blocks.
+func (gpo *Oracle) recentPrices(ctx context.Context, blockNum uint64) ([]*big.Int, error) {
 	ch := make(chan getBlockPricesResult, gpo.checkBlocks)
 	sent := 0
 	exp := 0
@@ -114,7 +165,7 @@ func (gpo *Oracle) SuggestPrice(ctx context.Context) (*big.Int, error) {
 	for exp > 0 {
 		res := <-ch
 		if res.err != nil {
-			return lastPrice, res.err
+			return nil, res.err
 		}
 		exp--
 		if res.price != nil {
@@ -132,20 +183,8 @@ func (gpo *Oracle) SuggestPrice(ctx context.Context) (*big.Int, error) {
 			blockNum--
 		}
 	}
-	price := lastPrice
-	if len(blockPrices) > 0 {
-		sort.Sort(bigIntArray(blockPrices))
-		price = blockPrices[(len(blockPrices)-1)*gpo.percentile/100]
-	}
-	if price.Cmp(maxPrice) > 0 {
-		price = new(big.Int).Set(maxPrice)
-	}
-
-	gpo.cacheLock.Lock()
-	gpo.lastHead = headHash
-	gpo.lastPrice = price
-	gpo.cacheLock.Unlock()
-	return price, nil
+	sort.Sort(bigIntArray(blockPrices))
+	return blockPrices, nil
 }
 
 type getBlockPricesResult struct {
@@ -11,7 +11,10 @@ import (
 	"github.com/sero-cash/go-sero/core"
 	"github.com/sero-cash/go-sero/sero/downloader"
 	"github.com/sero-cash/go-sero/sero/gasprice"
+	"github.com/sero-cash/go-sero/voter"
 	"github.com/sero-cash/go-sero/zero/proofservice"
+	"github.com/sero-cash/go-sero/zero/wallet/exchange"
+	"github.com/sero-cash/go-sero/zero/wallet/stakeservice"
 )
 
 var _ = (*configMarshaling)(nil)
@@ -40,6 +43,9 @@ func (c Config) MarshalTOML() (interface{}, error) {
 		Ethash                  ethash.Config
 		TxPool                  core.TxPoolConfig
 		Proof                   *proofservice.Config
+		Exchange                exchange.Config
+		Voter                   voter.Config
+		Stake                   stakeservice.Config
 		GPO                     gasprice.Config
 		EnablePreimageRecording bool
 		DocRoot                 string `toml:"-"`
@@ -66,6 +72,9 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.Ethash = c.Ethash
 	enc.TxPool = c.TxPool
 	enc.Proof = c.Proof
+	enc.Exchange = c.Exchange
+	enc.Voter = c.Voter
+	enc.Stake = c.Stake
 	enc.GPO = c.GPO
 	enc.EnablePreimageRecording = c.EnablePreimageRecording
 	enc.DocRoot = c.DocRoot
@@ -96,6 +105,9 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 		Ethash                  *ethash.Config
 		TxPool                  *core.TxPoolConfig
 		Proof                   *proofservice.Config
+		Exchange                *exchange.Config
+		Voter                   *voter.Config
+		Stake                   *stakeservice.Config
 		GPO                     *gasprice.Config
 		EnablePreimageRecording *bool
 		DocRoot                 *string `toml:"-"`
@@ -167,6 +179,15 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.Proof != nil {
 		c.Proof = dec.Proof
 	}
+	if dec.Exchange != nil {
+		c.Exchange = *dec.Exchange
+	}
+	if dec.Voter != nil {
+		c.Voter = *dec.Voter
+	}
+	if dec.Stake != nil {
+		c.Stake = *dec.Stake
+	}
 	if dec.GPO != nil {
 		c.GPO = *dec.GPO
 	}
@@ -0,0 +1,147 @@
+package sero
+
+import (
+	"sync"
+	"time"
+
+	mapset "github.com/deckarep/golang-set"
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/core/types"
+	"github.com/sero-cash/go-sero/metrics"
+	"github.com/sero-cash/go-sero/voter"
+)
+
+const (
+	// A peer may relay maxGossipPerWindow lotteries and votes per window,
+	// far more than the three votes and one lottery of a block.
+	gossipWindow       = time.Second
+	maxGossipPerWindow = 64
+
+	// The penalties add up to the score of the peer, which halves every
+	// gossipDecay. The peer is dropped when it reaches gossipDropScore.
+	gossipDecay       = time.Minute
+	gossipDropScore   = 100
+	penaltyFlood      = 1
+	penaltyStale      = 1
+	penaltyDuplicate  = 2
+	penaltyInvalid    = 10
+	maxGossipHalvings = 32
+)
+
+var (
+	gossipDroppedMeter = metrics.NewRegisteredMeter("sero/gossip/dropped", nil)
+	gossipPenaltyMeter = metrics.NewRegisteredMeter("sero/gossip/penalty", nil)
+	gossipBannedMeter  = metrics.NewRegisteredMeter("sero/gossip/banned", nil)
+)
+
+// gossipScore accounts the lotteries and votes a peer relays.
+type gossipScore struct {
+	lock    sync.Mutex
+	score   int
+	decayed time.Time
+	window  time.Time
+	count   int
+
+	// the votes and lotteries the peer sent, unlike the known sets they do
+	// not hold what was sent to the peer
+	votes    mapset.Set
+	lotterys mapset.Set
+}
+
+func newGossipScore() *gossipScore {
+	now := time.Now()
+	return &gossipScore{decayed: now, window: now, votes: mapset.NewSet(), lotterys: mapset.NewSet()}
+}
+
+// received records the hash sent by the peer and reports whether it sent it
+// before.
+func received(set mapset.Set, hash common.Hash, max int) bool {
+	if set.Contains(hash) {
+		return true
+	}
+	for set.Cardinality() >= max {
+		set.Pop()
+	}
+	set.Add(hash)
+	return false
+}
+
+// allow counts a message against the rate of the peer.
+func (self *gossipScore) allow(now time.Time) bool {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if now.Sub(self.window) >= gossipWindow {
+		self.window, self.count = now, 0
+	}
+	self.count++
+	return self.count <= maxGossipPerWindow
+}
+
+// penalize adds the penalty and reports whether the peer must be dropped.
+func (self *gossipScore) penalize(penalty int, now time.Time) bool {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if halvings := now.Sub(self.decayed) / gossipDecay; halvings > 0 {
+		if halvings > maxGossipHalvings {
+			halvings = maxGossipHalvings
+		}
+		self.score >>= uint(halvings)
+		self.decayed = now
+	}
+	self.score += penalty
+	gossipPenaltyMeter.Mark(int64(penalty))
+	return self.score >= gossipDropScore
+}
+
+func gossipPenalty(err error) int {
+	switch err {
+	case nil, voter.ErrVoteKnown, voter.ErrLotteryKnown, voter.ErrFuture:
+		return 0
+	case voter.ErrStale:
+		return penaltyStale
+	default:
+		return penaltyInvalid
+	}
+}
+
+// score applies the penalty, an error drops the peer.
+func (pm *ProtocolManager) score(p *peer, penalty int) error {
+	if penalty == 0 {
+		return nil
+	}
+	if p.gossip.penalize(penalty, time.Now()) {
+		gossipBannedMeter.Mark(1)
+		p.Log().Debug("Dropping peer spamming votes")
+		return errResp(ErrSpamPeer, "too many invalid or duplicate votes and lotteries")
+	}
+	return nil
+}
+
+// handleVote accounts a vote relayed by the peer before the voter takes it.
+func (pm *ProtocolManager) handleVote(p *peer, vote *types.Vote) error {
+	if !p.gossip.allow(time.Now()) {
+		gossipDroppedMeter.Mark(1)
+		return pm.score(p, penaltyFlood)
+	}
+	hash := vote.Hash()
+	if received(p.gossip.votes, hash, maxKnownVotes) {
+		gossipDroppedMeter.Mark(1)
+		return pm.score(p, penaltyDuplicate)
+	}
+	p.MarkVote(hash)
+	return pm.score(p, gossipPenalty(pm.voter.ReceiveVote(vote)))
+}
+
+// handleLottery accounts a lottery relayed by the peer.
+func (pm *ProtocolManager) handleLottery(p *peer, lottery *types.Lottery) error {
+	if !p.gossip.allow(time.Now()) {
+		gossipDroppedMeter.Mark(1)
+		return pm.score(p, penaltyFlood)
+	}
+	if received(p.gossip.lotterys, lottery.PosHash, maxKnownLotterys) {
+		gossipDroppedMeter.Mark(1)
+		return pm.score(p, penaltyDuplicate)
+	}
+	p.MarkLottery(lottery.PosHash)
+	return pm.score(p, gossipPenalty(pm.voter.ReceiveLottery(lottery)))
+}
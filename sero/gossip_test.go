@@ -0,0 +1,200 @@
+package sero
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/core"
+	"github.com/sero-cash/go-sero/core/types"
+	"github.com/sero-cash/go-sero/event"
+	"github.com/sero-cash/go-sero/node"
+	"github.com/sero-cash/go-sero/p2p"
+	"github.com/sero-cash/go-sero/p2p/discover"
+	"github.com/sero-cash/go-sero/p2p/simulations"
+	"github.com/sero-cash/go-sero/p2p/simulations/adapters"
+	"github.com/sero-cash/go-sero/rpc"
+	"github.com/sero-cash/go-sero/voter"
+)
+
+// testVoter takes the votes and lotteries for the blocks above 10 once, a
+// lagging one is behind all of them.
+type testVoter struct {
+	lock    sync.Mutex
+	seen    map[common.Hash]bool
+	lagging bool
+	votes   event.Feed
+}
+
+func (self *testVoter) SubscribeNewVoteEvent(ch chan<- core.NewVoteEvent) event.Subscription {
+	return self.votes.Subscribe(ch)
+}
+
+func (self *testVoter) SubscribeNewLotteryEvent(ch chan<- core.NewLotteryEvent) event.Subscription {
+	return self.votes.Subscribe(ch)
+}
+
+func (self *testVoter) receive(num uint64, hash common.Hash, known error) error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if self.lagging {
+		return voter.ErrFuture
+	}
+	if num < 10 {
+		return voter.ErrStale
+	}
+	if self.seen[hash] {
+		return known
+	}
+	self.seen[hash] = true
+	return nil
+}
+
+func (self *testVoter) ReceiveLottery(lottery *types.Lottery) error {
+	return self.receive(lottery.ParentNum, lottery.PosHash, voter.ErrLotteryKnown)
+}
+
+func (self *testVoter) ReceiveVote(vote *types.Vote) error {
+	return self.receive(vote.ParentNum, vote.Hash(), voter.ErrVoteKnown)
+}
+
+// gossipService runs the sero message handler on the votes and lotteries
+// of its peers, a spammer floods them with a stale vote.
+type gossipService struct {
+	id   discover.NodeID
+	pm   *ProtocolManager
+	spam bool
+}
+
+func (self *gossipService) Protocols() []p2p.Protocol {
+	return []p2p.Protocol{{Name: ProtocolName, Version: sero63, Length: ProtocolLengths[0], Run: self.run}}
+}
+
+func (self *gossipService) run(p *p2p.Peer, rw p2p.MsgReadWriter) error {
+	peer := newPeer(sero63, p, rw)
+	quit := make(chan struct{})
+	defer close(quit)
+	go func() {
+		vote := &types.Vote{ParentNum: 1, ShareId: common.BytesToHash(self.id[:])}
+		for i := uint64(0); ; i++ {
+			if !self.spam {
+				vote = &types.Vote{ParentNum: 100 + i, ShareId: common.BytesToHash(self.id[:])}
+				lottery := &types.Lottery{ParentNum: 100 + i, PosHash: vote.Hash()}
+				if p2p.Send(rw, NewLotteryMsg, lottery) != nil {
+					return
+				}
+			}
+			if p2p.Send(rw, NewVoteMsg, vote) != nil {
+				return
+			}
+			select {
+			case <-quit:
+				return
+			case <-time.After(self.interval()):
+			}
+		}
+	}()
+	for {
+		if err := self.pm.handleMsg(peer); err != nil {
+			return err
+		}
+	}
+}
+
+func (self *gossipService) interval() time.Duration {
+	if self.spam {
+		return time.Millisecond
+	}
+	return 20 * time.Millisecond
+}
+
+func (self *gossipService) APIs() []rpc.API                { return nil }
+func (self *gossipService) Start(server *p2p.Server) error { return nil }
+func (self *gossipService) Stop() error                    { return nil }
+
+func newGossipService(spam, lagging bool) adapters.ServiceFunc {
+	return func(ctx *adapters.ServiceContext) (node.Service, error) {
+		pm := &ProtocolManager{voter: &testVoter{seen: map[common.Hash]bool{}, lagging: lagging}}
+		return &gossipService{id: ctx.Config.ID, pm: pm, spam: spam}, nil
+	}
+}
+
+func TestGossipSpammerIsolated(t *testing.T) {
+	adapter := adapters.NewSimAdapter(map[string]adapters.ServiceFunc{
+		"honest":  newGossipService(false, false),
+		"lagging": newGossipService(false, true),
+		"spammer": newGossipService(true, false),
+	})
+	net := simulations.NewNetwork(adapter, &simulations.NetworkConfig{DefaultService: "honest"})
+	defer net.Shutdown()
+
+	start := func(service string) discover.NodeID {
+		conf := adapters.RandomNodeConfig()
+		conf.Services = []string{service}
+		node, err := net.NewNodeWithConfig(conf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := net.Start(node.ID()); err != nil {
+			t.Fatal(err)
+		}
+		return node.ID()
+	}
+	honest1, honest2, spammer := start("honest"), start("honest"), start("spammer")
+	lagging := start("lagging")
+
+	events := make(chan *simulations.Event, 100)
+	sub := net.Events().Subscribe(events)
+	defer sub.Unsubscribe()
+
+	for _, conn := range [][2]discover.NodeID{{honest1, honest2}, {lagging, honest1}, {lagging, honest2}, {spammer, honest1}, {spammer, honest2}} {
+		if err := net.Connect(conn[0], conn[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dropped := map[discover.NodeID]bool{}
+	timeout := time.After(20 * time.Second)
+	for len(dropped) < 2 {
+		select {
+		case ev := <-events:
+			if ev.Type != simulations.EventTypeConn || ev.Control || ev.Conn.Up {
+				continue
+			}
+			switch {
+			case ev.Conn.One == spammer:
+				dropped[ev.Conn.Other] = true
+			case ev.Conn.Other == spammer:
+				dropped[ev.Conn.One] = true
+			default:
+				t.Fatal("honest nodes disconnected")
+			}
+		case <-timeout:
+			t.Fatal("spammer not isolated, dropped by", len(dropped))
+		}
+	}
+	// the lagging node has taken far more future votes than the penalties
+	// of invalid ones allow by now
+	time.Sleep(time.Second)
+	for _, conn := range [][2]discover.NodeID{{honest1, honest2}, {lagging, honest1}, {lagging, honest2}} {
+		if c := net.GetConn(conn[0], conn[1]); c == nil || !c.Up {
+			t.Fatal("honest nodes not connected")
+		}
+	}
+}
+
+func TestGossipCrossingRelay(t *testing.T) {
+	pm := &ProtocolManager{voter: &testVoter{seen: map[common.Hash]bool{}}}
+	p := newPeer(sero63, p2p.NewPeer(discover.NodeID{}, "peer", nil), nil)
+
+	// the vote was broadcast to the peer while it relayed it to us
+	vote := &types.Vote{ParentNum: 100}
+	p.MarkVote(vote.Hash())
+	if err := pm.handleVote(p, vote); err != nil || p.gossip.score != 0 {
+		t.Fatal("crossing relay penalized", p.gossip.score, err)
+	}
+	if err := pm.handleVote(p, vote); err != nil || p.gossip.score != penaltyDuplicate {
+		t.Fatal("repeated vote not penalized", p.gossip.score, err)
+	}
+}
@@ -653,8 +653,7 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 		if err := msg.Decode(&vote); err != nil {
 			return errResp(ErrDecode, "msg %v: %v", msg, err)
 		}
-		p.MarkVote(vote.Hash())
-		pm.voter.AddVote(&vote)
+		return pm.handleVote(p, &vote)
 
 	case msg.Code == NewLotteryMsg:
 
@@ -663,8 +662,7 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 		if err := msg.Decode(&lottery); err != nil {
 			return errResp(ErrDecode, "msg %v: %v", msg, err)
 		}
-		p.MarkLottery(lottery.PosHash)
-		pm.voter.AddLottery(&lottery)
+		return pm.handleLottery(p, &lottery)
 
 	default:
 		return errResp(ErrInvalidMsgCode, "%v", msg.Code)
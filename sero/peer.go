@@ -99,6 +99,7 @@ type peer struct {
 	queuedAnns     chan *types.Block         // Queue of blocks to announce to the peer
 	queuedLotterys chan *types.Lottery
 	queuedVotes    chan *types.Vote
+	gossip         *gossipScore  // Accounting of the lotteries and votes relayed
 	term           chan struct{} // Termination channel to stop the broadcaster
 }
 
@@ -117,6 +118,7 @@ func newPeer(version int, p *p2p.Peer, rw p2p.MsgReadWriter) *peer {
 		queuedAnns:     make(chan *types.Block, maxQueuedAnns),
 		queuedLotterys: make(chan *types.Lottery, maxQueuedLotterys),
 		queuedVotes:    make(chan *types.Vote, maxQueuedVotes),
+		gossip:         newGossipScore(),
 		term:           make(chan struct{}),
 	}
 }
@@ -79,6 +79,7 @@ const (
 	ErrNoStatusMsg
 	ErrExtraStatusMsg
 	ErrSuspendedPeer
+	ErrSpamPeer
 )
 
 func (e errCode) String() string {
@@ -96,6 +97,7 @@ var errorToString = map[int]string{
 	ErrNoStatusMsg:             "No status message",
 	ErrExtraStatusMsg:          "Extra status message",
 	ErrSuspendedPeer:           "Suspended peer",
+	ErrSpamPeer:                "Spamming peer",
 }
 
 type txPool interface {
@@ -114,8 +116,8 @@ type txPool interface {
 type shareVoter interface {
 	SubscribeNewVoteEvent(chan<- core.NewVoteEvent) event.Subscription
 	SubscribeNewLotteryEvent(chan<- core.NewLotteryEvent) event.Subscription
-	AddLottery(lottery *types.Lottery)
-	AddVote(vote *types.Vote)
+	ReceiveLottery(lottery *types.Lottery) error
+	ReceiveVote(vote *types.Vote) error
 }
 
 // statusData is the network packet for the status message.
@@ -0,0 +1,259 @@
+package voter
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/core/types"
+	"github.com/sero-cash/go-sero/log"
+	"github.com/sero-cash/go-sero/metrics"
+)
+
+var (
+	lotteryInCounter     = metrics.NewRegisteredCounter("voter/lotteries/in", nil)
+	lotteryFailedCounter = metrics.NewRegisteredCounter("voter/lotteries/failed", nil)
+	selectedCounter      = metrics.NewRegisteredCounter("voter/shares/selected", nil)
+	signedCounter        = metrics.NewRegisteredCounter("voter/votes/signed", nil)
+	propagatedCounter    = metrics.NewRegisteredCounter("voter/votes/propagated", nil)
+	includedCounter      = metrics.NewRegisteredCounter("voter/votes/included", nil)
+	lateCounter          = metrics.NewRegisteredCounter("voter/votes/late", nil)
+	missedCounter        = metrics.NewRegisteredCounter("voter/votes/missed", nil)
+	pendingGauge         = metrics.NewRegisteredGauge("voter/votes/pending", nil)
+)
+
+const (
+	defaultAlertDeadline = 20 * time.Second
+	alertPostTimeout     = 5 * time.Second
+	dutyCheckInterval    = 2 * time.Second
+	alertEnvPrefix       = "SERO_VOTE_"
+	// dutyKeepBlocks keeps a duty until its votes can no longer be included.
+	dutyKeepBlocks = 2
+)
+
+// AlertConfig tells how a vote missed by this node is reported.
+type AlertConfig struct {
+	// Level of the log line, warn by default.
+	Level string
+	// Exec runs with the alert as JSON on stdin and in SERO_VOTE_ variables.
+	Exec string
+	// URL receives the alert as a JSON POST.
+	URL string
+	// Deadline after the selection of the share, 20s by default.
+	Deadline time.Duration
+}
+
+// MissedVote is the alert of a share selected for this node whose vote was
+// not produced in time.
+type MissedVote struct {
+	Block    uint64
+	Share    common.Hash
+	IsPool   bool
+	Selected time.Time
+	Reason   string
+}
+
+// dutyKey is a share of this node selected to vote for the block, by its
+// pool or solo.
+type dutyKey struct {
+	block  uint64
+	share  common.Hash
+	isPool bool
+}
+
+type duty struct {
+	at       time.Time
+	reason   string
+	signed   bool
+	included bool
+	alerted  bool
+}
+
+type liveness struct {
+	mu     sync.Mutex
+	duties map[dutyKey]*duty
+	config AlertConfig
+	level  log.Lvl
+	// pending duties are neither signed nor alerted.
+	pending int64
+}
+
+func newLiveness() *liveness {
+	return &liveness{duties: map[dutyKey]*duty{}, level: log.LvlWarn}
+}
+
+func (self *liveness) setConfig(config AlertConfig) error {
+	level := log.LvlWarn
+	if config.Level != "" {
+		lvl, err := log.LvlFromString(config.Level)
+		if err != nil {
+			return err
+		}
+		if lvl == log.LvlCrit {
+			return errors.New("crit is not an alert level")
+		}
+		level = lvl
+	}
+	if config.Deadline <= 0 {
+		config.Deadline = defaultAlertDeadline
+	}
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.config, self.level = config, level
+	return nil
+}
+
+// selected notes a share of this node selected for the block, reason tells
+// why it cannot be signed.
+func (self *liveness) selected(key dutyKey, reason string) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if d, ok := self.duties[key]; ok {
+		if d.reason == "" {
+			d.reason = reason
+		}
+		return
+	}
+	self.duties[key] = &duty{at: time.Now(), reason: reason}
+	selectedCounter.Inc(1)
+	self.setPending(1)
+}
+
+func (self *liveness) setPending(delta int64) {
+	self.pending += delta
+	pendingGauge.Update(self.pending)
+}
+
+func (self *liveness) failed(key dutyKey, reason string) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if d, ok := self.duties[key]; ok && !d.signed {
+		d.reason = reason
+	}
+}
+
+// signed notes a vote signed for the share, dropped when it came too late
+// for the block.
+func (self *liveness) signed(key dutyKey, dropped bool) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	signedCounter.Inc(1)
+	d, ok := self.duties[key]
+	if !ok || d.signed {
+		return
+	}
+	if d.alerted || dropped || time.Since(d.at) > self.config.Deadline {
+		lateCounter.Inc(1)
+	}
+	if !d.alerted {
+		self.setPending(-1)
+	}
+	d.signed = true
+}
+
+// included counts the votes of this node in the header, the current votes
+// are for its own block and the parent votes for the parent block.
+func (self *liveness) included(header *types.Header) {
+	num := header.Number.Uint64()
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	mark := func(block uint64, votes []types.HeaderVote) {
+		for _, vote := range votes {
+			if d, ok := self.duties[dutyKey{block, vote.Id, vote.IsPool}]; ok && d.signed && !d.included {
+				d.included = true
+				includedCounter.Inc(1)
+			}
+		}
+	}
+	mark(num, header.CurrentVotes)
+	if num > 0 {
+		mark(num-1, header.ParentVotes)
+	}
+}
+
+// check alerts the duties past the deadline and forgets the old ones.
+func (self *liveness) check(head uint64) {
+	var alerts []MissedVote
+	self.mu.Lock()
+	config, level := self.config, self.level
+	for key, d := range self.duties {
+		if !d.signed && !d.alerted && time.Since(d.at) > config.Deadline {
+			d.alerted = true
+			self.setPending(-1)
+			missedCounter.Inc(1)
+			reason := d.reason
+			if reason == "" {
+				reason = "no vote produced"
+			}
+			alerts = append(alerts, MissedVote{key.block, key.share, key.isPool, d.at, reason})
+		}
+		if time.Since(d.at) > lifeTime && key.block+dutyKeepBlocks < head {
+			if !d.signed && !d.alerted {
+				self.setPending(-1)
+			}
+			delete(self.duties, key)
+		}
+	}
+	self.mu.Unlock()
+	for _, alert := range alerts {
+		alertLog(level, alert)
+		if config.Exec != "" {
+			go alertExec(config.Exec, alert)
+		}
+		if config.URL != "" {
+			go alertPost(config.URL, alert)
+		}
+	}
+}
+
+func alertLog(level log.Lvl, alert MissedVote) {
+	ctx := []interface{}{"block", alert.Block, "share", alert.Share, "isPool", alert.IsPool, "reason", alert.Reason}
+	switch level {
+	case log.LvlError:
+		log.Error("Missed stake vote", ctx...)
+	case log.LvlWarn:
+		log.Warn("Missed stake vote", ctx...)
+	case log.LvlInfo:
+		log.Info("Missed stake vote", ctx...)
+	case log.LvlDebug:
+		log.Debug("Missed stake vote", ctx...)
+	default:
+		log.Trace("Missed stake vote", ctx...)
+	}
+}
+
+func alertExec(command string, alert MissedVote) {
+	data, _ := json.Marshal(alert)
+	cmd := exec.Command(command)
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Env = append(os.Environ(),
+		alertEnvPrefix+"BLOCK="+fmt.Sprint(alert.Block),
+		alertEnvPrefix+"SHARE="+alert.Share.Hex(),
+		alertEnvPrefix+"ISPOOL="+fmt.Sprint(alert.IsPool),
+		alertEnvPrefix+"REASON="+alert.Reason,
+	)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		log.Error("Vote alert exec", "command", command, "err", err, "output", string(out))
+	}
+}
+
+func alertPost(url string, alert MissedVote) {
+	data, _ := json.Marshal(alert)
+	client := http.Client{Timeout: alertPostTimeout}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		log.Error("Vote alert post", "url", url, "err", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		log.Error("Vote alert post", "url", url, "status", resp.Status)
+	}
+}
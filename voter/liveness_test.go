@@ -0,0 +1,63 @@
+package voter
+
+import (
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/core/types"
+)
+
+func TestLivenessAlert(t *testing.T) {
+	alerts := make(chan MissedVote, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var alert MissedVote
+		json.NewDecoder(r.Body).Decode(&alert)
+		alerts <- alert
+	}))
+	defer server.Close()
+
+	l := newLiveness()
+	if err := l.setConfig(AlertConfig{Level: "crit"}); err == nil {
+		t.Fatal("crit accepted")
+	}
+	if err := l.setConfig(AlertConfig{URL: server.URL, Deadline: time.Millisecond}); err != nil {
+		t.Fatal(err)
+	}
+	missed := dutyKey{10, common.Hash{1}, true}
+	voted := dutyKey{10, common.Hash{2}, false}
+	// the pool of the voted share was selected too and did not vote
+	poolMissed := dutyKey{10, common.Hash{2}, true}
+	l.selected(missed, "vote key of the pool not available to the signer")
+	l.selected(voted, "")
+	l.selected(poolMissed, "")
+	l.signed(voted, false)
+	if l.pending != 2 {
+		t.Fatal("pending", l.pending)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	l.check(10)
+	for i := 0; i < 2; i++ {
+		select {
+		case alert := <-alerts:
+			if alert.Block != 10 || (alert.Share != missed.share && alert.Share != poolMissed.share) || !alert.IsPool {
+				t.Fatalf("alert %+v", alert)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("no alert posted")
+		}
+	}
+	if l.pending != 0 || !l.duties[missed].alerted || !l.duties[poolMissed].alerted || l.duties[voted].alerted {
+		t.Fatal("duties not updated")
+	}
+
+	l.included(&types.Header{Number: big.NewInt(11), ParentVotes: []types.HeaderVote{{Id: voted.share}}})
+	if !l.duties[voted].included || l.duties[poolMissed].included {
+		t.Fatal("parent vote not included")
+	}
+}
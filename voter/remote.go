@@ -0,0 +1,265 @@
+package voter
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sero-cash/go-czero-import/c_type"
+	"github.com/sero-cash/go-czero-import/superzk"
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/core/types"
+	"github.com/sero-cash/go-sero/log"
+	"github.com/sero-cash/go-sero/rpc"
+	"github.com/sero-cash/go-sero/serodb"
+	"github.com/sero-cash/go-sero/zero/utils"
+)
+
+const (
+	// remoteTimeout bounds a call to the signer, a late vote is worthless.
+	remoteTimeout = 3 * time.Second
+	// remoteKeyRefresh is how often the keys of the signer are fetched again.
+	remoteKeyRefresh = time.Minute
+	// signedKeep is how many parent numbers the signer remembers per key.
+	signedKeep = 1024
+)
+
+// Config selects the vote signer, the local wallets when SignerURL is empty.
+type Config struct {
+	// SignerURL is the http url or the IPC path of a vote signer daemon.
+	SignerURL string
+	// SignerToken authenticates the node to a signer served over http.
+	SignerToken string
+	// Alert reports the votes of the node that were not produced in time.
+	Alert AlertConfig
+}
+
+type tokenTransport struct {
+	token string
+	base  http.RoundTripper
+}
+
+func (self *tokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req.Header.Set("Authorization", "Bearer "+self.token)
+	return self.base.RoundTrip(req)
+}
+
+// TokenHandler rejects the http requests without the bearer token.
+func TokenHandler(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			http.Error(w, "invalid token", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isMyPKr is replaced by the tests, the stub of superzk owns no PKr.
+var isMyPKr = func(tk *c_type.Tk, pkr *c_type.PKr) bool {
+	return superzk.IsMyPKr(tk, pkr)
+}
+
+type remoteKey struct {
+	tk c_type.Tk
+	pk c_type.Uint512
+}
+
+// RemoteSigner has the votes signed by a signer daemon over JSON-RPC. The
+// keys of the signer are fetched in the background, KeyOf matches the PKrs
+// against them locally.
+type RemoteSigner struct {
+	client *rpc.Client
+	lock   sync.RWMutex
+	keys   []remoteKey
+	// owners caches the matches of KeyOf until the next refresh, nil for
+	// the PKrs of no key.
+	owners map[c_type.PKr]*c_type.Uint512
+	// gen counts the refreshes, a match made with older keys is not cached.
+	gen  uint64
+	quit chan struct{}
+}
+
+// DialRemoteSigner connects to the signer at url, an IPC path unless it is
+// an http url. The IPC socket is guarded by its file permissions, http needs
+// the token.
+func DialRemoteSigner(url string, token string) (*RemoteSigner, error) {
+	var (
+		client *rpc.Client
+		err    error
+	)
+	if strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://") {
+		if token == "" {
+			return nil, fmt.Errorf("vote signer %v needs a token", url)
+		}
+		client, err = rpc.DialHTTPWithClient(url, &http.Client{
+			Transport: &tokenTransport{token, http.DefaultTransport},
+			Timeout:   remoteTimeout,
+		})
+	} else {
+		ctx, cancel := context.WithTimeout(context.Background(), remoteTimeout)
+		defer cancel()
+		client, err = rpc.DialIPC(ctx, url)
+	}
+	if err != nil {
+		return nil, err
+	}
+	signer := &RemoteSigner{client: client, owners: map[c_type.PKr]*c_type.Uint512{}, quit: make(chan struct{})}
+	if err := signer.refresh(); err != nil {
+		log.Error("Vote signer keys", "err", err)
+	}
+	go signer.loop()
+	return signer, nil
+}
+
+func (self *RemoteSigner) loop() {
+	ticker := time.NewTicker(remoteKeyRefresh)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := self.refresh(); err != nil {
+				log.Error("Vote signer keys", "err", err)
+			}
+		case <-self.quit:
+			return
+		}
+	}
+}
+
+// refresh fetches the keys of the signer, the old ones are kept when it does
+// not answer.
+func (self *RemoteSigner) refresh() error {
+	ctx, cancel := context.WithTimeout(context.Background(), remoteTimeout)
+	defer cancel()
+	var tks []c_type.Tk
+	if err := self.client.CallContext(ctx, &tks, "votesigner_keys"); err != nil {
+		return err
+	}
+	keys := make([]remoteKey, 0, len(tks))
+	for _, tk := range tks {
+		if pk, err := superzk.Tk2Pk(&tk); err == nil {
+			keys = append(keys, remoteKey{tk, pk})
+		}
+	}
+	self.lock.Lock()
+	self.keys = keys
+	self.owners = map[c_type.PKr]*c_type.Uint512{}
+	self.gen++
+	self.lock.Unlock()
+	return nil
+}
+
+func (self *RemoteSigner) KeyOf(pkr c_type.PKr) (pk c_type.Uint512, ok bool) {
+	self.lock.RLock()
+	owner, cached := self.owners[pkr]
+	keys, gen := self.keys, self.gen
+	self.lock.RUnlock()
+	if !cached {
+		for i := range keys {
+			if isMyPKr(&keys[i].tk, &pkr) {
+				owner = &keys[i].pk
+				break
+			}
+		}
+		self.lock.Lock()
+		if self.gen == gen {
+			self.owners[pkr] = owner
+		}
+		self.lock.Unlock()
+	}
+	if owner == nil {
+		return
+	}
+	return *owner, true
+}
+
+func (self *RemoteSigner) Keys() (tks []c_type.Tk) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	for _, key := range self.keys {
+		tks = append(tks, key.tk)
+	}
+	return
+}
+
+func (self *RemoteSigner) Sign(req *VoteRequest) (sign c_type.Uint512, e error) {
+	ctx, cancel := context.WithTimeout(context.Background(), remoteTimeout)
+	defer cancel()
+	e = self.client.CallContext(ctx, &sign, "votesigner_signVote", req)
+	return
+}
+
+func (self *RemoteSigner) Close() {
+	close(self.quit)
+	self.client.Close()
+}
+
+var signedPrefix = []byte("SIGNED")
+
+func signedKey(pk *c_type.Uint512, parentNum uint64) []byte {
+	return append(append(append([]byte{}, signedPrefix...), pk[:]...), utils.EncodeNumber(parentNum)...)
+}
+
+// SignerService is the votesigner API of a signer daemon. A key never signs
+// votes for two PosHash values at the same parent number.
+type SignerService struct {
+	signer VoteSigner
+	db     serodb.Database
+	lock   sync.Mutex
+}
+
+func NewSignerService(signer VoteSigner, db serodb.Database) *SignerService {
+	return &SignerService{signer: signer, db: db}
+}
+
+// KeyOf returns the pk behind the vote PKr, nil when the signer does not
+// hold it.
+func (self *SignerService) KeyOf(pkr c_type.PKr) *c_type.Uint512 {
+	if pk, ok := self.signer.KeyOf(pkr); ok {
+		return &pk
+	}
+	return nil
+}
+
+// Keys returns the tks of the accounts the signer can sign for, the node
+// matches the vote PKrs against them.
+func (self *SignerService) Keys() []c_type.Tk {
+	return self.signer.Keys()
+}
+
+func (self *SignerService) SignVote(req VoteRequest) (sign c_type.Uint512, e error) {
+	if types.StakeHash(&req.PosHash, &req.ParentPos, req.IsPool) != req.StakeHash {
+		e = fmt.Errorf("stake hash of %v does not match", req.PosHash.Hex())
+		return
+	}
+	pk, ok := self.signer.KeyOf(req.VotePKr)
+	if !ok {
+		e = errNoVoteKey
+		return
+	}
+
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	key := signedKey(&pk, req.ParentNum)
+	if value, err := self.db.Get(key); err == nil {
+		if signed := common.BytesToHash(value); signed != req.PosHash {
+			e = fmt.Errorf("refuse to sign %v at parent %v, signed %v", req.PosHash.Hex(), req.ParentNum, signed.Hex())
+			return
+		}
+	} else {
+		if e = self.db.Put(key, req.PosHash[:]); e != nil {
+			return
+		}
+		if req.ParentNum > signedKeep {
+			self.db.Delete(signedKey(&pk, req.ParentNum-signedKeep))
+		}
+	}
+	log.Info("Vote signer sign", "poshash", req.PosHash, "block", req.ParentNum+1, "share", req.ShareHash, "isPool", req.IsPool)
+	return self.signer.Sign(&req)
+}
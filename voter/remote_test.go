@@ -0,0 +1,100 @@
+package voter
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sero-cash/go-czero-import/c_type"
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/core/types"
+	"github.com/sero-cash/go-sero/rpc"
+	"github.com/sero-cash/go-sero/serodb"
+)
+
+type testSigner struct {
+	pkr c_type.PKr
+}
+
+func (self *testSigner) KeyOf(pkr c_type.PKr) (c_type.Uint512, bool) {
+	return c_type.Uint512{1}, pkr == self.pkr
+}
+
+func (self *testSigner) Keys() []c_type.Tk {
+	return []c_type.Tk{{1}}
+}
+
+func (self *testSigner) Sign(req *VoteRequest) (sign c_type.Uint512, e error) {
+	copy(sign[:], req.StakeHash[:])
+	return
+}
+
+func testRequest(parentNum uint64, poshash common.Hash, isPool bool) VoteRequest {
+	req := VoteRequest{ParentNum: parentNum, PosHash: poshash, ParentPos: common.Hash{0xee}, VotePKr: c_type.PKr{1}, IsPool: isPool}
+	req.StakeHash = types.StakeHash(&req.PosHash, &req.ParentPos, req.IsPool)
+	return req
+}
+
+func TestSignerServiceRefusesDoubleSign(t *testing.T) {
+	service := NewSignerService(&testSigner{c_type.PKr{1}}, serodb.NewMemDatabase())
+
+	if _, err := service.SignVote(testRequest(10, common.Hash{1}, false)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.SignVote(testRequest(10, common.Hash{1}, true)); err != nil {
+		t.Fatalf("pool vote for the same poshash refused: %v", err)
+	}
+	if _, err := service.SignVote(testRequest(10, common.Hash{2}, false)); err == nil {
+		t.Fatal("second poshash at the same parent signed")
+	}
+	if _, err := service.SignVote(testRequest(11, common.Hash{2}, false)); err != nil {
+		t.Fatal(err)
+	}
+
+	req := testRequest(12, common.Hash{3}, false)
+	req.StakeHash = common.Hash{}
+	if _, err := service.SignVote(req); err == nil {
+		t.Fatal("wrong stake hash signed")
+	}
+	req = testRequest(12, common.Hash{3}, false)
+	req.VotePKr = c_type.PKr{2}
+	if _, err := service.SignVote(req); err == nil {
+		t.Fatal("unknown vote pkr signed")
+	}
+}
+
+func TestRemoteSignerToken(t *testing.T) {
+	defer func(orig func(*c_type.Tk, *c_type.PKr) bool) { isMyPKr = orig }(isMyPKr)
+	isMyPKr = func(tk *c_type.Tk, pkr *c_type.PKr) bool { return tk[0] == pkr[0] }
+
+	server := rpc.NewServer()
+	if err := server.RegisterName("votesigner", NewSignerService(&testSigner{c_type.PKr{1}}, serodb.NewMemDatabase())); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(TokenHandler("secret", server))
+	defer srv.Close()
+
+	signer, err := DialRemoteSigner(srv.URL, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer signer.Close()
+	if pk, ok := signer.KeyOf(c_type.PKr{1}); !ok || pk != (c_type.Uint512{1}) {
+		t.Fatalf("got key %v %v, want the pk", pk[0], ok)
+	}
+	if _, ok := signer.KeyOf(c_type.PKr{2}); ok {
+		t.Fatal("got a key for an unknown pkr")
+	}
+	req := testRequest(10, common.Hash{1}, false)
+	if sign, err := signer.Sign(&req); err != nil || sign[0] != req.StakeHash[0] {
+		t.Fatalf("got sign %v, %v", sign[0], err)
+	}
+
+	intruder, err := DialRemoteSigner(srv.URL, "guess")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer intruder.Close()
+	if _, err := intruder.Sign(&req); err == nil {
+		t.Fatal("signed with a wrong token")
+	}
+}
@@ -0,0 +1,61 @@
+package voter
+
+import (
+	"github.com/sero-cash/go-czero-import/c_type"
+	"github.com/sero-cash/go-sero/common"
+)
+
+// signedHistory is how many blocks of locally signed votes are remembered.
+const signedHistory = 10000
+
+// SignedVote is a vote this node signed for the share selected at Block.
+type SignedVote struct {
+	Block  uint64
+	Share  common.Hash
+	IsPool bool
+}
+
+type signedLog struct {
+	votes []SignedVote
+}
+
+func (self *signedLog) add(vote SignedVote) {
+	self.votes = append(self.votes, vote)
+	if vote.Block <= signedHistory {
+		return
+	}
+	drop := 0
+	for drop < len(self.votes) && self.votes[drop].Block+signedHistory < vote.Block {
+		drop++
+	}
+	self.votes = self.votes[drop:]
+}
+
+func (self *signedLog) between(from, to uint64) (votes []SignedVote) {
+	for _, vote := range self.votes {
+		if vote.Block >= from && vote.Block <= to {
+			votes = append(votes, vote)
+		}
+	}
+	return
+}
+
+func (self *Voter) addSigned(info voteInfo) {
+	self.signedMu.Lock()
+	defer self.signedMu.Unlock()
+	self.signed.add(SignedVote{info.parentNum + 1, info.shareHash, info.isPool})
+}
+
+// SignedVotes returns the votes signed by this node for the blocks from to
+// to, both included.
+func (self *Voter) SignedVotes(from, to uint64) []SignedVote {
+	self.signedMu.Lock()
+	defer self.signedMu.Unlock()
+	return self.signed.between(from, to)
+}
+
+// HasVoteKey reports whether the signer of the voter can sign for the PKr.
+func (self *Voter) HasVoteKey(pkr c_type.PKr) bool {
+	_, ok := self.getSigner().KeyOf(pkr)
+	return ok
+}
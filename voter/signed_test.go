@@ -0,0 +1,24 @@
+package voter
+
+import (
+	"testing"
+
+	"github.com/sero-cash/go-sero/common"
+)
+
+func TestSignedLogPrunes(t *testing.T) {
+	log := signedLog{}
+	for _, block := range []uint64{5, 6, 6, signedHistory + 6} {
+		log.add(SignedVote{block, common.Hash{byte(block)}, true})
+	}
+	if votes := log.between(0, 10); len(votes) != 2 || votes[0].Block != 6 {
+		t.Fatalf("got votes %+v, want the two of block 6", votes)
+	}
+	if votes := log.between(7, signedHistory+6); len(votes) != 1 {
+		t.Fatalf("got votes %+v, want one", votes)
+	}
+	log.add(SignedVote{signedHistory + 7, common.Hash{}, false})
+	if votes := log.between(0, 10); len(votes) != 0 {
+		t.Fatalf("got votes %+v older than the history", votes)
+	}
+}
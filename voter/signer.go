@@ -0,0 +1,87 @@
+package voter
+
+import (
+	"errors"
+
+	"github.com/sero-cash/go-czero-import/c_superzk"
+	"github.com/sero-cash/go-czero-import/c_type"
+	"github.com/sero-cash/go-czero-import/superzk"
+	"github.com/sero-cash/go-sero/accounts"
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/common/address"
+)
+
+// VoteRequest carries what a signer needs to sign one vote, StakeHash is
+// types.StakeHash of PosHash, ParentPos and IsPool.
+type VoteRequest struct {
+	Index     uint32
+	ParentNum uint64
+	ShareHash common.Hash
+	PosHash   common.Hash
+	ParentPos common.Hash
+	StakeHash common.Hash
+	VotePKr   c_type.PKr
+	IsPool    bool
+}
+
+// VoteSigner holds the keys of vote PKrs and signs their votes.
+type VoteSigner interface {
+	// KeyOf returns the pk of the account behind the vote PKr, false when
+	// the signer can not sign for it.
+	KeyOf(pkr c_type.PKr) (c_type.Uint512, bool)
+	// Keys returns the tks of the accounts the signer can sign for.
+	Keys() []c_type.Tk
+	Sign(req *VoteRequest) (c_type.Uint512, error)
+}
+
+var errNoVoteKey = errors.New("vote key not found")
+
+func signVote(seed *address.Seed, req *VoteRequest) (c_type.Uint512, error) {
+	data := c_type.Uint256{}
+	copy(data[:], req.StakeHash[:])
+	version := 1
+	if c_superzk.IsSzkPKr(&req.VotePKr) {
+		version = 2
+	}
+	sk := superzk.Seed2Sk(seed.SeedToUint256(), version)
+	return superzk.SignPKr_ByHeight(req.ParentNum+1, &sk, &data, &req.VotePKr)
+}
+
+// LocalSigner signs with the seeds of the unlocked wallets of am.
+type LocalSigner struct {
+	am *accounts.Manager
+}
+
+func NewLocalSigner(am *accounts.Manager) *LocalSigner {
+	return &LocalSigner{am}
+}
+
+func (self *LocalSigner) KeyOf(pkr c_type.PKr) (pk c_type.Uint512, ok bool) {
+	for _, w := range self.am.Wallets() {
+		if w.IsMine(pkr) {
+			if seed, err := w.GetSeed(); err != nil || seed == nil {
+				return
+			}
+			return w.Accounts()[0].GetPk(), true
+		}
+	}
+	return
+}
+
+func (self *LocalSigner) Keys() (tks []c_type.Tk) {
+	for _, w := range self.am.Wallets() {
+		if seed, err := w.GetSeed(); err == nil && seed != nil {
+			tks = append(tks, w.Accounts()[0].Tk.ToTk())
+		}
+	}
+	return
+}
+
+func (self *LocalSigner) Sign(req *VoteRequest) (sign c_type.Uint512, e error) {
+	seed := GetSeedByVotePkr(self.am.Wallets(), req.VotePKr)
+	if seed == nil {
+		e = errNoVoteKey
+		return
+	}
+	return signVote(seed, req)
+}
@@ -1,13 +1,12 @@
 package voter
 
 import (
+	"errors"
 	"math/big"
 	"sync"
 	"time"
 
-	"github.com/sero-cash/go-czero-import/c_superzk"
-	"github.com/sero-cash/go-czero-import/superzk"
-
+	"github.com/hashicorp/golang-lru"
 	"github.com/sero-cash/go-sero/accounts"
 
 	"github.com/sero-cash/go-sero/serodb"
@@ -17,6 +16,7 @@ import (
 	"github.com/sero-cash/go-sero/zero/stake"
 
 	"github.com/sero-cash/go-czero-import/c_type"
+	"github.com/sero-cash/go-czero-import/superzk"
 
 	"github.com/sero-cash/go-sero/log"
 
@@ -36,6 +36,22 @@ const (
 
 	delayNum         = 1
 	lotteryQueueSize = 12
+
+	// maxSeenVotes and maxSeenLotterys bound the caches of the gossip seen.
+	maxSeenVotes    = 4096
+	maxSeenLotterys = 1024
+)
+
+var (
+	ErrVoteKnown    = errors.New("known vote")
+	ErrLotteryKnown = errors.New("known lottery")
+	// ErrStale is a vote or a lottery for a block that has passed.
+	ErrStale = errors.New("stale vote or lottery")
+	// ErrFuture is a vote or a lottery for a block ahead of the local head,
+	// honest peers relay them while this node is behind.
+	ErrFuture = errors.New("future vote or lottery")
+	// ErrInvalid is a vote or a lottery no honest node sends.
+	ErrInvalid = errors.New("invalid vote or lottery")
 )
 
 type blockChain interface {
@@ -61,23 +77,35 @@ type Voter struct {
 	voteMu    sync.RWMutex
 	lotteryMu sync.RWMutex
 
-	votes    map[common.Hash]time.Time
-	lotterys map[common.Hash]time.Time
+	votes    *lru.Cache
+	lotterys *lru.Cache
 
 	lotteryQueue *PriorityQueue
+
+	signerMu sync.RWMutex
+	signer   VoteSigner
+
+	signedMu sync.Mutex
+	signed   signedLog
+
+	liveness *liveness
 }
 
 func NewVoter(chainconfig *params.ChainConfig, chain blockChain, sero Backend) *Voter {
 	// Sanitize the input to ensure no vulnerable gas prices are set
 
 	// Create the transaction pool with its initial settings
+	votes, _ := lru.New(maxSeenVotes)
+	lotterys, _ := lru.New(maxSeenLotterys)
 	voter := &Voter{
 		sero:         sero,
 		chain:        chain,
 		lotteryCh:    make(chan *types.Lottery, chainLotterySize),
-		votes:        make(map[common.Hash]time.Time),
-		lotterys:     make(map[common.Hash]time.Time),
+		votes:        votes,
+		lotterys:     lotterys,
 		lotteryQueue: &PriorityQueue{},
+		signer:       NewLocalSigner(sero.AccountManager()),
+		liveness:     newLiveness(),
 	}
 	voter.lotteryQueue.Init(lotteryQueueSize)
 
@@ -92,39 +120,57 @@ func NewVoter(chainconfig *params.ChainConfig, chain blockChain, sero Backend) *
 	return voter
 }
 
+// SetSigner replaces the signer of the votes, the local wallets by default.
+func (self *Voter) SetSigner(signer VoteSigner) {
+	self.signerMu.Lock()
+	defer self.signerMu.Unlock()
+	self.signer = signer
+}
+
+// SetAlert configures how the votes this node misses are reported.
+func (self *Voter) SetAlert(config AlertConfig) error {
+	return self.liveness.setConfig(config)
+}
+
+func (self *Voter) getSigner() VoteSigner {
+	self.signerMu.RLock()
+	defer self.signerMu.RUnlock()
+	return self.signer
+}
+
 func (self *Voter) loop() {
 	evict := time.NewTicker(evictionInterval)
 	defer evict.Stop()
+	check := time.NewTicker(dutyCheckInterval)
+	defer check.Stop()
+	heads := make(chan core.ChainHeadEvent, 16)
+	headSub := self.chain.SubscribeChainHeadEvent(heads)
+	defer headSub.Unsubscribe()
 	for {
 		select {
+		case head := <-heads:
+			self.liveness.included(head.Block.Header())
+		case <-check.C:
+			self.liveness.check(self.chain.CurrentBlock().NumberU64())
 		case <-evict.C:
 			self.lotteryMu.Lock()
-
-			dropLotterys := []common.Hash{}
-			for k, v := range self.lotterys {
-				if time.Since(v) > lifeTime {
-					dropLotterys = append(dropLotterys, k)
-				}
-			}
-			for _, h := range dropLotterys {
-				delete(self.lotterys, h)
-			}
+			evictSeen(self.lotterys)
 			self.lotteryMu.Unlock()
 			self.voteMu.Lock()
-			dropVotes := []common.Hash{}
-			for k, v := range self.votes {
-				if time.Since(v) > lifeTime {
-					dropVotes = append(dropVotes, k)
-				}
-			}
-			for _, h := range dropVotes {
-				delete(self.votes, h)
-			}
+			evictSeen(self.votes)
 			self.voteMu.Unlock()
 		}
 	}
 }
 
+func evictSeen(seen *lru.Cache) {
+	for _, k := range seen.Keys() {
+		if v, ok := seen.Peek(k); ok && time.Since(v.(time.Time)) > lifeTime {
+			seen.Remove(k)
+		}
+	}
+}
+
 func (self *Voter) IsLotteryValid(lottery *types.Lottery) bool {
 	current := self.chain.CurrentBlock().NumberU64()
 	if (lottery.ParentNum + 1) < current-1 {
@@ -152,6 +198,7 @@ func (self *Voter) lotteryTaskLoop() {
 			} else {
 				selfShares, err := self.SelfShares(lottery.PosHash, lottery.ParentHash, parentBlock.Number())
 				if err != nil {
+					lotteryFailedCounter.Inc(1)
 					log.Error("lotteryTaskLoop", "selfShare error ", err)
 				} else {
 					for _, s := range selfShares {
@@ -185,7 +232,8 @@ func (self *Voter) voteLoop() {
 				}
 				selfShares, err := self.SelfShares(lItem.Lottery.PosHash, parentBlock.Hash(), parentBlock.Number())
 				if err != nil {
-					log.Trace("lotteryTaskLoop", "selfShare error ", err)
+					lotteryFailedCounter.Inc(1)
+					log.Error("voteLoop", "selfShare error ", err)
 				} else {
 					for _, s := range selfShares {
 						self.sign(s)
@@ -202,10 +250,11 @@ type voteInfo struct {
 	parentNum uint64
 	shareHash common.Hash
 	poshash   common.Hash
+	parentPos common.Hash
 	stakeHash common.Hash
 	votePKr   c_type.PKr
 	isPool    bool
-	seed      address.Seed
+	key       c_type.Uint512
 }
 
 func cotainsVoteInfo(voteInfos []voteInfo, item voteInfo, pool *stake.StakePool) bool {
@@ -213,7 +262,7 @@ func cotainsVoteInfo(voteInfos []voteInfo, item voteInfo, pool *stake.StakePool)
 		return false
 	}
 	for _, v := range voteInfos {
-		if v.seed == item.seed && v.index == item.index &&
+		if v.key == item.key && v.index == item.index &&
 			v.shareHash == v.shareHash && v.poshash == item.poshash &&
 			v.parentNum == item.parentNum {
 			return true
@@ -243,6 +292,16 @@ func GetSeedByVotePkr(wallets []accounts.Wallet, pkr c_type.PKr) *address.Seed {
 	return nil
 }
 
+// isMine reports whether a local wallet owns the PKr, whatever the signer.
+func (self *Voter) isMine(pkr c_type.PKr) bool {
+	for _, w := range self.sero.AccountManager().Wallets() {
+		if w.IsMine(pkr) {
+			return true
+		}
+	}
+	return false
+}
+
 func (self *Voter) SelfShares(poshash common.Hash, parent common.Hash, parentNumber *big.Int) ([]voteInfo, error) {
 	current := self.chain.CurrentBlock().NumberU64()
 	if current > delayNum+parentNumber.Uint64() {
@@ -281,7 +340,7 @@ func (self *Voter) SelfShares(poshash common.Hash, parent common.Hash, parentNum
 		var voteInfos []voteInfo
 		if len(ints) > 0 {
 			parentPos := parentHeader.HashPos()
-			wallets := self.sero.AccountManager().Wallets()
+			signer := self.getSigner()
 			for i, share := range shares {
 				var pool *stake.StakePool
 				if share.PoolId != nil {
@@ -290,33 +349,53 @@ func (self *Voter) SelfShares(poshash common.Hash, parent common.Hash, parentNum
 						log.Error("lotteryTaskLoop", "GetStakePool", share.PoolId, "note exist")
 					}
 				}
+				var poolKey c_type.Uint512
+				poolOk := false
+				if pool != nil {
+					poolKey, poolOk = signer.KeyOf(pool.VotePKr)
+				}
+				shareKey, shareOk := signer.KeyOf(share.VotePKr)
+				poolDuty := dutyKey{header.Number.Uint64(), common.BytesToHash(share.Id()), true}
+				shareDuty := dutyKey{header.Number.Uint64(), common.BytesToHash(share.Id()), false}
+				if pool != nil && !pool.Closed {
+					if poolOk {
+						self.liveness.selected(poolDuty, "")
+					} else if self.isMine(pool.VotePKr) {
+						self.liveness.selected(poolDuty, "vote key of the pool not available to the signer")
+					}
+				}
+				if shareOk {
+					self.liveness.selected(shareDuty, "")
+				} else if self.isMine(share.VotePKr) {
+					self.liveness.selected(shareDuty, "vote key of the share not available to the signer")
+				}
 				if pool != nil {
 					stakeHash := types.StakeHash(&poshash, &parentPos, true)
-					seed := GetSeedByVotePkr(wallets, pool.VotePKr)
-					if seed != nil {
+					if poolOk {
 						voteInfos = append(voteInfos, voteInfo{
 							ints[i],
 							parentNumber.Uint64(),
 							common.BytesToHash(share.Id()),
 							poshash,
+							parentPos,
 							stakeHash,
 							pool.VotePKr,
 							true,
-							*seed})
+							poolKey})
 					}
 				}
-				shareVoteSeed := GetSeedByVotePkr(wallets, share.VotePKr)
-				if shareVoteSeed != nil {
+				if shareOk {
 					stakeHash := types.StakeHash(&poshash, &parentPos, false)
 					info := voteInfo{
 						ints[i],
 						parentNumber.Uint64(),
 						common.BytesToHash(share.Id()),
 						poshash,
+						parentPos,
 						stakeHash,
 						share.VotePKr,
 						false,
-						*shareVoteSeed}
+						shareKey}
 					if cotainsVoteInfo(voteInfos, info, pool) {
 						continue
 					} else {
@@ -332,22 +411,31 @@ func (self *Voter) SelfShares(poshash common.Hash, parent common.Hash, parentNum
 }
 
 func (self *Voter) sign(info voteInfo) {
-	data := c_type.Uint256{}
-	copy(data[:], info.stakeHash[:])
-	version := 1
-	if c_superzk.IsSzkPKr(&info.votePKr) {
-		version = 2
-	}
-	sk := superzk.Seed2Sk(info.seed.SeedToUint256(), version)
-	sign, err := superzk.SignPKr_ByHeight(info.parentNum+1, &sk, &data, &info.votePKr)
+	sign, err := self.getSigner().Sign(&VoteRequest{
+		Index:     info.index,
+		ParentNum: info.parentNum,
+		ShareHash: info.shareHash,
+		PosHash:   info.poshash,
+		ParentPos: info.parentPos,
+		StakeHash: info.stakeHash,
+		VotePKr:   info.votePKr,
+		IsPool:    info.isPool,
+	})
+	duty := dutyKey{info.parentNum + 1, info.shareHash, info.isPool}
 	if err != nil {
+		self.liveness.failed(duty, "sign: "+err.Error())
 		log.Error("voter sign", "sign err", err)
 		return
 	}
 	log.Info(">>>>>>>>>>>>>sign vote", "poshas", info.poshash, "block", info.parentNum+1, "share", info.shareHash, "idx", info.index, "isPool", info.isPool)
 	vote := &types.Vote{info.index, info.parentNum, info.shareHash, info.poshash, info.isPool, sign}
 	//go self.voteWorkFeed.Send(core.NewVoteEvent{vote})
-	self.AddVote(vote)
+	self.addSigned(info)
+	err = self.ReceiveVote(vote)
+	self.liveness.signed(duty, err == ErrStale)
+	if err == nil {
+		propagatedCounter.Inc(1)
+	}
 }
 
 // SubscribeNewTxsEvent registers a subscription of NewTxsEvent and
@@ -373,20 +461,34 @@ func (self *Voter) SendVoteEvent(vote *types.Vote) {
 }
 
 func (self *Voter) AddLottery(lottery *types.Lottery) {
+	self.ReceiveLottery(lottery)
+}
+
+// ReceiveLottery adds a lottery and tells why it was not taken, so the
+// peers relaying bad ones can be scored.
+func (self *Voter) ReceiveLottery(lottery *types.Lottery) error {
 	self.lotteryMu.Lock()
 	defer self.lotteryMu.Unlock()
 	current := self.chain.CurrentBlock().Number().Uint64()
 	if current > lottery.ParentNum+delayNum {
 		log.Trace("AddLottery droped", "current", current, "voteBlock", lottery.ParentNum+1)
-		return
+		return ErrStale
+	}
+	if lottery.ParentNum+1 > current+2 {
+		return ErrFuture
 	}
-	_, exits := self.lotterys[lottery.PosHash]
-	if !exits {
-		log.Trace("AddLottery", "poshas", lottery.PosHash, "block", lottery.ParentNum+1)
-		self.lotterys[lottery.PosHash] = time.Now()
-		self.lotteryCh <- lottery
-		self.SendLotteryEvent(lottery)
+	if !self.IsLotteryValid(lottery) {
+		return ErrStale
 	}
+	if self.lotterys.Contains(lottery.PosHash) {
+		return ErrLotteryKnown
+	}
+	log.Trace("AddLottery", "poshas", lottery.PosHash, "block", lottery.ParentNum+1)
+	self.lotterys.Add(lottery.PosHash, time.Now())
+	lotteryInCounter.Inc(1)
+	self.lotteryCh <- lottery
+	self.SendLotteryEvent(lottery)
+	return nil
 }
 
 func (self *Voter) getStateByNumber(num uint64) (*state.StateDB, error) {
@@ -398,20 +500,68 @@ func (self *Voter) getStateByNumber(num uint64) (*state.StateDB, error) {
 
 }
 
+// signedByShare checks the signature of the vote against the vote PKr of its
+// share at the canonical parent. A vote ahead of the chain is not checked.
+func (self *Voter) signedByShare(vote *types.Vote) bool {
+	parent := self.chain.GetHeaderByNumber(vote.ParentNum)
+	if parent == nil {
+		return true
+	}
+	state, err := self.chain.StateAt(parent)
+	if err != nil {
+		return true
+	}
+	stakeState := stake.NewStakeState(state)
+	share := stakeState.GetShare(vote.ShareId)
+	if share == nil {
+		return false
+	}
+	votePKr := &share.VotePKr
+	if vote.IsPool {
+		if share.PoolId == nil {
+			return false
+		}
+		pool := stakeState.GetStakePool(*share.PoolId)
+		if pool == nil {
+			return false
+		}
+		votePKr = &pool.VotePKr
+	}
+	parentPosHash := parent.HashPos()
+	stakeHash := types.StakeHash(&vote.PosHash, &parentPosHash, vote.IsPool)
+	return superzk.VerifyPKr_ByHeight(vote.ParentNum+1, stakeHash.HashToUint256(), &vote.Sign, votePKr)
+}
+
 func (self *Voter) AddVote(vote *types.Vote) {
+	self.ReceiveVote(vote)
+}
+
+// ReceiveVote propagates a new vote and tells why it was not taken.
+func (self *Voter) ReceiveVote(vote *types.Vote) error {
 	self.voteMu.Lock()
 	defer self.voteMu.Unlock()
 
 	current := self.chain.CurrentBlock().Number().Uint64()
 	if current > vote.ParentNum+delayNum {
 		log.Trace("AddVote droped", "current", current, "voteBlock", vote.ParentNum+1)
-		return
+		return ErrStale
+	}
+	if vote.Idx >= stake.MaxVoteCount {
+		return ErrInvalid
 	}
-	_, exits := self.votes[vote.Hash()]
-	if !exits {
-		log.Trace("AddVote", "hashpos", vote.PosHash, "block", vote.ParentNum+1)
-		go self.voteWorkFeed.Send(core.NewVoteEvent{vote})
-		self.SendVoteEvent(vote)
-		self.votes[vote.Hash()] = time.Now()
+	if vote.ParentNum+1 > current+2 {
+		return ErrFuture
 	}
+	hash := vote.Hash()
+	if self.votes.Contains(hash) {
+		return ErrVoteKnown
+	}
+	if !self.signedByShare(vote) {
+		return ErrInvalid
+	}
+	log.Trace("AddVote", "hashpos", vote.PosHash, "block", vote.ParentNum+1)
+	go self.voteWorkFeed.Send(core.NewVoteEvent{vote})
+	self.SendVoteEvent(vote)
+	self.votes.Add(hash, time.Now())
+	return nil
 }
@@ -0,0 +1,48 @@
+package consensus
+
+import (
+	"errors"
+
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/crypto"
+	"github.com/sero-cash/go-sero/rlp"
+	"github.com/sero-cash/go-sero/serodb"
+	"github.com/sero-cash/go-sero/trie"
+)
+
+// Prover is a tri that proves its values, the keys are hashed as in the
+// secure state trie.
+type Prover interface {
+	Prove(key []byte, fromLevel uint, proofDb serodb.Putter) error
+}
+
+// ProveObj puts in proofDb the nodes of the tri proving the state hash of
+// the object.
+func (self *ObjPoint) ProveObj(id []byte, proofDb serodb.Putter) error {
+	prover, ok := self.cons.db.CurrentTri().(Prover)
+	if !ok {
+		return errors.New("tri can not prove")
+	}
+	k := key{self.objPre, id}
+	return prover.Prove(crypto.Keccak256([]byte(k.k())), 0, proofDb)
+}
+
+// VerifyObj checks the nodes in proofDb against the root of the tri and
+// returns the state hash of the object they prove.
+func VerifyObj(root common.Hash, objPre string, id []byte, proofDb trie.DatabaseReader) (state []byte, e error) {
+	k := key{objPre, id}
+	value, _, err := trie.VerifyProof(root, crypto.Keccak256([]byte(k.k())), proofDb)
+	if err != nil {
+		e = err
+		return
+	}
+	if value == nil {
+		e = errors.New("object not in the tri")
+		return
+	}
+	var hash Bytes
+	if e = rlp.DecodeBytes(value, &hash); e != nil {
+		return
+	}
+	return hash, nil
+}
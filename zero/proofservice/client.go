@@ -6,6 +6,7 @@ import (
 	"errors"
 	"github.com/sero-cash/go-czero-import/c_type"
 	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/common/hexutil"
 	"github.com/sero-cash/go-sero/zero/txtool"
 	"github.com/sero-cash/go-sero/zero/wallet/light"
 	"log"
@@ -24,6 +25,10 @@ func NewLocalClient(backend Backend) *LocalClient {
 func (self *LocalClient) CommitTx(tx *txtool.GTx) error {
 	return self.backend.CommitTx(tx)
 }
+func (self *LocalClient) TxConfirmations(hash common.Hash) (uint64, error) {
+	return self.backend.TxConfirmations(hash)
+}
+
 func (self *LocalClient) CheckNils(nils []c_type.Uint256) bool {
 	if nilResps, err := self.backend.CheckNil(nils); err == nil {
 		return len(nilResps) == 0
@@ -51,6 +56,27 @@ func (self *RemoteClient) CheckNils(nils []c_type.Uint256) bool {
 	return checkNils(self.host, nils);
 }
 
+func (self *RemoteClient) TxConfirmations(hash common.Hash) (uint64, error) {
+	resp, err := doPost(self.host, "sero_getTransactionReceipt", []interface{}{hash})
+	if err != nil {
+		return 0, err
+	}
+	var receipt *struct {
+		BlockNumber hexutil.Uint64 `json:"blockNumber"`
+	}
+	if resp.Result == nil || json.Unmarshal(*resp.Result, &receipt) != nil || receipt == nil {
+		return 0, nil
+	}
+	if resp, err = doPost(self.host, "sero_blockNumber", []interface{}{}); err != nil {
+		return 0, err
+	}
+	var head hexutil.Uint64
+	if resp.Result == nil || json.Unmarshal(*resp.Result, &head) != nil || head < receipt.BlockNumber {
+		return 0, nil
+	}
+	return uint64(head-receipt.BlockNumber) + 1, nil
+}
+
 func checkNils(host string, nils []c_type.Uint256) bool {
 	resp, err := doPost(host, "light_checkNil", []interface{}{nils})
 	if err != nil {
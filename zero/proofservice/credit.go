@@ -0,0 +1,90 @@
+package proofservice
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/sero-cash/go-czero-import/c_superzk"
+	"github.com/sero-cash/go-czero-import/c_type"
+	"github.com/sero-cash/go-sero/log"
+	"github.com/sero-cash/go-sero/zero/txs/stx"
+)
+
+// verifyFrom tells whether the tx is signed by its From, which the credit
+// of the job belongs to.
+var verifyFrom = func(tx *stx.T) bool {
+	hash := tx.Tx1_Hash()
+	return c_superzk.VerifyPKr_X(&hash, &tx.Sign, &tx.From)
+}
+
+// Account is the prepaid credit of a client, Spent and Jobs are what the
+// jobs took from it.
+type Account struct {
+	Balance   *big.Int
+	Deposited *big.Int
+	Spent     *big.Int
+	Jobs      uint64
+}
+
+func newAccount() Account {
+	return Account{big.NewInt(0), big.NewInt(0), big.NewInt(0), 0}
+}
+
+// Account returns the credit of the client.
+func (proof *ProofService) Account(client c_type.PKr) Account {
+	return proof.storage.Account(client)
+}
+
+func (proof *ProofService) charge(client c_type.PKr, amount *big.Int) error {
+	proof.creditLock.Lock()
+	defer proof.creditLock.Unlock()
+	account := proof.storage.Account(client)
+	if account.Balance.Cmp(amount) < 0 {
+		return errors.New("insufficient credit")
+	}
+	account.Balance.Sub(account.Balance, amount)
+	account.Spent.Add(account.Spent, amount)
+	account.Jobs++
+	proof.storage.SaveAccount(client, account)
+	return nil
+}
+
+func (proof *ProofService) refund(client c_type.PKr, amount *big.Int) {
+	proof.creditLock.Lock()
+	defer proof.creditLock.Unlock()
+	account := proof.storage.Account(client)
+	account.Balance.Add(account.Balance, amount)
+	account.Spent.Sub(account.Spent, amount)
+	account.Jobs--
+	proof.storage.SaveAccount(client, account)
+}
+
+func (proof *ProofService) deposit(client c_type.PKr, amount *big.Int) {
+	proof.creditLock.Lock()
+	defer proof.creditLock.Unlock()
+	account := proof.storage.Account(client)
+	account.Balance.Add(account.Balance, amount)
+	account.Deposited.Add(account.Deposited, amount)
+	proof.storage.SaveAccount(client, account)
+}
+
+// creditDeposits adds the deposits of the committed jobs once their tx is
+// confirmed, so that a tx double spent before it is mined credits nothing.
+func (proof *ProofService) creditDeposits() {
+	for _, job := range proof.Jobs(JobCommitted) {
+		if job.Deposit == nil || job.Credited {
+			continue
+		}
+		confirmations, err := proof.client.TxConfirmations(job.TxHash)
+		if err != nil {
+			log.Warn("ProofService tx confirmations", "hash", job.TxHash, "err", err)
+			continue
+		}
+		if confirmations < proof.config.CreditConfirmations {
+			continue
+		}
+		proof.deposit(job.Client, job.Deposit)
+		job.Credited = true
+		proof.storage.Save(job)
+	}
+}
@@ -1,15 +1,18 @@
 package proofservice
 
 import (
+	"crypto/ecdsa"
 	"errors"
 	"github.com/sero-cash/go-czero-import/c_type"
 	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/crypto"
 	"github.com/sero-cash/go-sero/log"
 	"github.com/sero-cash/go-sero/zero/txs/stx"
 	"github.com/sero-cash/go-sero/zero/txtool"
 	"github.com/sero-cash/go-sero/zero/txtool/flight"
 	"github.com/sero-cash/go-sero/zero/wallet/light"
 	"math/big"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -22,17 +25,58 @@ type ServiceFee struct {
 	FixedFee *big.Int
 }
 
+const (
+	JobQueued    = "queued"
+	JobProving   = "proving"
+	JobCommitted = "committed"
+	JobFailed    = "failed"
+)
+
+const (
+	defaultJobTTL              = 2 * time.Hour
+	defaultCreditConfirmations = 12
+)
+
+// Job proves the tx with the hash Hash, the hash of its Tx1, which is also
+// found by SignHash, the hash its From signs. TxHash is the hash of the committed
+// tx and Reason tells why the job failed. Charge is what the job took from
+// the credit of the Client and Deposit what it adds once the committed tx
+// is confirmed, Credited tells it was added.
 type Job struct {
+	Hash      common.Hash
+	SignHash  common.Hash
 	TxHash    common.Hash
+	State     string
+	Reason    string
 	Timestamp time.Time
-	Error     error
+	Updated   time.Time
+	Client    c_type.PKr
+	Charge    *big.Int
+	Deposit   *big.Int
+	Credited  bool
 
 	tx    *stx.T
 	param *txtool.GTxParam
 }
 
 func newJob(tx *stx.T, param *txtool.GTxParam) *Job {
-	return &Job{tx: tx, param: param};
+	hash, signHash := tx.Tx1.Tx1_Hash(), tx.Tx1_Hash()
+	now := time.Now()
+	return &Job{Hash: common.BytesToHash(hash[:]), SignHash: common.BytesToHash(signHash[:]), State: JobQueued, Timestamp: now, Updated: now, tx: tx, param: param}
+}
+
+func (job *Job) finished() bool {
+	return job.State == JobCommitted || job.State == JobFailed
+}
+
+func (job *Job) setState(state, reason string) {
+	job.State, job.Reason, job.Updated = state, reason, time.Now()
+}
+
+func sortJobs(jobs []*Job) {
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].Timestamp.Before(jobs[j].Timestamp)
+	})
 }
 
 var instance *ProofService
@@ -42,6 +86,31 @@ type Config struct {
 	MaxWorkNumber  int
 	MaxQueueNumber int
 	Fee            ServiceFee
+	// DataDir keeps the jobs over restarts, they are in memory when empty.
+	DataDir string
+	// JobKey is the AES key sealing the work of the unfinished jobs in
+	// DataDir, without it they are not resumed after a restart.
+	JobKey []byte
+	// JobTTL is how long the finished jobs are kept, 2 hours by default.
+	JobTTL time.Duration
+	// Workers hands the jobs to registered worker processes instead of
+	// proving them in process.
+	Workers bool
+	// LeaseTimeout is how long a worker keeps a job without a heartbeat, 30
+	// seconds by default.
+	LeaseTimeout time.Duration
+	// QuoteKey signs the fee quotes, a key made at start when nil.
+	QuoteKey *ecdsa.PrivateKey
+	// QuoteTTL is how long a quote holds, 10 minutes by default.
+	QuoteTTL time.Duration
+	// Credit lets the clients pay from a prepaid credit: a job paying more
+	// than its fee deposits the rest to the credit of the From of its tx,
+	// which signs it, and a job paying less takes the difference from it.
+	Credit bool
+	// CreditConfirmations is how deep the tx of a job is mined before its
+	// deposit is credited, 12 blocks by default. The deposits not confirmed
+	// before the job is evicted are dropped.
+	CreditConfirmations uint64
 }
 
 type ProofService struct {
@@ -50,11 +119,13 @@ type ProofService struct {
 
 	queueChan chan *Job
 	workChan  chan *Job
-	jobs      sync.Map
-	workNum   int32;
+	workNum   int32
 	client    SeroClient
 	// redisClient *RedisClient
 	storage Storage
+	pool    *workerPool
+
+	creditLock sync.Mutex
 }
 
 func Instance() *ProofService {
@@ -64,39 +135,15 @@ func Instance() *ProofService {
 type Backend interface {
 	CommitTx(tx *txtool.GTx) error
 	CheckNil(Nils []c_type.Uint256) (nilResps []light.NilValue, e error)
+	// TxConfirmations counts the blocks from the one of the tx, 0 while it
+	// is not mined.
+	TxConfirmations(hash common.Hash) (uint64, error)
 }
 
 type SeroClient interface {
 	CheckNils(nils []c_type.Uint256) bool
 	CommitTx(tx *txtool.GTx) error
-}
-
-type Storage interface {
-	Exists(common.Hash) bool
-	Save(job *Job)
-	Get(hash common.Hash) *Job
-}
-
-type MapStorage struct {
-	cache map[common.Hash]*Job
-}
-
-func newMapStorage() *MapStorage {
-	return &MapStorage{make(map[common.Hash]*Job)}
-}
-
-func (storage *MapStorage) Exists(hash common.Hash) bool {
-	_, ok := storage.cache[hash];
-	return ok;
-}
-
-func (storage *MapStorage) Save(job *Job) {
-	hash := job.tx.Tx1.Tx1_Hash()
-	storage.cache[common.BytesToHash(hash[:])] = job
-}
-
-func (storage *MapStorage) Get(hash common.Hash) *Job {
-	return storage.cache[hash]
+	TxConfirmations(hash common.Hash) (uint64, error)
 }
 
 func NewProofService(rpc string, backend Backend, config *Config) *ProofService {
@@ -106,75 +153,119 @@ func NewProofService(rpc string, backend Backend, config *Config) *ProofService
 		queueChan: make(chan *Job, config.MaxQueueNumber),
 	}
 
-	proof.client = NewLocalClient(backend)
+	if backend != nil {
+		proof.client = NewLocalClient(backend)
+	} else {
+		proof.client = NewRemoteClient(rpc)
+	}
 	proof.storage = newMapStorage()
+	if config.DataDir != "" {
+		storage, err := NewDBStorage(config.DataDir, config.JobKey)
+		if err != nil {
+			panic(err)
+		}
+		proof.storage = storage
+	}
+	if config.JobTTL <= 0 {
+		config.JobTTL = defaultJobTTL
+	}
+	if config.QuoteKey == nil {
+		key, err := crypto.GenerateKey()
+		if err != nil {
+			panic(err)
+		}
+		config.QuoteKey = key
+	}
+	if config.QuoteTTL <= 0 {
+		config.QuoteTTL = defaultQuoteTTL
+	}
+	if config.CreditConfirmations == 0 {
+		config.CreditConfirmations = defaultCreditConfirmations
+	}
+	if config.Workers {
+		if config.LeaseTimeout <= 0 {
+			config.LeaseTimeout = defaultLeaseTimeout
+		}
+		proof.pool = newWorkerPool(config.LeaseTimeout)
+	}
 
 	instance = proof
+	proof.resume()
 	go proof.loop()
 	log.Info("ProofService start", "config:", config)
 	return proof
 }
 
-func (proof *ProofService) FindTxHash(hash common.Hash) common.Hash {
-	job := proof.storage.Get(hash)
-	if job != nil {
-		return job.TxHash
+// resume queues again the jobs a restart stopped before they were proven,
+// it runs before the service takes new work so no job is queued twice.
+func (proof *ProofService) resume() {
+	var pending []*Job
+	for _, job := range proof.storage.List() {
+		if job.finished() {
+			continue
+		}
+		if job.tx == nil || job.param == nil {
+			proof.finish(job, nil, "lost on restart")
+			continue
+		}
+		job.setState(JobQueued, "")
+		proof.storage.Save(job)
+		pending = append(pending, job)
+		log.Info("ProofService resume job", "hash", job.Hash)
 	}
-	return common.Hash{}
+	go func() {
+		for _, job := range pending {
+			proof.queueChan <- job
+		}
+	}()
 }
 
-var sero = *common.BytesToHash(common.LeftPadBytes([]byte("SERO"), 32)).HashToUint256()
-
-func (proof *ProofService) checkFee(param *txtool.GTxParam) bool {
-	if proof.config.Fee.FixedFee.Sign() > 0 {
-		for _, out := range param.Outs {
-			if out.PKr == proof.config.PKr {
-				if out.Asset.Tkn != nil {
-					if out.Asset.Tkn.Currency == sero {
-						amount := out.Asset.Tkn.Value.ToInt()
-						return amount.Cmp(proof.config.Fee.FixedFee) >= 0
-					}
-				}
-				break
-			}
-		}
-	} else {
-		fee := big.NewInt(0)
-		for _, in := range param.Ins {
-			if in.Out.State.OS.Out_P != nil {
-				fee = new(big.Int).Add(fee, proof.config.Fee.ZinFee)
-			} else if in.Out.State.OS.Out_C != nil {
-				fee = new(big.Int).Add(fee, proof.config.Fee.OinFee)
-			} else {
-				return false
-			}
+// Job returns the job proving the tx with the hash, the hash of its Tx1 or
+// the one its From signs.
+func (proof *ProofService) Job(hash common.Hash) *Job {
+	if job := proof.storage.Get(hash); job != nil {
+		return job
+	}
+	for _, job := range proof.storage.List() {
+		if job.SignHash == hash {
+			return job
 		}
-		fee = new(big.Int).Add(fee, new(big.Int).Mul(proof.config.Fee.OutFee, big.NewInt(int64(len(param.Outs)-1))))
-		for _, out := range param.Outs {
-			if out.PKr == proof.config.PKr {
-				if out.Asset.Tkn != nil {
-					if out.Asset.Tkn.Currency == sero {
-						amount := out.Asset.Tkn.Value.ToInt()
-						return amount.Cmp(fee) >= 0
-					}
-				}
-				break
-			}
+	}
+	return nil
+}
+
+// Jobs returns the jobs in the state, all jobs when it is empty.
+func (proof *ProofService) Jobs(state string) (jobs []*Job) {
+	for _, job := range proof.storage.List() {
+		if state == "" || job.State == state {
+			jobs = append(jobs, job)
 		}
 	}
+	return
+}
 
-	return false
+func (proof *ProofService) FindTxHash(hash common.Hash) common.Hash {
+	job := proof.Job(hash)
+	if job != nil {
+		return job.TxHash
+	}
+	return common.Hash{}
 }
 
+var sero = *common.BytesToHash(common.LeftPadBytes([]byte("SERO"), 32)).HashToUint256()
+
 func (proof *ProofService) Fee() ServiceFee {
 	return proof.config.Fee
 }
 
-func (proof *ProofService) SubmitWork(tx *stx.T, param *txtool.GTxParam) error {
-	hash := tx.Tx1_Hash()
-	if !proof.checkFee(param) {
-		log.Error("check fee error", "txHash", common.Bytes2Hex(hash[:]))
-		errors.New("checkFee error")
+// SubmitWork queues the proof of the tx, it is charged the fee of the quote
+// when one is given.
+func (proof *ProofService) SubmitWork(tx *stx.T, param *txtool.GTxParam, quote *Quote) error {
+	hash := tx.Tx1.Tx1_Hash()
+	charge, deposit, err := proof.checkFee(param, quote)
+	if err != nil {
+		log.Error("check fee error", "txHash", common.Bytes2Hex(hash[:]), "err", err)
+		return err
 	}
 
 	if proof.storage.Exists(common.BytesToHash(hash[:])) {
@@ -183,55 +274,97 @@ func (proof *ProofService) SubmitWork(tx *stx.T, param *txtool.GTxParam) error {
 	}
 
 	job := newJob(tx, param)
+	if charge != nil || deposit != nil {
+		if param.From.PKr != tx.From || !verifyFrom(tx) {
+			return errors.New("credit needs the tx signed by its From")
+		}
+	}
+	job.Client, job.Deposit = tx.From, deposit
+	if charge != nil {
+		if err := proof.charge(job.Client, charge); err != nil {
+			return err
+		}
+		job.Charge = charge
+	}
+	proof.storage.Save(job)
 	if TryEnqueue(job, proof.queueChan) {
-		proof.storage.Save(job);
 		return nil
 	}
+	proof.storage.Delete(job.Hash)
+	if charge != nil {
+		proof.refund(job.Client, charge)
+	}
 	return errors.New("server is busy")
 }
 
+// finish commits the job with the proven tx or fails it with the reason,
+// and refunds the charge of a failed one.
+func (proof *ProofService) finish(job *Job, gtx *txtool.GTx, reason string) {
+	if reason == "" {
+		if err := proof.client.CommitTx(gtx); err != nil {
+			reason = err.Error()
+		}
+	}
+	if reason != "" {
+		log.Error("processJob error", "hash", job.Hash, "error", reason)
+		job.setState(JobFailed, reason)
+		if job.Charge != nil {
+			proof.refund(job.Client, job.Charge)
+		}
+	} else {
+		txHash := gtx.Tx.ToHash()
+		job.TxHash = common.BytesToHash(txHash[:])
+		job.setState(JobCommitted, "")
+	}
+	proof.storage.Save(job)
+}
+
 func (proof *ProofService) processJob(job *Job) {
+	job.setState(JobProving, "")
+	proof.storage.Save(job)
 	gtx, err := flight.ProveTx1(job.tx, job.param)
 	if err != nil {
-		log.Error("processJob error", "error", err)
-		job.Error = err
-		proof.storage.Save(job);
+		proof.finish(job, nil, err.Error())
 		return
 	}
-	if err := proof.client.CommitTx(&gtx); err != nil {
-		log.Error("processJob error", "error", err)
-		job.Error = err
-		proof.storage.Save(job);
-		return
-	}
-	txHash := gtx.Tx.ToHash()
-	job.TxHash = common.BytesToHash(txHash[:])
-	proof.storage.Save(job);
+	proof.finish(job, &gtx, "")
 }
 
 func (proof *ProofService) loop() {
 	clear := time.NewTicker(time.Minute * 10)
 	defer clear.Stop()
+	credit := time.NewTicker(time.Minute)
+	defer credit.Stop()
+
+	// the workers fetch the jobs themselves when there is a pool
+	queueChan := proof.queueChan
+	var expire <-chan time.Time
+	if proof.pool != nil {
+		queueChan = nil
+		ticker := time.NewTicker(proof.pool.timeout / 3)
+		defer ticker.Stop()
+		expire = ticker.C
+	}
 
 	for {
 		for proof.workNum >= 5 {
 			time.Sleep(time.Second)
 		}
 		select {
-		case job := <-proof.queueChan:
+		case job := <-queueChan:
 			atomic.AddInt32(&proof.workNum, 1)
 			go func() {
-				defer atomic.AddInt32(&proof.workNum, -1);
+				defer atomic.AddInt32(&proof.workNum, -1)
 				proof.processJob(job)
 			}()
+		case <-credit.C:
+			proof.creditDeposits()
 		case <-clear.C:
-			proof.jobs.Range(func(key, value interface{}) bool {
-				job := value.(*Job)
-				if job.Timestamp.Add(time.Hour * 2).Before(time.Now()) {
-					proof.jobs.Delete(key);
-				}
-				return true
-			})
+			if count := proof.storage.Evict(time.Now().Add(-proof.config.JobTTL)); count > 0 {
+				log.Info("ProofService evicted jobs", "count", count)
+			}
+		case <-expire:
+			proof.expireLeases()
 		}
 	}
 }
@@ -0,0 +1,150 @@
+package proofservice
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"time"
+
+	"github.com/sero-cash/go-sero/common/hexutil"
+	"github.com/sero-cash/go-sero/crypto"
+	"github.com/sero-cash/go-sero/rlp"
+	"github.com/sero-cash/go-sero/zero/txtool"
+)
+
+const defaultQuoteTTL = 10 * time.Minute
+
+// Quote is a fee the service signed to charge for the proofs of txs with
+// the input and output counts until Expires, in unix seconds.
+type Quote struct {
+	Zins      hexutil.Uint64
+	Oins      hexutil.Uint64
+	Outs      hexutil.Uint64
+	Fee       *hexutil.Big
+	Expires   hexutil.Uint64
+	Signature hexutil.Bytes
+}
+
+func (quote *Quote) sigHash() []byte {
+	data, _ := rlp.EncodeToBytes([]interface{}{
+		uint64(quote.Zins), uint64(quote.Oins), uint64(quote.Outs), quote.Fee.ToInt(), uint64(quote.Expires),
+	})
+	return crypto.Keccak256(data)
+}
+
+// counts returns the Z and O inputs of the param and its outputs not paying
+// the service.
+func (proof *ProofService) counts(param *txtool.GTxParam) (zins, oins, outs uint64, e error) {
+	for _, in := range param.Ins {
+		if in.Out.State.OS.Out_P != nil {
+			zins++
+		} else if in.Out.State.OS.Out_C != nil {
+			oins++
+		} else {
+			e = errors.New("input without out")
+			return
+		}
+	}
+	for _, out := range param.Outs {
+		if out.PKr != proof.config.PKr {
+			outs++
+		}
+	}
+	return
+}
+
+func (proof *ProofService) price(zins, oins, outs uint64) *big.Int {
+	fee := proof.config.Fee
+	if fee.FixedFee.Sign() > 0 {
+		return new(big.Int).Set(fee.FixedFee)
+	}
+	price := new(big.Int).Mul(fee.ZinFee, new(big.Int).SetUint64(zins))
+	price.Add(price, new(big.Int).Mul(fee.OinFee, new(big.Int).SetUint64(oins)))
+	return price.Add(price, new(big.Int).Mul(fee.OutFee, new(big.Int).SetUint64(outs)))
+}
+
+// paid returns the SERO the param pays the service.
+func (proof *ProofService) paid(param *txtool.GTxParam) *big.Int {
+	for _, out := range param.Outs {
+		if out.PKr == proof.config.PKr {
+			if out.Asset.Tkn != nil && out.Asset.Tkn.Currency == sero {
+				return out.Asset.Tkn.Value.ToInt()
+			}
+			break
+		}
+	}
+	return big.NewInt(0)
+}
+
+// Quote prices the proof of the tx of the param.
+func (proof *ProofService) Quote(param *txtool.GTxParam) (quote *Quote, e error) {
+	zins, oins, outs, e := proof.counts(param)
+	if e != nil {
+		return
+	}
+	quote = &Quote{
+		Zins:    hexutil.Uint64(zins),
+		Oins:    hexutil.Uint64(oins),
+		Outs:    hexutil.Uint64(outs),
+		Fee:     (*hexutil.Big)(proof.price(zins, oins, outs)),
+		Expires: hexutil.Uint64(time.Now().Add(proof.config.QuoteTTL).Unix()),
+	}
+	if quote.Signature, e = crypto.Sign(quote.sigHash(), proof.config.QuoteKey); e != nil {
+		quote = nil
+	}
+	return
+}
+
+func (proof *ProofService) checkQuote(quote *Quote, param *txtool.GTxParam) error {
+	if quote.Fee == nil {
+		return errors.New("quote without fee")
+	}
+	pub, err := crypto.Ecrecover(quote.sigHash(), quote.Signature)
+	if err != nil || !bytes.Equal(pub, crypto.FromECDSAPub(&proof.config.QuoteKey.PublicKey)) {
+		return errors.New("quote not signed by the service")
+	}
+	if time.Now().Unix() > int64(quote.Expires) {
+		return errors.New("quote expired")
+	}
+	zins, oins, outs, err := proof.counts(param)
+	if err != nil {
+		return err
+	}
+	if zins != uint64(quote.Zins) || oins != uint64(quote.Oins) || outs != uint64(quote.Outs) {
+		return errors.New("quote for other counts")
+	}
+	return nil
+}
+
+// checkFee returns what the job of the param takes from the credit of the
+// client and what it adds to it once committed.
+func (proof *ProofService) checkFee(param *txtool.GTxParam, quote *Quote) (charge, deposit *big.Int, e error) {
+	var fee *big.Int
+	if quote != nil {
+		if e = proof.checkQuote(quote, param); e != nil {
+			return
+		}
+		fee = quote.Fee.ToInt()
+	} else {
+		zins, oins, outs, err := proof.counts(param)
+		if err != nil {
+			e = err
+			return
+		}
+		fee = proof.price(zins, oins, outs)
+	}
+
+	paid := proof.paid(param)
+	if paid.Cmp(fee) >= 0 {
+		if proof.config.Credit && paid.Cmp(fee) > 0 {
+			deposit = new(big.Int).Sub(paid, fee)
+		}
+		return
+	}
+	if !proof.config.Credit {
+		e = errors.New("checkFee error")
+		return
+	}
+	charge = new(big.Int).Sub(fee, paid)
+	return
+}
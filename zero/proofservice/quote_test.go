@@ -0,0 +1,104 @@
+package proofservice
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/sero-cash/go-czero-import/c_type"
+	"github.com/sero-cash/go-sero/crypto"
+	"github.com/sero-cash/go-sero/zero/txs/assets"
+	"github.com/sero-cash/go-sero/zero/txs/stx"
+	"github.com/sero-cash/go-sero/zero/txs/stx/stx_v1"
+	"github.com/sero-cash/go-sero/zero/txtool"
+	"github.com/sero-cash/go-sero/zero/utils"
+)
+
+func payParam(client, service c_type.PKr, amount uint64) *txtool.GTxParam {
+	param := &txtool.GTxParam{From: txtool.Kr{PKr: client}}
+	param.Outs = []txtool.GOut{{PKr: c_type.PKr{7}}, {PKr: service}}
+	param.Outs[1].Asset.Tkn = &assets.Token{Currency: sero, Value: utils.NewU256(amount)}
+	return param
+}
+
+func TestQuoteAndCredit(t *testing.T) {
+	defer func(verify func(*stx.T) bool) { verifyFrom = verify }(verifyFrom)
+	verifyFrom = func(tx *stx.T) bool { return tx.Sign == c_type.Uint512{1} }
+
+	key, _ := crypto.GenerateKey()
+	service := testPKr(1)
+	config := &Config{
+		PKr:      service,
+		Fee:      ServiceFee{ZinFee: big.NewInt(0), OinFee: big.NewInt(0), OutFee: big.NewInt(10), FixedFee: big.NewInt(0)},
+		QuoteKey: key,
+		QuoteTTL: time.Minute,
+		Credit:   true,
+
+		CreditConfirmations: 12,
+	}
+	backend := &testBackend{committed: map[c_type.Uint256]bool{}}
+	proof := &ProofService{config: config, storage: newMapStorage(), queueChan: make(chan *Job, 10), client: NewLocalClient(backend)}
+
+	client := testPKr(2)
+	quote, err := proof.Quote(payParam(client, service, 0))
+	if err != nil || quote.Fee.ToInt().Int64() != 10 || quote.Outs != 1 {
+		t.Fatal(quote, err)
+	}
+	if _, _, err := proof.checkFee(payParam(client, service, 10), quote); err != nil {
+		t.Fatal(err)
+	}
+	forged := *quote
+	forged.Outs = 0
+	if _, _, err := proof.checkFee(payParam(client, service, 10), &forged); err == nil {
+		t.Fatal("forged quote accepted")
+	}
+
+	submit := func(i byte, param *txtool.GTxParam) (*Job, error) {
+		tx := &stx.T{From: client, Sign: c_type.Uint512{1}}
+		tx.Tx1.Outs_P = []stx_v1.Out_P{{Memo: c_type.Uint512{i}}}
+		if err := proof.SubmitWork(tx, param, nil); err != nil {
+			return nil, err
+		}
+		job := <-proof.queueChan
+		return job, nil
+	}
+
+	if _, err := submit(1, payParam(client, service, 5)); err == nil {
+		t.Fatal("job without credit accepted")
+	}
+	if _, err := submit(2, payParam(testPKr(3), service, 100)); err == nil {
+		t.Fatal("deposit to another client accepted")
+	}
+	job, err := submit(2, payParam(client, service, 100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof.finish(job, &txtool.GTx{Tx: *job.tx}, "")
+	proof.creditDeposits()
+	if account := proof.Account(client); account.Balance.Sign() != 0 {
+		t.Fatalf("deposit before confirmation %+v", account)
+	}
+	backend.confirmations = config.CreditConfirmations
+	proof.creditDeposits()
+	proof.creditDeposits()
+	if account := proof.Account(client); account.Balance.Int64() != 90 || account.Deposited.Int64() != 90 {
+		t.Fatalf("deposit %+v", account)
+	}
+
+	job, err = submit(3, payParam(client, service, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if account := proof.Account(client); account.Balance.Int64() != 80 || account.Spent.Int64() != 10 || account.Jobs != 1 {
+		t.Fatalf("charge %+v", account)
+	}
+	proof.finish(job, nil, "invalid")
+	if account := proof.Account(client); account.Balance.Int64() != 90 || account.Spent.Int64() != 0 || account.Jobs != 0 {
+		t.Fatalf("refund %+v", account)
+	}
+}
+
+func testPKr(i byte) (pkr c_type.PKr) {
+	pkr[0] = i
+	return
+}
@@ -0,0 +1,335 @@
+package proofservice
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sero-cash/go-czero-import/c_type"
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/log"
+	"github.com/sero-cash/go-sero/serodb"
+	"github.com/sero-cash/go-sero/zero/txs/stx"
+	"github.com/sero-cash/go-sero/zero/txtool"
+)
+
+type Storage interface {
+	Exists(common.Hash) bool
+	Save(job *Job)
+	Get(hash common.Hash) *Job
+	Delete(hash common.Hash)
+	// List returns the jobs, the oldest first.
+	List() []*Job
+	// Evict drops the finished jobs last updated before the time.
+	Evict(before time.Time) int
+	// Account returns the credit of the client, empty when it has none.
+	Account(client c_type.PKr) Account
+	SaveAccount(client c_type.PKr, account Account)
+}
+
+type MapStorage struct {
+	lock     sync.RWMutex
+	cache    map[common.Hash]*Job
+	accounts map[c_type.PKr]Account
+}
+
+func newMapStorage() *MapStorage {
+	return &MapStorage{cache: make(map[common.Hash]*Job), accounts: make(map[c_type.PKr]Account)}
+}
+
+func (storage *MapStorage) Exists(hash common.Hash) bool {
+	storage.lock.RLock()
+	defer storage.lock.RUnlock()
+	_, ok := storage.cache[hash]
+	return ok
+}
+
+func (storage *MapStorage) Save(job *Job) {
+	storage.lock.Lock()
+	defer storage.lock.Unlock()
+	ret := *job
+	storage.cache[job.Hash] = &ret
+}
+
+func (storage *MapStorage) Get(hash common.Hash) *Job {
+	storage.lock.RLock()
+	defer storage.lock.RUnlock()
+	if job, ok := storage.cache[hash]; ok {
+		ret := *job
+		return &ret
+	}
+	return nil
+}
+
+func (storage *MapStorage) Delete(hash common.Hash) {
+	storage.lock.Lock()
+	defer storage.lock.Unlock()
+	delete(storage.cache, hash)
+}
+
+func (storage *MapStorage) List() (jobs []*Job) {
+	storage.lock.RLock()
+	defer storage.lock.RUnlock()
+	for _, job := range storage.cache {
+		ret := *job
+		jobs = append(jobs, &ret)
+	}
+	sortJobs(jobs)
+	return
+}
+
+func (storage *MapStorage) Evict(before time.Time) (count int) {
+	storage.lock.Lock()
+	defer storage.lock.Unlock()
+	for hash, job := range storage.cache {
+		if job.finished() && job.Updated.Before(before) {
+			delete(storage.cache, hash)
+			count++
+		}
+	}
+	return
+}
+
+func (storage *MapStorage) Account(client c_type.PKr) Account {
+	storage.lock.RLock()
+	defer storage.lock.RUnlock()
+	account, ok := storage.accounts[client]
+	if !ok {
+		return newAccount()
+	}
+	return Account{
+		new(big.Int).Set(account.Balance),
+		new(big.Int).Set(account.Deposited),
+		new(big.Int).Set(account.Spent),
+		account.Jobs,
+	}
+}
+
+func (storage *MapStorage) SaveAccount(client c_type.PKr, account Account) {
+	storage.lock.Lock()
+	defer storage.lock.Unlock()
+	storage.accounts[client] = account
+}
+
+var (
+	jobPrefix     = []byte("JOB")
+	accountPrefix = []byte("CREDIT")
+)
+
+func jobKey(hash common.Hash) []byte {
+	return append(append([]byte{}, jobPrefix...), hash[:]...)
+}
+
+// storedJob is a job in the db. The tx and its param hold the secrets of
+// the inputs, they are sealed with the job key until the job is finished so
+// that a restart can prove it, and not kept at all without a key.
+type storedJob struct {
+	Hash      common.Hash
+	SignHash  common.Hash
+	TxHash    common.Hash
+	State     string
+	Reason    string
+	Timestamp time.Time
+	Updated   time.Time
+	Client    c_type.PKr
+	Charge    *big.Int `json:",omitempty"`
+	Deposit   *big.Int `json:",omitempty"`
+	Credited  bool     `json:",omitempty"`
+	Sealed    []byte   `json:",omitempty"`
+}
+
+type sealedWork struct {
+	Tx    *stx.T
+	Param *txtool.GTxParam
+}
+
+// DBStorage keeps the jobs in a LevelDB database only its owner can read.
+type DBStorage struct {
+	db   *serodb.LDBDatabase
+	aead cipher.AEAD
+}
+
+// NewDBStorage opens the jobs in dir, key is the AES key sealing the work of
+// the unfinished jobs, which is lost on restart when it is nil.
+func NewDBStorage(dir string, key []byte) (*DBStorage, error) {
+	storage := &DBStorage{}
+	if key != nil {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		if storage.aead, err = cipher.NewGCM(block); err != nil {
+			return nil, err
+		}
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(dir, 0700); err != nil {
+		return nil, err
+	}
+	db, err := serodb.NewLDBDatabase(dir, 16, 16)
+	if err != nil {
+		return nil, err
+	}
+	storage.db = db
+	return storage, nil
+}
+
+func (storage *DBStorage) seal(job *Job) ([]byte, error) {
+	data, err := json.Marshal(&sealedWork{job.tx, job.param})
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, storage.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return storage.aead.Seal(nonce, nonce, data, job.Hash[:]), nil
+}
+
+func (storage *DBStorage) open(job *Job, sealed []byte) error {
+	size := storage.aead.NonceSize()
+	if len(sealed) < size {
+		return errors.New("sealed work truncated")
+	}
+	data, err := storage.aead.Open(nil, sealed[:size], sealed[size:], job.Hash[:])
+	if err != nil {
+		return err
+	}
+	var work sealedWork
+	if err := json.Unmarshal(data, &work); err != nil {
+		return err
+	}
+	job.tx, job.param = work.Tx, work.Param
+	return nil
+}
+
+func (storage *DBStorage) Close() {
+	storage.db.Close()
+}
+
+func (storage *DBStorage) Exists(hash common.Hash) bool {
+	ok, _ := storage.db.Has(jobKey(hash))
+	return ok
+}
+
+func (storage *DBStorage) Save(job *Job) {
+	stored := storedJob{job.Hash, job.SignHash, job.TxHash, job.State, job.Reason, job.Timestamp, job.Updated, job.Client, job.Charge, job.Deposit, job.Credited, nil}
+	if !job.finished() && storage.aead != nil && job.tx != nil {
+		sealed, err := storage.seal(job)
+		if err != nil {
+			log.Error("ProofService seal job", "hash", job.Hash, "err", err)
+			return
+		}
+		stored.Sealed = sealed
+	}
+	data, err := json.Marshal(&stored)
+	if err != nil {
+		log.Error("ProofService encode job", "hash", job.Hash, "err", err)
+		return
+	}
+	if err := storage.db.Put(jobKey(job.Hash), data); err != nil {
+		log.Error("ProofService save job", "hash", job.Hash, "err", err)
+	}
+}
+
+func (storage *DBStorage) decodeJob(data []byte) *Job {
+	var stored storedJob
+	if err := json.Unmarshal(data, &stored); err != nil {
+		log.Error("ProofService invalid job", "err", err)
+		return nil
+	}
+	job := &Job{
+		Hash:      stored.Hash,
+		SignHash:  stored.SignHash,
+		TxHash:    stored.TxHash,
+		State:     stored.State,
+		Reason:    stored.Reason,
+		Timestamp: stored.Timestamp,
+		Updated:   stored.Updated,
+		Client:    stored.Client,
+		Charge:    stored.Charge,
+		Deposit:   stored.Deposit,
+		Credited:  stored.Credited,
+	}
+	if stored.Sealed != nil && storage.aead != nil {
+		if err := storage.open(job, stored.Sealed); err != nil {
+			log.Error("ProofService open job", "hash", job.Hash, "err", err)
+		}
+	}
+	return job
+}
+
+func (storage *DBStorage) Get(hash common.Hash) *Job {
+	data, err := storage.db.Get(jobKey(hash))
+	if err != nil {
+		return nil
+	}
+	return storage.decodeJob(data)
+}
+
+func (storage *DBStorage) Delete(hash common.Hash) {
+	storage.db.Delete(jobKey(hash))
+}
+
+func (storage *DBStorage) List() (jobs []*Job) {
+	iterator := storage.db.NewIteratorWithPrefix(jobPrefix)
+	defer iterator.Release()
+	for iterator.Next() {
+		if job := storage.decodeJob(iterator.Value()); job != nil {
+			jobs = append(jobs, job)
+		}
+	}
+	sortJobs(jobs)
+	return
+}
+
+func (storage *DBStorage) Evict(before time.Time) (count int) {
+	batch := storage.db.NewBatch()
+	for _, job := range storage.List() {
+		if job.finished() && job.Updated.Before(before) {
+			batch.Delete(jobKey(job.Hash))
+			count++
+		}
+	}
+	if err := batch.Write(); err != nil {
+		log.Error("ProofService evict jobs", "err", err)
+		return 0
+	}
+	return
+}
+
+func accountKey(client c_type.PKr) []byte {
+	return append(append([]byte{}, accountPrefix...), client[:]...)
+}
+
+func (storage *DBStorage) Account(client c_type.PKr) Account {
+	account := newAccount()
+	data, err := storage.db.Get(accountKey(client))
+	if err != nil {
+		return account
+	}
+	if err := json.Unmarshal(data, &account); err != nil {
+		log.Error("ProofService invalid account", "err", err)
+		return newAccount()
+	}
+	return account
+}
+
+func (storage *DBStorage) SaveAccount(client c_type.PKr, account Account) {
+	data, err := json.Marshal(&account)
+	if err != nil {
+		log.Error("ProofService encode account", "err", err)
+		return
+	}
+	if err := storage.db.Put(accountKey(client), data); err != nil {
+		log.Error("ProofService save account", "err", err)
+	}
+}
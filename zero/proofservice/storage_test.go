@@ -0,0 +1,97 @@
+package proofservice
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/sero-cash/go-czero-import/c_type"
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/zero/txs/stx"
+	"github.com/sero-cash/go-sero/zero/txs/stx/stx_v1"
+	"github.com/sero-cash/go-sero/zero/txtool"
+)
+
+func TestDBStorage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "proofservice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	storage, err := NewDBStorage(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer storage.Close()
+
+	old := time.Now().Add(-time.Hour)
+	storage.Save(&Job{Hash: common.Hash{1}, State: JobCommitted, TxHash: common.Hash{9}, Timestamp: old, Updated: old})
+	storage.Save(&Job{Hash: common.Hash{2}, State: JobFailed, Reason: "invalid", Timestamp: old.Add(time.Second), Updated: time.Now()})
+	storage.Save(&Job{Hash: common.Hash{3}, State: JobProving, Timestamp: old.Add(2 * time.Second), Updated: old})
+
+	if job := storage.Get(common.Hash{1}); job == nil || job.TxHash != (common.Hash{9}) || job.State != JobCommitted {
+		t.Fatalf("job %+v", job)
+	}
+	if jobs := storage.List(); len(jobs) != 3 || jobs[0].Hash != (common.Hash{1}) || jobs[1].Reason != "invalid" {
+		t.Fatalf("jobs %+v", jobs)
+	}
+	if count := storage.Evict(time.Now().Add(-time.Minute)); count != 1 || storage.Exists(common.Hash{1}) {
+		t.Fatal("evicted", count)
+	}
+
+	proof := &ProofService{storage: storage, queueChan: make(chan *Job, 1)}
+	proof.resume()
+	if job := storage.Get(common.Hash{3}); job.State != JobFailed {
+		t.Fatal("job without its tx resumed", job.State)
+	}
+	if jobs := proof.Jobs(JobFailed); len(jobs) != 2 {
+		t.Fatal("failed jobs", len(jobs))
+	}
+}
+
+func TestDBStorageSealsWork(t *testing.T) {
+	dir, err := ioutil.TempDir("", "proofservice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	key := make([]byte, 32)
+	storage, err := NewDBStorage(dir, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(dir); err != nil || info.Mode().Perm() != 0700 {
+		t.Fatal("dir mode", info.Mode(), err)
+	}
+
+	tx := &stx.T{}
+	tx.Tx1.Outs_P = []stx_v1.Out_P{{Memo: c_type.Uint512{1}}}
+	param := &txtool.GTxParam{Ins: []txtool.GIn{{SKr: c_type.PKr{0xab, 0xcd, 0xef}}}}
+	job := newJob(tx, param)
+	storage.Save(job)
+
+	data, _ := storage.db.Get(jobKey(job.Hash))
+	if bytes.Contains(data, []byte("SKr")) {
+		t.Fatal("work stored in plain text")
+	}
+	if got := storage.Get(job.Hash); got == nil || got.tx == nil || got.param == nil || got.param.Ins[0].SKr != param.Ins[0].SKr {
+		t.Fatal("sealed work not opened")
+	}
+	storage.Close()
+
+	// without the key the work is lost
+	storage, err = NewDBStorage(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer storage.Close()
+	if got := storage.Get(job.Hash); got == nil || got.tx != nil {
+		t.Fatal("work opened without the key")
+	}
+	proof := &ProofService{storage: storage}
+	if got := proof.Job(job.SignHash); got == nil || got.Hash != job.Hash {
+		t.Fatal("job not found by the signed hash")
+	}
+}
@@ -0,0 +1,113 @@
+package proofservice
+
+import (
+	"context"
+	"time"
+
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/log"
+	"github.com/sero-cash/go-sero/rpc"
+	"github.com/sero-cash/go-sero/zero/txs/stx"
+	"github.com/sero-cash/go-sero/zero/txtool"
+	"github.com/sero-cash/go-sero/zero/txtool/flight"
+)
+
+// WorkerAPI is the proofworker RPC namespace the worker processes call.
+type WorkerAPI struct {
+	proof *ProofService
+}
+
+func NewWorkerAPI(proof *ProofService) *WorkerAPI {
+	return &WorkerAPI{proof}
+}
+
+func (api *WorkerAPI) Register(name string) (WorkerRegistration, error) {
+	return api.proof.RegisterWorker(name)
+}
+
+func (api *WorkerAPI) Heartbeat(id string) error {
+	return api.proof.Heartbeat(id)
+}
+
+func (api *WorkerAPI) FetchJob(id string) (*WorkerJob, error) {
+	return api.proof.FetchJob(id)
+}
+
+func (api *WorkerAPI) SubmitResult(id string, hash common.Hash, gtx *txtool.GTx, reason string) error {
+	return api.proof.SubmitResult(id, hash, gtx, reason)
+}
+
+// ProveFunc proves the tx of a job.
+type ProveFunc func(tx *stx.T, param *txtool.GTxParam) (txtool.GTx, error)
+
+// Worker proves the jobs of a proof service it reaches over RPC.
+type Worker struct {
+	client *rpc.Client
+	name   string
+	prove  ProveFunc
+}
+
+// NewWorker makes a worker proving with prove, flight.ProveTx1 when nil.
+func NewWorker(client *rpc.Client, name string, prove ProveFunc) *Worker {
+	if prove == nil {
+		prove = flight.ProveTx1
+	}
+	return &Worker{client, name, prove}
+}
+
+// Run registers the worker and proves the jobs until quit is closed.
+func (worker *Worker) Run(quit chan struct{}) error {
+	var registration WorkerRegistration
+	if err := worker.client.Call(&registration, "proofworker_register", worker.name); err != nil {
+		return err
+	}
+	id := registration.Id
+	log.Info("Proof worker registered", "id", id)
+
+	go func() {
+		ticker := time.NewTicker(registration.LeaseTimeout / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := worker.client.Call(nil, "proofworker_heartbeat", id); err != nil {
+					log.Error("Proof worker heartbeat", "err", err)
+				}
+			case <-quit:
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-quit:
+			return nil
+		default:
+		}
+		var job *WorkerJob
+		ctx, cancel := context.WithTimeout(context.Background(), 2*fetchWait)
+		err := worker.client.CallContext(ctx, &job, "proofworker_fetchJob", id)
+		cancel()
+		if err != nil {
+			log.Error("Proof worker fetch", "err", err)
+			select {
+			case <-quit:
+				return nil
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+		if job == nil {
+			continue
+		}
+		reason := ""
+		gtx, err := worker.prove(job.Tx, job.Param)
+		if err != nil {
+			reason = err.Error()
+		}
+		if err := worker.client.Call(nil, "proofworker_submitResult", id, job.Hash, &gtx, reason); err != nil {
+			log.Error("Proof worker submit", "hash", job.Hash, "err", err)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+package proofservice
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sero-cash/go-czero-import/seroparam"
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/log"
+	"github.com/sero-cash/go-sero/zero/txs/stx"
+	"github.com/sero-cash/go-sero/zero/txtool"
+	"github.com/sero-cash/go-sero/zero/txtool/verify"
+)
+
+const (
+	defaultLeaseTimeout = 30 * time.Second
+	// fetchWait is how long a fetch waits for a job before it returns none.
+	fetchWait = 5 * time.Second
+	// a worker silent for this many lease timeouts is dropped with its stats
+	forgetLeases = 10
+)
+
+var errUnknownWorker = errors.New("unknown worker")
+
+// verifyTx checks the proofs of a returned tx, the tests replace it.
+var verifyTx = verify.VerifyWithoutState
+
+// WorkerStats is the throughput of a registered worker.
+type WorkerStats struct {
+	Id         string
+	Name       string
+	Registered time.Time
+	LastSeen   time.Time
+	Leases     int
+	Done       uint64
+	Failed     uint64
+	Expired    uint64
+	// ProveTime sums the time from the fetch to the result of the done jobs.
+	ProveTime time.Duration
+}
+
+// WorkerJob is a job leased to a worker.
+type WorkerJob struct {
+	Hash  common.Hash
+	Tx    *stx.T
+	Param *txtool.GTxParam
+}
+
+// WorkerRegistration tells a worker its id and how often to heartbeat.
+type WorkerRegistration struct {
+	Id           string
+	LeaseTimeout time.Duration
+}
+
+type lease struct {
+	job      *Job
+	worker   string
+	start    time.Time
+	deadline time.Time
+}
+
+type workerPool struct {
+	lock    sync.Mutex
+	workers map[string]*WorkerStats
+	leases  map[common.Hash]*lease
+	timeout time.Duration
+}
+
+func newWorkerPool(timeout time.Duration) *workerPool {
+	return &workerPool{workers: map[string]*WorkerStats{}, leases: map[common.Hash]*lease{}, timeout: timeout}
+}
+
+// seen extends the leases of the worker, the caller holds the lock.
+func (pool *workerPool) seen(id string) (*WorkerStats, error) {
+	worker, ok := pool.workers[id]
+	if !ok {
+		return nil, errUnknownWorker
+	}
+	now := time.Now()
+	worker.LastSeen = now
+	for _, lease := range pool.leases {
+		if lease.worker == id {
+			lease.deadline = now.Add(pool.timeout)
+		}
+	}
+	return worker, nil
+}
+
+// RegisterWorker adds a worker process to the pool.
+func (proof *ProofService) RegisterWorker(name string) (registration WorkerRegistration, e error) {
+	if proof.pool == nil {
+		e = errors.New("proof service proves in process")
+		return
+	}
+	id := make([]byte, 8)
+	if _, e = rand.Read(id); e != nil {
+		return
+	}
+	now := time.Now()
+	registration = WorkerRegistration{hex.EncodeToString(id), proof.pool.timeout}
+	proof.pool.lock.Lock()
+	proof.pool.workers[registration.Id] = &WorkerStats{Id: registration.Id, Name: name, Registered: now, LastSeen: now}
+	proof.pool.lock.Unlock()
+	log.Info("ProofService worker registered", "id", registration.Id, "name", name)
+	return
+}
+
+// Heartbeat keeps the worker and its leases alive.
+func (proof *ProofService) Heartbeat(id string) error {
+	if proof.pool == nil {
+		return errUnknownWorker
+	}
+	proof.pool.lock.Lock()
+	defer proof.pool.lock.Unlock()
+	_, err := proof.pool.seen(id)
+	return err
+}
+
+// FetchJob leases a queued job to the worker, it waits a while for one and
+// returns nil when none came.
+func (proof *ProofService) FetchJob(id string) (*WorkerJob, error) {
+	if err := proof.Heartbeat(id); err != nil {
+		return nil, err
+	}
+	var job *Job
+	select {
+	case job = <-proof.queueChan:
+	case <-time.After(fetchWait):
+		return nil, nil
+	}
+
+	proof.pool.lock.Lock()
+	worker, err := proof.pool.seen(id)
+	if err != nil {
+		proof.pool.lock.Unlock()
+		proof.requeue(job)
+		return nil, err
+	}
+	now := time.Now()
+	proof.pool.leases[job.Hash] = &lease{job, id, now, now.Add(proof.pool.timeout)}
+	worker.Leases++
+	proof.pool.lock.Unlock()
+
+	job.setState(JobProving, "")
+	proof.storage.Save(job)
+	return &WorkerJob{job.Hash, job.tx, job.param}, nil
+}
+
+// SubmitResult finishes the job leased to the worker with the proven tx, or
+// fails it with the reason.
+func (proof *ProofService) SubmitResult(id string, hash common.Hash, gtx *txtool.GTx, reason string) error {
+	if proof.pool == nil {
+		return errUnknownWorker
+	}
+	proof.pool.lock.Lock()
+	worker, err := proof.pool.seen(id)
+	if err != nil {
+		proof.pool.lock.Unlock()
+		return err
+	}
+	lease, ok := proof.pool.leases[hash]
+	if !ok || lease.worker != id {
+		proof.pool.lock.Unlock()
+		return errors.New("job not leased to the worker")
+	}
+	delete(proof.pool.leases, hash)
+	worker.Leases--
+	proof.pool.lock.Unlock()
+
+	job := lease.job
+	if reason == "" && gtx == nil {
+		reason = "no tx returned"
+	}
+	if reason == "" {
+		if err := verifyResult(job, gtx); err != nil {
+			reason = err.Error()
+			log.Warn("ProofService worker returned an invalid tx", "id", id, "name", worker.Name, "hash", hash, "err", err)
+		}
+	}
+	proof.finish(job, gtx, reason)
+
+	proof.pool.lock.Lock()
+	if job.State == JobFailed {
+		worker.Failed++
+	} else {
+		worker.Done++
+		worker.ProveTime += time.Since(lease.start)
+	}
+	proof.pool.lock.Unlock()
+	return nil
+}
+
+// verifyResult checks that the tx a worker returned is the tx of the job
+// with valid proofs, a worker can not get a tx committed that was not asked.
+func verifyResult(job *Job, gtx *txtool.GTx) error {
+	if gtx.Tx.Tx1.Tx1_Hash() != *job.Hash.HashToUint256() {
+		return errors.New("returned tx is not the tx of the job")
+	}
+	num := seroparam.SIP5()
+	if job.param.Num != nil {
+		num = *job.param.Num
+	} else if job.param.IsExt != nil && *job.param.IsExt {
+		num = seroparam.SIP7()
+	}
+	if err := verifyTx(&job.tx.Ehash, &gtx.Tx, num); err != nil {
+		return fmt.Errorf("returned tx is not valid: %v", err)
+	}
+	return nil
+}
+
+// Workers returns the stats of the registered workers.
+func (proof *ProofService) Workers() (workers []WorkerStats) {
+	if proof.pool == nil {
+		return
+	}
+	proof.pool.lock.Lock()
+	defer proof.pool.lock.Unlock()
+	for _, worker := range proof.pool.workers {
+		workers = append(workers, *worker)
+	}
+	sort.Slice(workers, func(i, j int) bool {
+		return workers[i].Registered.Before(workers[j].Registered)
+	})
+	return
+}
+
+func (proof *ProofService) requeue(job *Job) {
+	job.setState(JobQueued, "")
+	proof.storage.Save(job)
+	if !TryEnqueue(job, proof.queueChan) {
+		go func() {
+			proof.queueChan <- job
+		}()
+	}
+}
+
+// expireLeases queues again the jobs of the leases past their deadline and
+// drops the workers gone silent.
+func (proof *ProofService) expireLeases() {
+	var expired []*Job
+	now := time.Now()
+	proof.pool.lock.Lock()
+	for hash, lease := range proof.pool.leases {
+		if now.After(lease.deadline) {
+			delete(proof.pool.leases, hash)
+			if worker, ok := proof.pool.workers[lease.worker]; ok {
+				worker.Leases--
+				worker.Expired++
+			}
+			expired = append(expired, lease.job)
+		}
+	}
+	for id, worker := range proof.pool.workers {
+		if worker.Leases == 0 && now.Sub(worker.LastSeen) > forgetLeases*proof.pool.timeout {
+			delete(proof.pool.workers, id)
+			log.Warn("ProofService worker gone", "id", id, "name", worker.Name)
+		}
+	}
+	proof.pool.lock.Unlock()
+
+	for _, job := range expired {
+		log.Warn("ProofService lease expired", "hash", job.Hash)
+		proof.requeue(job)
+	}
+}
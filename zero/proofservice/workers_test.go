@@ -0,0 +1,154 @@
+package proofservice
+
+import (
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sero-cash/go-czero-import/c_type"
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/rpc"
+	"github.com/sero-cash/go-sero/zero/txs/stx"
+	"github.com/sero-cash/go-sero/zero/txs/stx/stx_v1"
+	"github.com/sero-cash/go-sero/zero/txtool"
+	"github.com/sero-cash/go-sero/zero/wallet/light"
+)
+
+type testBackend struct {
+	lock          sync.Mutex
+	committed     map[c_type.Uint256]bool
+	confirmations uint64
+}
+
+func (self *testBackend) CommitTx(tx *txtool.GTx) error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.committed[tx.Tx.Tx1.Tx1_Hash()] = true
+	return nil
+}
+
+func (self *testBackend) CheckNil(Nils []c_type.Uint256) (nilResps []light.NilValue, e error) {
+	return
+}
+
+func (self *testBackend) TxConfirmations(hash common.Hash) (uint64, error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return self.confirmations, nil
+}
+
+func (self *testBackend) count() int {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return len(self.committed)
+}
+
+func TestWorkers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "proofworkers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// the stub of the proofs does not verify, the tx with the memo 0xff
+	// stands for a bad proof
+	defer func(orig func(*c_type.Uint256, *stx.T, uint64) error) { verifyTx = orig }(verifyTx)
+	verifyTx = func(ehash *c_type.Uint256, tx *stx.T, num uint64) error {
+		if tx.Tx1.Outs_P[0].Memo[0] == 0xff {
+			return errors.New("bad proof")
+		}
+		return nil
+	}
+
+	backend := &testBackend{committed: map[c_type.Uint256]bool{}}
+	fee := ServiceFee{ZinFee: big.NewInt(0), OinFee: big.NewInt(0), OutFee: big.NewInt(0), FixedFee: big.NewInt(0)}
+	service := NewProofService("", backend, &Config{MaxQueueNumber: 20, Fee: fee, Workers: true, LeaseTimeout: 300 * time.Millisecond})
+
+	ipcPath := filepath.Join(dir, "worker.ipc")
+	listener, _, err := rpc.StartIPCEndpoint(ipcPath, []rpc.API{{Namespace: "proofworker", Version: "1.0", Service: NewWorkerAPI(service)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	const jobs = 12
+	for i := 0; i < jobs; i++ {
+		tx := &stx.T{}
+		tx.Tx1.Outs_P = []stx_v1.Out_P{{Memo: c_type.Uint512{byte(i + 1)}}}
+		if err := service.SubmitWork(tx, &txtool.GTxParam{}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the dead worker leases a job and never comes back
+	dead, err := rpc.Dial(ipcPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dead.Close()
+	var registration WorkerRegistration
+	if err := dead.Call(&registration, "proofworker_register", "dead"); err != nil {
+		t.Fatal(err)
+	}
+	var leased *WorkerJob
+	if err := dead.Call(&leased, "proofworker_fetchJob", registration.Id); err != nil || leased == nil {
+		t.Fatal(leased, err)
+	}
+
+	quit := make(chan struct{})
+	defer close(quit)
+	prove := func(tx *stx.T, param *txtool.GTxParam) (txtool.GTx, error) {
+		time.Sleep(20 * time.Millisecond)
+		return txtool.GTx{Tx: *tx}, nil
+	}
+	for i := 0; i < 3; i++ {
+		client, err := rpc.Dial(ipcPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer client.Close()
+		go NewWorker(client, "worker", prove).Run(quit)
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+	for backend.count() < jobs {
+		if time.Now().After(deadline) {
+			t.Fatal("committed", backend.count())
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if job := service.Job(leased.Hash); job == nil || job.State != JobCommitted {
+		t.Fatalf("leased job %+v", job)
+	}
+
+	var done, expired uint64
+	for _, stats := range service.Workers() {
+		done += stats.Done
+		expired += stats.Expired
+	}
+	if done != jobs || expired != 1 {
+		t.Fatal("done", done, "expired", expired)
+	}
+
+	tx := &stx.T{}
+	tx.Tx1.Outs_P = []stx_v1.Out_P{{Memo: c_type.Uint512{0xff}}}
+	if err := service.SubmitWork(tx, &txtool.GTxParam{}, nil); err != nil {
+		t.Fatal(err)
+	}
+	hash := newJob(tx, nil).Hash
+	for deadline := time.Now().Add(10 * time.Second); ; time.Sleep(50 * time.Millisecond) {
+		if job := service.Job(hash); job != nil && job.State == JobFailed {
+			break
+		} else if time.Now().After(deadline) {
+			t.Fatalf("job with a bad proof %+v", job)
+		}
+	}
+	if backend.count() != jobs {
+		t.Fatal("committed a bad proof")
+	}
+}
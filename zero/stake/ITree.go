@@ -33,6 +33,19 @@ type Node struct {
 	factor int
 }
 
+// NewNode returns a tree node holding num tickets of the share key.
+func NewNode(key common.Hash, num uint32) *Node {
+	return &Node{key: key, num: num, total: num, factor: 1}
+}
+
+func (node *Node) Key() common.Hash {
+	return node.key
+}
+
+func (node *Node) Num() uint32 {
+	return node.num
+}
+
 func (node *Node) Print() {
 	padn := "|"
 	// for i := 2; i <= node.height; i++ {
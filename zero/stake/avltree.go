@@ -262,6 +262,32 @@ func (tree *AVLTree) FindByIndex(index uint32) (*Node, error) {
 	}
 }
 
+// IndexOf returns the index of the first ticket of the share key, the
+// tickets of a share take the indexes up to IndexOf+num.
+func (tree *AVLTree) IndexOf(key common.Hash) (uint32, bool) {
+	index := uint32(0)
+	node := tree.newRootNode()
+	for node != nil {
+		left := node.left(tree.state)
+		if node.key == key {
+			if left != nil {
+				index += left.total
+			}
+			return index, true
+		}
+		if cmp(node.key, key) > 0 {
+			node = left
+		} else {
+			if left != nil {
+				index += left.total
+			}
+			index += node.num
+			node = node.right(tree.state)
+		}
+	}
+	return 0, false
+}
+
 func (tree *AVLTree) Delete(key common.Hash, num uint32) *Node {
 	rootNode := tree.newRootNode()
 	node, ret := tree.delete(rootNode, key, num)
@@ -0,0 +1,117 @@
+package stake
+
+import (
+	"bytes"
+	"math/big"
+
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/log"
+	"github.com/sero-cash/go-sero/rlp"
+	"github.com/sero-cash/go-sero/serodb"
+	"github.com/sero-cash/go-sero/zero/zconfig"
+)
+
+type EventKind uint8
+
+const (
+	// EVENT_SOLO is the reward of a share voted by its owner.
+	EVENT_SOLO EventKind = iota + 1
+	// EVENT_POOL is the part of a pool vote reward paid to the share.
+	EVENT_POOL
+	// EVENT_POOLFEE is the part of a pool vote reward kept by the pool.
+	EVENT_POOLFEE
+	// EVENT_REMEDY is paid to the miner of the block holding parent votes,
+	// it is recorded against the coinbase and not a share.
+	EVENT_REMEDY
+	// EVENT_MISSED returns the votes a share missed to its owner.
+	EVENT_MISSED
+	// EVENT_EXPIRED returns the tickets never selected to the owner.
+	EVENT_EXPIRED
+)
+
+var eventKindNames = map[EventKind]string{
+	EVENT_SOLO:    "solo",
+	EVENT_POOL:    "pool",
+	EVENT_POOLFEE: "poolFee",
+	EVENT_REMEDY:  "remedy",
+	EVENT_MISSED:  "missed",
+	EVENT_EXPIRED: "expired",
+}
+
+func (self EventKind) String() string {
+	if name, ok := eventKindNames[self]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// RewardEvent is one reward or refund of a share made by ProcessBeforeApply,
+// PoolId is set for the shares of a pool. A remedy has no share, Coinbase is
+// the miner it pays.
+type RewardEvent struct {
+	Kind     EventKind
+	Id       common.Hash
+	PoolId   *common.Hash `rlp:"nil"`
+	Amount   *big.Int     `rlp:"nil"`
+	Count    uint32
+	Coinbase *common.Address `rlp:"nil"`
+}
+
+var blockEventsPrefix = []byte("STAKE$BLOCKEVENTS$")
+
+func blockEventsKey(hash common.Hash) []byte {
+	return append(append([]byte{}, blockEventsPrefix...), hash[:]...)
+}
+
+func (self *StakeState) addEvent(kind EventKind, share *Share, amount *big.Int, count uint32) {
+	if !zconfig.RecordStakeEvents() {
+		return
+	}
+	self.events = append(self.events, RewardEvent{
+		Kind:   kind,
+		Id:     common.BytesToHash(share.Id()),
+		PoolId: share.PoolId,
+		Amount: new(big.Int).Set(amount),
+		Count:  count,
+	})
+}
+
+func (self *StakeState) addRemedyEvent(coinbase common.Address, amount *big.Int, count uint32) {
+	if !zconfig.RecordStakeEvents() {
+		return
+	}
+	self.events = append(self.events, RewardEvent{
+		Kind:     EVENT_REMEDY,
+		Amount:   new(big.Int).Set(amount),
+		Count:    count,
+		Coinbase: &coinbase,
+	})
+}
+
+// Events returns the reward events of the last ProcessBeforeApply, always
+// empty unless the node records stake events.
+func (self *StakeState) Events() []RewardEvent {
+	return self.events
+}
+
+func WriteBlockEvents(putter serodb.Putter, hash common.Hash, events []RewardEvent) error {
+	data, err := rlp.EncodeToBytes(events)
+	if err != nil {
+		return err
+	}
+	return putter.Put(blockEventsKey(hash), data)
+}
+
+// GetBlockEvents returns the reward events recorded for the block, false when
+// the block was not recorded.
+func GetBlockEvents(getter serodb.Getter, hash common.Hash) (events []RewardEvent, ok bool) {
+	data, _ := getter.Get(blockEventsKey(hash))
+	if len(data) == 0 {
+		return
+	}
+	if err := rlp.Decode(bytes.NewReader(data), &events); err != nil {
+		log.Error("Invalid block events RLP", "hash", hash, "err", err)
+		return
+	}
+	return events, true
+}
@@ -0,0 +1,95 @@
+package stake
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/core/types"
+	"github.com/sero-cash/go-sero/crypto"
+	"github.com/sero-cash/go-sero/serodb"
+	"github.com/sero-cash/go-sero/zero/consensus"
+)
+
+// The prefixes of the state hashes of the shares and pools in the state trie.
+const (
+	shareObjPre = "STAKE$SHAREOBJ$CONS"
+	poolObjPre  = "STAKE$POOL$CONS"
+)
+
+// StakeProof holds a share at a block with its pool and the nodes of the
+// state trie proving their records.
+type StakeProof struct {
+	Share      *Share
+	ShareNodes [][]byte
+	Pool       *StakePool `rlp:"nil"`
+	PoolNodes  [][]byte
+}
+
+func proveObj(obj *consensus.ObjPoint, id common.Hash) (nodes [][]byte, e error) {
+	db := serodb.NewMemDatabase()
+	if e = obj.ProveObj(id[:], db); e != nil {
+		return
+	}
+	for _, key := range db.Keys() {
+		node, _ := db.Get(key)
+		nodes = append(nodes, node)
+	}
+	return
+}
+
+// ProveShare proves the share, and its pool, in the state.
+func (self *StakeState) ProveShare(id common.Hash) (proof *StakeProof, e error) {
+	share := self.GetShare(id)
+	if share == nil {
+		e = errors.New("share not exists")
+		return
+	}
+	proof = &StakeProof{Share: share}
+	if proof.ShareNodes, e = proveObj(&self.shareObj, id); e != nil {
+		return
+	}
+	if share.PoolId != nil {
+		if pool := self.GetStakePool(*share.PoolId); pool != nil {
+			proof.Pool = pool
+			proof.PoolNodes, e = proveObj(&self.stakePoolObj, *share.PoolId)
+		}
+	}
+	return
+}
+
+func verifyObj(root common.Hash, objPre string, item consensus.PItem, nodes [][]byte) error {
+	db := serodb.NewMemDatabase()
+	for _, node := range nodes {
+		db.Put(crypto.Keccak256(node), node)
+	}
+	state, err := consensus.VerifyObj(root, objPre, item.Id(), db)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(state, item.State()) {
+		return errors.New("record does not match the state")
+	}
+	return nil
+}
+
+// VerifyStakeProof checks the proof of the share against the state root of
+// the header, it needs nothing else.
+func VerifyStakeProof(header *types.Header, id common.Hash, proof *StakeProof) error {
+	if proof.Share == nil || !bytes.Equal(proof.Share.Id(), id[:]) {
+		return errors.New("proof is not for the share")
+	}
+	if err := verifyObj(header.Root, shareObjPre, proof.Share, proof.ShareNodes); err != nil {
+		return fmt.Errorf("share: %v", err)
+	}
+	if proof.Pool != nil {
+		if proof.Share.PoolId == nil || !bytes.Equal(proof.Pool.Id(), proof.Share.PoolId[:]) {
+			return errors.New("pool is not the pool of the share")
+		}
+		if err := verifyObj(header.Root, poolObjPre, proof.Pool, proof.PoolNodes); err != nil {
+			return fmt.Errorf("pool: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package stake
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/sero-cash/go-czero-import/c_type"
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/core/types"
+	"github.com/sero-cash/go-sero/rlp"
+)
+
+func TestProveShare(t *testing.T) {
+	state, stateDB := newState()
+	share := &Share{PKr: c_type.PKr{1}, Value: big.NewInt(10000), InitNum: 10, Num: 10}
+	state.AddPendingShare(share)
+	state.AddPendingShare(&Share{PKr: c_type.PKr{2}, Value: big.NewInt(10000), InitNum: 5, Num: 5})
+	header := &types.Header{Root: stateDB.IntermediateRoot(true)}
+
+	id := common.BytesToHash(share.Id())
+	proof, err := state.ProveShare(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := rlp.EncodeToBytes(proof)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof = &StakeProof{}
+	if err := rlp.DecodeBytes(data, proof); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyStakeProof(header, id, proof); err != nil {
+		t.Fatal(err)
+	}
+	proof.Share.Num = 9
+	if VerifyStakeProof(header, id, proof) == nil {
+		t.Fatal("changed share verified")
+	}
+	proof.Share.Num = 10
+	if VerifyStakeProof(&types.Header{Root: common.Hash{1}}, id, proof) == nil {
+		t.Fatal("verified against another root")
+	}
+	if _, err := state.ProveShare(common.Hash{1}); err == nil {
+		t.Fatal("proved a missing share")
+	}
+}
@@ -275,6 +275,8 @@ type StakeState struct {
 	missedNum    consensus.KVPoint
 	blockHash    consensus.KVPoint
 	newShareNum  consensus.KVPoint
+
+	events []RewardEvent
 }
 
 var (
@@ -359,8 +361,8 @@ func NewStakeState(statedb *state.StateDB) *StakeState {
 	stakeState := &StakeState{statedb: statedb}
 	stakeState.missedNum = consensus.NewKVPt(cons, "STAKE$EMISSEDNNUM$", "")
 	stakeState.sharePool = consensus.NewKVPt(cons, "STAKE$SHAREPOOL$CONS$", "")
-	stakeState.shareObj = consensus.NewObjPt(cons, "STAKE$SHAREOBJ$CONS", ShareDB.Pre, "share")
-	stakeState.stakePoolObj = consensus.NewObjPt(cons, "STAKE$POOL$CONS", StakePoolDB.Pre, "pool")
+	stakeState.shareObj = consensus.NewObjPt(cons, shareObjPre, ShareDB.Pre, "share")
+	stakeState.stakePoolObj = consensus.NewObjPt(cons, poolObjPre, StakePoolDB.Pre, "pool")
 	stakeState.blockHash = consensus.NewKVPt(cons, "BLOCK$BLOCKHASH$", "")
 	stakeState.newShareNum = consensus.NewKVPt(cons, "STAKE$NEWSHARENUM$", "")
 	return stakeState
@@ -624,10 +626,19 @@ func (self *StakeState) getShares(getter serodb.Getter, blockHash common.Hash, b
 func (self *StakeState) CurrentPrice() *big.Int {
 	tree := NewTree(self, 0)
 	newNum := self.getNewShareNum()
-	size := tree.Size() + newNum
+	return SharePrice(tree.Size() + newNum)
+}
+
+// SharePrice is the price of the next share when size tickets are in the pool.
+func SharePrice(size uint32) *big.Int {
 	return new(big.Int).Add(basePrice, new(big.Int).Mul(addition, big.NewInt(int64(size))))
 }
 
+// SumPrice is the cost of n shares bought when size tickets are in the pool.
+func SumPrice(size uint32, n int64) *big.Int {
+	return sum(SharePrice(size), addition, n)
+}
+
 func (self *StakeState) SumAmount(n int64) *big.Int {
 	return sum(self.CurrentPrice(), addition, n)
 }
@@ -851,12 +862,13 @@ func (self *StakeState) processRemedyRewards(bc blockChain, header *types.Header
 			soloReware, totalReward := self.StakeCurrentReward(parentHeader.Number)
 			reward := new(big.Int)
 			for _, vote := range parentHeader.ParentVotes {
+				remedy := new(big.Int).Div(soloReware, big.NewInt(3))
 				if vote.IsPool {
-					reward.Add(reward, new(big.Int).Div(totalReward, big.NewInt(3)))
-				} else {
-					reward.Add(reward, new(big.Int).Div(soloReware, big.NewInt(3)))
+					remedy = new(big.Int).Div(totalReward, big.NewInt(3))
 				}
+				reward.Add(reward, remedy)
 			}
+			self.addRemedyEvent(parentHeader.Coinbase, reward, uint32(len(parentHeader.ParentVotes)))
 			asset := assets.Asset{
 				&assets.Token{
 					utils.CurrencyToUint256("SERO"),
@@ -1070,16 +1082,19 @@ func (self *StakeState) rewardVote(vote types.HeaderVote, soloReware, reward *bi
 		share.addProfit(new(big.Int).Sub(reward, poolReward))
 		share.addIncome(new(big.Int).Add(share.Value, new(big.Int).Sub(reward, poolReward)))
 		self.updateStakePool(pool)
+		self.addEvent(EVENT_POOL, share, new(big.Int).Sub(reward, poolReward), 1)
+		self.addEvent(EVENT_POOLFEE, share, poolReward, 1)
 	} else {
 		share.addProfit(soloReware)
 		share.addIncome(new(big.Int).Add(share.Value, soloReware))
+		self.addEvent(EVENT_SOLO, share, soloReware, 1)
 	}
 	self.updateShare(share)
 	return nil
 }
 
 func (self *StakeState) processOutDate(header *types.Header, bc blockChain) (err error) {
-	outOfDatePeriod := getOutOfDateWindow()
+	outOfDatePeriod := GetOutOfDateWindow()
 	if header.Number.Uint64() < outOfDatePeriod || header.Number.Uint64()-outOfDatePeriod < seroparam.SIP4() {
 		return
 	}
@@ -1138,6 +1153,7 @@ func (self *StakeState) processOutDate(header *types.Header, bc blockChain) (err
 				}
 
 				share.addIncome(new(big.Int).Mul(share.Value, big.NewInt(int64(share.Num))))
+				self.addEvent(EVENT_EXPIRED, share, new(big.Int).Mul(share.Value, big.NewInt(int64(share.Num))), share.Num)
 				share.Status = STATUS_OUTOFDATE
 				self.updateShare(share)
 			}
@@ -1192,6 +1208,7 @@ func (self *StakeState) processMissVoted(header *types.Header, bc blockChain) (e
 				}
 
 				share.addIncome(new(big.Int).Mul(share.Value, big.NewInt(int64(share.WillVoteNum))))
+				self.addEvent(EVENT_MISSED, share, new(big.Int).Mul(share.Value, big.NewInt(int64(share.WillVoteNum))), share.WillVoteNum)
 				share.Status = STATUS_FINISHED
 				self.updateShare(share)
 			}
@@ -1234,7 +1251,7 @@ func (self *StakeState) processNowShares(header *types.Header, bc blockChain) (e
 }
 
 func (self *StakeState) payIncome(bc blockChain, header *types.Header) (err error) {
-	payPeriod := getPayPeriod()
+	payPeriod := GetPayPeriod()
 	if header.Number.Uint64() < payPeriod {
 		return
 	}
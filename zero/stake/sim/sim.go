@@ -0,0 +1,261 @@
+// Package sim estimates the return of a share purchase by running rounds of
+// the share selection and the rewards of the stake package.
+package sim
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/big"
+	"runtime"
+	"sync"
+
+	"github.com/sero-cash/go-czero-import/seroparam"
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/crypto"
+	"github.com/sero-cash/go-sero/zero/stake"
+	"github.com/sero-cash/go-sero/zero/utils"
+)
+
+const (
+	DefaultRounds = 100
+	// MaxRounds and MaxWork, the rounds times the blocks, bound the work of
+	// one simulation.
+	MaxRounds = 10000
+	MaxWork   = 20000000
+	// SyntheticShareSize is the tickets of each share of a synthetic pool.
+	SyntheticShareSize = 10
+	// MaxSyntheticSize bounds the tickets of a synthetic pool.
+	MaxSyntheticSize = 1000000
+)
+
+// Params describes the purchase to simulate.
+type Params struct {
+	// Tickets is the number of shares bought.
+	Tickets uint32
+	// Cost is paid for the tickets, by default the price at the pool size.
+	Cost *big.Int
+	// Pool votes the tickets through a stake pool taking Fee/10000 of the
+	// rewards, otherwise they are solo tickets.
+	Pool bool
+	Fee  uint16
+	// MissRate is the chance that a selected ticket misses its vote.
+	MissRate float64
+	// Start is the block the tickets enter the pool.
+	Start uint64
+	// Blocks is how long the tickets wait to be selected, the out of date
+	// window by default.
+	Blocks uint64
+	Rounds int
+	Seed   common.Hash
+}
+
+// Limits is what the rounds leave out, every result reports it.
+const Limits = "the other shares are assumed to be replaced as they are selected and no vote is late, " +
+	"so the pool size, and with it the reward, only moves by the simulated tickets"
+
+// Result summarizes the rounds of a simulation. Return and Variance are
+// relative to Cost.
+type Result struct {
+	Rounds          int
+	PoolSize        uint32
+	Tickets         uint32
+	Cost            *big.Int
+	ExpectedReward  *big.Int
+	MinReward       *big.Int
+	MaxReward       *big.Int
+	Return          float64
+	Variance        float64
+	MissProbability float64
+	ExpireRate      float64
+	Limits          string
+}
+
+type round struct {
+	reward  *big.Int
+	missed  uint32
+	expired uint32
+}
+
+// Simulate runs the rounds against the share pool in base, which is only
+// read. The rewards are those of stake.StakeState.StakeCurrentReward, within
+// the Limits of the rounds. It stops between rounds once ctx is done.
+func Simulate(ctx context.Context, base stake.State, params Params) (result Result, e error) {
+	if params.Tickets == 0 {
+		e = errors.New("no tickets to simulate")
+		return
+	}
+	if params.MissRate < 0 || params.MissRate > 1 {
+		e = errors.New("miss rate must be between 0 and 1")
+		return
+	}
+	if params.Pool && params.Fee > 10000 {
+		e = errors.New("pool fee must not exceed 10000")
+		return
+	}
+	if params.Blocks == 0 {
+		params.Blocks = stake.GetOutOfDateWindow()
+	}
+	if params.Blocks > stake.GetOutOfDateWindow() {
+		e = errors.New("blocks must not exceed the out of date window")
+		return
+	}
+	if params.Rounds <= 0 {
+		params.Rounds = DefaultRounds
+	}
+	if params.Rounds > MaxRounds {
+		e = errors.New("too many rounds")
+		return
+	}
+	if uint64(params.Rounds)*params.Blocks > MaxWork {
+		e = errors.New("too many rounds for the blocks")
+		return
+	}
+	result.Rounds = params.Rounds
+	result.Tickets = params.Tickets
+	result.Limits = Limits
+	result.PoolSize = stake.NewAVLTree(base).Size()
+	if result.PoolSize+params.Tickets < stake.MaxVoteCount {
+		e = errors.New("share pool too small")
+		return
+	}
+	result.Cost = params.Cost
+	if result.Cost == nil {
+		result.Cost = stake.SumPrice(result.PoolSize, int64(params.Tickets))
+	}
+
+	rounds := make([]round, params.Rounds)
+	errs := make([]error, params.Rounds)
+	mu := new(sync.Mutex)
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < runtime.NumCPU(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				rounds[i], errs[i] = runRound(&overlay{base, mu, memState{}}, &params, i)
+			}
+		}()
+	}
+	for i := range rounds {
+		if e = ctx.Err(); e != nil {
+			break
+		}
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	if e != nil {
+		return
+	}
+	for _, err := range errs {
+		if err != nil {
+			e = err
+			return
+		}
+	}
+	summarize(&result, rounds)
+	return
+}
+
+func summarize(result *Result, rounds []round) {
+	total := new(big.Int)
+	missed, expired := uint64(0), uint64(0)
+	cost, _ := new(big.Float).SetInt(result.Cost).Float64()
+	returns := make([]float64, len(rounds))
+	for i, each := range rounds {
+		total.Add(total, each.reward)
+		missed += uint64(each.missed)
+		expired += uint64(each.expired)
+		if result.MinReward == nil || each.reward.Cmp(result.MinReward) < 0 {
+			result.MinReward = each.reward
+		}
+		if result.MaxReward == nil || each.reward.Cmp(result.MaxReward) > 0 {
+			result.MaxReward = each.reward
+		}
+		if cost > 0 {
+			returns[i], _ = new(big.Float).Quo(new(big.Float).SetInt(each.reward), big.NewFloat(cost)).Float64()
+		}
+	}
+	n := float64(len(rounds))
+	result.ExpectedReward = new(big.Int).Div(total, big.NewInt(int64(len(rounds))))
+	for _, r := range returns {
+		result.Return += r / n
+	}
+	for _, r := range returns {
+		result.Variance += math.Pow(r-result.Return, 2) / n
+	}
+	tickets := n * float64(result.Tickets)
+	result.MissProbability = float64(missed) / tickets
+	result.ExpireRate = float64(expired) / tickets
+}
+
+// runRound adds the tickets to the pool and selects the shares of each
+// block until the tickets are all selected or out of date.
+func runRound(state *overlay, params *Params, i int) (ret round, e error) {
+	seed := crypto.Keccak256Hash(params.Seed[:], utils.EncodeNumber(uint64(i)))
+	key := crypto.Keccak256Hash(seed[:], []byte("share"))
+	miss := stake.NewHash256PRNG(crypto.Keccak256(seed[:], []byte("miss")))
+
+	tree := stake.NewAVLTree(state)
+	tree.Insert(stake.NewNode(key, params.Tickets))
+	start, _ := tree.IndexOf(key)
+	left := params.Tickets
+	ret.reward = new(big.Int)
+
+	for b := uint64(0); b < params.Blocks && left > 0; b++ {
+		size := tree.Size()
+		blockSeed := crypto.Keccak256(seed[:], utils.EncodeNumber(b))
+		idxs, err := stake.FindShareIdxs(size, stake.MaxVoteCount, stake.NewHash256PRNG(blockSeed))
+		if err != nil {
+			e = err
+			return
+		}
+		hits := uint32(0)
+		for _, idx := range idxs {
+			if idx < start || idx >= start+left {
+				continue
+			}
+			node, err := tree.FindByIndex(idx)
+			if err != nil {
+				e = err
+				return
+			}
+			if node.Key() == key {
+				hits++
+			}
+		}
+		if hits == 0 {
+			continue
+		}
+		// the block pays after its voted tickets leave the pool
+		tree.Delete(key, hits)
+		left -= hits
+		start, _ = tree.IndexOf(key)
+		soloReward, reward := rewardBySize(tree.Size(), params.Start+b)
+		if params.Pool {
+			reward = new(big.Int).Sub(reward, new(big.Int).Div(new(big.Int).Mul(reward, big.NewInt(int64(params.Fee))), big.NewInt(10000)))
+		} else {
+			reward = soloReward
+		}
+		for h := uint32(0); h < hits; h++ {
+			if float64(miss.Hash256Rand())/float64(math.MaxUint32+1) < params.MissRate {
+				ret.missed++
+			} else {
+				ret.reward.Add(ret.reward, reward)
+			}
+		}
+	}
+	ret.expired = left
+	return
+}
+
+// rewardBySize is the reward stake.StakeState.StakeCurrentReward pays at
+// number when the share pool holds size tickets.
+func rewardBySize(size uint32, number uint64) (soloReward *big.Int, reward *big.Int) {
+	if seroparam.Is_Dev() {
+		return big.NewInt(600000000000000000), big.NewInt(900000000000000000)
+	}
+	return stake.GetPosRewardBySize(uint64(size), int64(number))
+}
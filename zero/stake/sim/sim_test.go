@@ -0,0 +1,73 @@
+package sim
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/zero/stake"
+)
+
+func TestIndexOf(t *testing.T) {
+	state, err := Synthetic(100, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := stake.NewAVLTree(state)
+	for i := uint32(0); i < tree.Size(); i++ {
+		node, err := tree.FindByIndex(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		start, ok := tree.IndexOf(node.Key())
+		if !ok || i < start || i >= start+node.Num() {
+			t.Fatal("index", i, "outside", start, node.Num())
+		}
+	}
+}
+
+func TestSimulate(t *testing.T) {
+	base, err := Synthetic(300, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	params := Params{Tickets: 20, Blocks: 2000, Rounds: 8, Seed: common.BytesToHash([]byte("seed"))}
+	r0, err := Simulate(ctx, base, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r1, _ := Simulate(ctx, base, params)
+	if r0.ExpectedReward.Cmp(r1.ExpectedReward) != 0 || r0.Variance != r1.Variance {
+		t.Fatal("not deterministic", r0, r1)
+	}
+	if r0.PoolSize != 300 || r0.ExpectedReward.Sign() <= 0 || r0.ExpireRate != 0 || r0.Limits == "" {
+		t.Fatal("unexpected result", r0)
+	}
+	for _, bad := range []Params{
+		{Tickets: 20, Rounds: MaxRounds + 1},
+		{Tickets: 20, Blocks: stake.GetOutOfDateWindow() + 1},
+		{Tickets: 20, Blocks: 2000, Rounds: MaxWork/2000 + 1},
+	} {
+		if _, err := Simulate(ctx, base, bad); err == nil {
+			t.Fatal("unbounded simulation accepted", bad)
+		}
+	}
+	if _, err := Synthetic(MaxSyntheticSize+1, SyntheticShareSize); err == nil {
+		t.Fatal("oversized synthetic pool accepted")
+	}
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := Simulate(cancelled, base, params); err != context.Canceled {
+		t.Fatal("cancelled simulation ran", err)
+	}
+
+	params.Pool, params.Fee = true, 10000
+	if r, _ := Simulate(ctx, base, params); r.ExpectedReward.Sign() != 0 {
+		t.Fatal("full fee paid", r.ExpectedReward)
+	}
+	params.Pool, params.MissRate = false, 1
+	if r, _ := Simulate(ctx, base, params); r.ExpectedReward.Sign() != 0 || r.MissProbability != 1 {
+		t.Fatal("missed votes rewarded", r)
+	}
+}
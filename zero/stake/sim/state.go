@@ -0,0 +1,63 @@
+package sim
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/crypto"
+	"github.com/sero-cash/go-sero/zero/stake"
+	"github.com/sero-cash/go-sero/zero/utils"
+)
+
+type memState map[common.Hash]common.Hash
+
+func (self memState) SetStakeState(key common.Hash, value common.Hash) {
+	self[key] = value
+}
+
+func (self memState) GetStakeState(key common.Hash) common.Hash {
+	return self[key]
+}
+
+// Synthetic returns a share pool of size tickets held by shares of perShare
+// tickets each, size at most MaxSyntheticSize.
+func Synthetic(size uint32, perShare uint32) (stake.State, error) {
+	if size > MaxSyntheticSize {
+		return nil, errors.New("synthetic pool too large")
+	}
+	if perShare == 0 {
+		perShare = 1
+	}
+	state := memState{}
+	tree := stake.NewAVLTree(state)
+	for i := uint64(0); size > 0; i++ {
+		num := perShare
+		if num > size {
+			num = size
+		}
+		tree.Insert(stake.NewNode(crypto.Keccak256Hash([]byte("sim"), utils.EncodeNumber(i)), num))
+		size -= num
+	}
+	return state, nil
+}
+
+// overlay keeps the writes of one round away from the shared pool.
+type overlay struct {
+	base   stake.State
+	mu     *sync.Mutex
+	writes memState
+}
+
+func (self *overlay) SetStakeState(key common.Hash, value common.Hash) {
+	self.writes[key] = value
+}
+
+func (self *overlay) GetStakeState(key common.Hash) common.Hash {
+	if value, ok := self.writes[key]; ok {
+		return value
+	}
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return self.base.GetStakeState(key)
+}
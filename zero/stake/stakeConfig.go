@@ -77,7 +77,9 @@ func getStatisticsMissWindow() uint64 {
 	return statisticsMissWindow
 }
 
-func getOutOfDateWindow() uint64 {
+// GetOutOfDateWindow is how many blocks a share waits to be selected before
+// its remaining tickets expire.
+func GetOutOfDateWindow() uint64 {
 	if seroparam.Is_Dev() {
 		return 100
 	}
@@ -91,7 +93,7 @@ func getMissVotedWindow() uint64 {
 	return missVotedWindow
 }
 
-func getPayPeriod() uint64 {
+func GetPayPeriod() uint64 {
 	if seroparam.Is_Dev() {
 		return 5
 	}
@@ -0,0 +1,231 @@
+package prepare
+
+import (
+	crand "crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"math/rand"
+	"sort"
+	"strings"
+
+	"github.com/sero-cash/go-sero/zero/utils"
+)
+
+const (
+	SelectorDefault        = "default"
+	SelectorLargestFirst   = "largest-first"
+	SelectorBranchAndBound = "branch-and-bound"
+	SelectorRandom         = "random"
+	SelectorMinInputs      = "min-inputs"
+)
+
+// CoinSelector picks the utxos of one currency that pay for amount.
+type CoinSelector interface {
+	Name() string
+	Select(candidates Utxos, amount *big.Int) (selected Utxos, e error)
+}
+
+// Selection reports the outcome of a coin selection so strategies can be
+// compared before anything is signed.
+type Selection struct {
+	Strategy   string
+	InputCount int
+	Change     map[string]*utils.U256
+	Error      string `json:",omitempty"`
+}
+
+var coinSelectors = map[string]CoinSelector{
+	SelectorLargestFirst:   &largestFirst{},
+	SelectorBranchAndBound: &branchAndBound{maxTries: 100000},
+	SelectorRandom:         &randomSelect{},
+	SelectorMinInputs:      &minInputs{},
+}
+
+// CoinSelectorNames returns the names of the built-in strategies, the default
+// order of FindRoots first.
+func CoinSelectorNames() (names []string) {
+	for name := range coinSelectors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return append([]string{SelectorDefault}, names...)
+}
+
+// GetCoinSelector returns the strategy registered under name. The empty name
+// and SelectorDefault return nil, which keeps the order of FindRoots.
+func GetCoinSelector(name string) (selector CoinSelector, e error) {
+	name = strings.ToLower(name)
+	if name == "" || name == SelectorDefault {
+		return
+	}
+	if s, ok := coinSelectors[name]; ok {
+		selector = s
+	} else {
+		e = fmt.Errorf("unknown coin selector: %v", name)
+	}
+	return
+}
+
+func utxoValue(utxo *Utxo) *big.Int {
+	if utxo.Asset.Tkn == nil {
+		return new(big.Int)
+	}
+	return utxo.Asset.Tkn.Value.ToIntRef()
+}
+
+func sumUtxos(utxos Utxos) *big.Int {
+	sum := new(big.Int)
+	for i := range utxos {
+		sum.Add(sum, utxoValue(&utxos[i]))
+	}
+	return sum
+}
+
+func sortByValue(utxos Utxos, desc bool) Utxos {
+	sorted := append(Utxos{}, utxos...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		c := utxoValue(&sorted[i]).Cmp(utxoValue(&sorted[j]))
+		if desc {
+			return c > 0
+		}
+		return c < 0
+	})
+	return sorted
+}
+
+func accumulate(utxos Utxos, amount *big.Int) (selected Utxos, e error) {
+	sum := new(big.Int)
+	for _, utxo := range utxos {
+		if sum.Cmp(amount) >= 0 {
+			break
+		}
+		selected = append(selected, utxo)
+		sum.Add(sum, utxoValue(&utxo))
+	}
+	if sum.Cmp(amount) < 0 {
+		e = fmt.Errorf("no enough unlocked utxos")
+	}
+	return
+}
+
+// largestFirst spends the biggest utxos first, which keeps proofs small but
+// leaves the dust behind.
+type largestFirst struct{}
+
+func (self *largestFirst) Name() string {
+	return SelectorLargestFirst
+}
+
+func (self *largestFirst) Select(candidates Utxos, amount *big.Int) (selected Utxos, e error) {
+	return accumulate(sortByValue(candidates, true), amount)
+}
+
+// branchAndBound searches for a set of utxos that matches amount exactly so
+// that no change output is needed, falling back to largest-first.
+type branchAndBound struct {
+	maxTries int
+}
+
+func (self *branchAndBound) Name() string {
+	return SelectorBranchAndBound
+}
+
+func (self *branchAndBound) Select(candidates Utxos, amount *big.Int) (selected Utxos, e error) {
+	sorted := sortByValue(candidates, true)
+	values := make([]*big.Int, len(sorted))
+	remains := make([]*big.Int, len(sorted)+1)
+	remains[len(sorted)] = new(big.Int)
+	for i := len(sorted) - 1; i >= 0; i-- {
+		values[i] = utxoValue(&sorted[i])
+		remains[i] = new(big.Int).Add(remains[i+1], values[i])
+	}
+	if remains[0].Cmp(amount) < 0 {
+		e = fmt.Errorf("no enough unlocked utxos")
+		return
+	}
+
+	tries := 0
+	picked := []int{}
+	var search func(index int, sum *big.Int) bool
+	search = func(index int, sum *big.Int) bool {
+		tries++
+		if c := sum.Cmp(amount); c == 0 {
+			return true
+		} else if c > 0 || index >= len(sorted) || tries > self.maxTries {
+			return false
+		}
+		if new(big.Int).Add(sum, remains[index]).Cmp(amount) < 0 {
+			return false
+		}
+		picked = append(picked, index)
+		if search(index+1, new(big.Int).Add(sum, values[index])) {
+			return true
+		}
+		picked = picked[:len(picked)-1]
+		return search(index+1, sum)
+	}
+
+	if search(0, new(big.Int)) {
+		for _, i := range picked {
+			selected = append(selected, sorted[i])
+		}
+		return
+	}
+	return accumulate(sorted, amount)
+}
+
+// randomSelect spends utxos in a random order so the inputs of a tx do not
+// reveal how the account's outputs are ranked.
+type randomSelect struct{}
+
+func (self *randomSelect) Name() string {
+	return SelectorRandom
+}
+
+func (self *randomSelect) Select(candidates Utxos, amount *big.Int) (selected Utxos, e error) {
+	var seed [8]byte
+	if _, err := crand.Read(seed[:]); err != nil {
+		e = err
+		return
+	}
+	shuffled := append(Utxos{}, candidates...)
+	r := rand.New(rand.NewSource(int64(binary.BigEndian.Uint64(seed[:]))))
+	r.Shuffle(len(shuffled), func(i, j int) {
+		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+	})
+	return accumulate(shuffled, amount)
+}
+
+// minInputs spends the smallest utxo that pays for amount alone. Without one
+// it takes the fewest inputs, which are the largest ones, and closes the gap
+// with the smallest utxo that still does, keeping the change small.
+type minInputs struct{}
+
+func (self *minInputs) Name() string {
+	return SelectorMinInputs
+}
+
+func (self *minInputs) Select(candidates Utxos, amount *big.Int) (selected Utxos, e error) {
+	sorted := sortByValue(candidates, false)
+	for _, utxo := range sorted {
+		if utxoValue(&utxo).Cmp(amount) >= 0 {
+			selected = Utxos{utxo}
+			return
+		}
+	}
+
+	desc := sortByValue(candidates, true)
+	if selected, e = accumulate(desc, amount); e != nil || len(selected) == 0 {
+		return
+	}
+	last := len(selected) - 1
+	gap := new(big.Int).Sub(amount, sumUtxos(selected[:last]))
+	for i := len(desc) - 1; i > last; i-- {
+		if utxoValue(&desc[i]).Cmp(gap) >= 0 {
+			selected[last] = desc[i]
+			break
+		}
+	}
+	return
+}
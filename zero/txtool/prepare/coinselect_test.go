@@ -0,0 +1,98 @@
+package prepare
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/sero-cash/go-czero-import/c_type"
+	"github.com/sero-cash/go-sero/zero/txs/assets"
+	"github.com/sero-cash/go-sero/zero/utils"
+)
+
+func newUtxos(values ...int64) (utxos Utxos) {
+	for i, v := range values {
+		root := c_type.Uint256{}
+		root[0] = byte(i + 1)
+		utxos = append(utxos, Utxo{
+			Root: root,
+			Asset: assets.Asset{Tkn: &assets.Token{
+				Currency: utils.CurrencyToUint256("SERO"),
+				Value:    utils.U256(*big.NewInt(v)),
+			}},
+		})
+	}
+	return
+}
+
+func TestLargestFirst(t *testing.T) {
+	selector, _ := GetCoinSelector(SelectorLargestFirst)
+	selected, e := selector.Select(newUtxos(1, 5, 3, 8), big.NewInt(10))
+	if e != nil {
+		t.Fatal(e)
+	}
+	if len(selected) != 2 || sumUtxos(selected).Int64() != 13 {
+		t.Fatalf("largest-first selected %v utxos with sum %v", len(selected), sumUtxos(selected))
+	}
+}
+
+func TestBranchAndBoundExactMatch(t *testing.T) {
+	selector, _ := GetCoinSelector(SelectorBranchAndBound)
+	selected, e := selector.Select(newUtxos(7, 2, 6, 5, 1), big.NewInt(8))
+	if e != nil {
+		t.Fatal(e)
+	}
+	if sumUtxos(selected).Int64() != 8 {
+		t.Fatalf("branch-and-bound missed the exact match, sum %v", sumUtxos(selected))
+	}
+
+	selected, e = selector.Select(newUtxos(10, 20), big.NewInt(15))
+	if e != nil {
+		t.Fatal(e)
+	}
+	if len(selected) != 1 || sumUtxos(selected).Int64() != 20 {
+		t.Fatalf("branch-and-bound fallback selected sum %v", sumUtxos(selected))
+	}
+}
+
+func TestMinInputs(t *testing.T) {
+	selector, _ := GetCoinSelector(SelectorMinInputs)
+	selected, e := selector.Select(newUtxos(100, 4, 12, 50), big.NewInt(11))
+	if e != nil {
+		t.Fatal(e)
+	}
+	if len(selected) != 1 || sumUtxos(selected).Int64() != 12 {
+		t.Fatalf("min-inputs selected %v utxos with sum %v", len(selected), sumUtxos(selected))
+	}
+
+	// no utxo pays alone, the second input closes the gap with the least change
+	selected, e = selector.Select(newUtxos(9, 2, 8, 7, 6), big.NewInt(16))
+	if e != nil {
+		t.Fatal(e)
+	}
+	if len(selected) != 2 || sumUtxos(selected).Int64() != 16 {
+		t.Fatalf("min-inputs selected %v utxos with sum %v", len(selected), sumUtxos(selected))
+	}
+}
+
+func TestRandomSelect(t *testing.T) {
+	selector, _ := GetCoinSelector(SelectorRandom)
+	selected, e := selector.Select(newUtxos(1, 2, 3, 4, 5), big.NewInt(9))
+	if e != nil {
+		t.Fatal(e)
+	}
+	if sumUtxos(selected).Int64() < 9 {
+		t.Fatalf("random selected sum %v", sumUtxos(selected))
+	}
+	if _, e := selector.Select(newUtxos(1, 2), big.NewInt(9)); e == nil {
+		t.Fatal("random should fail without enough utxos")
+	}
+}
+
+func TestUnknownSelector(t *testing.T) {
+	if _, e := GetCoinSelector("fifo"); e == nil {
+		t.Fatal("unknown selector should fail")
+	}
+	if s, e := GetCoinSelector(""); s != nil || e != nil {
+		t.Fatal("empty selector should keep the default order")
+	}
+}
@@ -18,16 +18,33 @@ import (
 )
 
 func SelectUtxos(param *PreTxParam, generator TxParamGenerator) (utxos Utxos, e error) {
+	utxos, _, e = SelectUtxosWithReport(param, generator)
+	return
+}
+
+func SelectUtxosWithReport(param *PreTxParam, generator TxParamGenerator) (utxos Utxos, selection Selection, e error) {
 	if len(param.Roots) > 0 {
 		for _, root := range param.Roots {
 			if utxo := generator.GetRoot(&root); utxo == nil {
-				return utxos, fmt.Errorf("can not find the utxo for root : %v", hexutil.Encode(root[:]))
+				e = fmt.Errorf("can not find the utxo for root : %v", hexutil.Encode(root[:]))
+				return
 			} else {
 				utxos = append(utxos, *utxo)
 			}
 		}
+		selection.InputCount = len(utxos)
 		return
 	} else {
+		var selector CoinSelector
+		if selector, e = GetCoinSelector(param.Selector); e != nil {
+			return
+		}
+		if selector != nil {
+			selection.Strategy = selector.Name()
+		} else {
+			selection.Strategy = SelectorDefault
+		}
+
 		ck := assets.NewCKState(true, &param.Fee)
 
 		if cmdsAsset := param.Cmds.OutAsset(); cmdsAsset != nil {
@@ -59,15 +76,26 @@ func SelectUtxos(param *PreTxParam, generator TxParamGenerator) (utxos Utxos, e
 			}
 		}
 
+		selection.Change = map[string]*utils.U256{}
 		for _, tkn := range ck.Tkns() {
-			outs, remain := generator.FindRoots(&param.From, utils.Uint256ToCurrency(&tkn.Currency), tkn.Value.ToIntRef())
-			if remain.Sign() <= 0 {
-				utxos = append(utxos, outs...)
+			currency := utils.Uint256ToCurrency(&tkn.Currency)
+			var outs Utxos
+			if selector == nil {
+				var remain big.Int
+				if outs, remain = generator.FindRoots(&param.From, currency, tkn.Value.ToIntRef()); remain.Sign() > 0 {
+					e = errors.New("no enough unlocked utxos")
+					return
+				}
 			} else {
-				e = errors.New("no enough unlocked utxos")
-				return
+				if outs, e = selector.Select(generator.FindCandidates(&param.From, currency), tkn.Value.ToIntRef()); e != nil {
+					return
+				}
 			}
+			utxos = append(utxos, outs...)
+			change := utils.U256(*new(big.Int).Sub(sumUtxos(outs), tkn.Value.ToIntRef()))
+			selection.Change[currency] = &change
 		}
+		selection.InputCount = len(utxos)
 
 		return
 	}
@@ -140,7 +168,7 @@ func BuildTxParam(
 			pkr = CreatePkr(&pk, 0)
 		}
 		ck.AddOut(&reception.Asset)
-		Outs = append(Outs, txtool.GOut{PKr: pkr, Asset: reception.Asset})
+		Outs = append(Outs, txtool.GOut{PKr: pkr, Asset: reception.Asset, Memo: reception.Memo})
 	}
 
 	if cmdsAsset := param.Cmds.OutAsset(); cmdsAsset != nil {
@@ -19,6 +19,7 @@ import (
 type Reception struct {
 	Addr  c_type.PKr
 	Asset assets.Asset
+	Memo  c_type.Uint512
 }
 
 type PkgCloseCmd struct {
@@ -161,6 +162,7 @@ type PreTxParam struct {
 	Fee        assets.Token
 	GasPrice   *big.Int
 	Roots      []c_type.Uint256
+	Selector   string
 }
 
 type Utxo struct {
@@ -179,6 +181,7 @@ func (self *Utxos) Roots() (roots []c_type.Uint256) {
 
 type TxParamGenerator interface {
 	FindRoots(pk *c_type.Uint512, currency string, amount *big.Int) (utxos Utxos, remain big.Int)
+	FindCandidates(pk *c_type.Uint512, currency string) (utxos Utxos)
 	FindRootsByTicket(pk *c_type.Uint512, tickets []assets.Ticket) (roots Utxos, remain map[c_type.Uint256]c_type.Uint256)
 	GetRoot(root *c_type.Uint256) (utxos *Utxo)
 	DefaultRefundTo(pk *c_type.Uint512) (ret *c_type.PKr)
@@ -0,0 +1,135 @@
+package exchange
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/sero-cash/go-czero-import/c_type"
+	"github.com/sero-cash/go-sero/log"
+	"github.com/sero-cash/go-sero/zero/txs/assets"
+	"github.com/sero-cash/go-sero/zero/txtool/prepare"
+	"github.com/sero-cash/go-sero/zero/utils"
+)
+
+var (
+	// batchMaxReceptions matches the receptions limit of prepare.GenTxParam.
+	batchMaxReceptions = 500
+	// batchMaxIns keeps a payout tx far below the size limit of the tx pool.
+	batchMaxIns = 500
+
+	errTooManyIns = errors.New("too many inputs for one tx")
+
+	batchSign = (*Exchange).GenTxWithSign
+)
+
+type BatchPayParam struct {
+	From       c_type.Uint512
+	RefundTo   *c_type.PKr
+	Receptions []prepare.Reception
+	Gas        uint64
+	GasPrice   *big.Int
+	Selector   string
+}
+
+// BatchPayResult is the outcome for the reception at Index, TxHash is empty
+// when its tx could not be built or committed.
+type BatchPayResult struct {
+	Index  int
+	TxHash *c_type.Uint256 `json:",omitempty"`
+	Error  string          `json:",omitempty"`
+}
+
+// BatchPay pays the receptions with as few txs as the limits allow. Every tx
+// locks its own utxos through the used flags, a tx that fails to sign or to
+// commit releases them again so the later txs can spend them.
+func (self *Exchange) BatchPay(param BatchPayParam) (results []BatchPayResult, e error) {
+	if self == nil {
+		e = errors.New("exchange instance is nil")
+		return
+	}
+	if len(param.Receptions) == 0 {
+		e = errors.New("have no receptions")
+		return
+	}
+	if account := self.getAccountByPk(param.From); account == nil {
+		e = errors.New("not found Pk")
+		return
+	} else if account.watchOnly() {
+		e = ErrWatchOnly
+		return
+	}
+
+	results = make([]BatchPayResult, len(param.Receptions))
+	for i := range results {
+		results[i].Index = i
+	}
+
+	size := batchMaxReceptions
+	for start := 0; start < len(param.Receptions); {
+		end := start + size
+		if end > len(param.Receptions) {
+			end = len(param.Receptions)
+		}
+		txHash, err := self.payChunk(&param, param.Receptions[start:end])
+		if err == errTooManyIns && end-start > 1 {
+			size = (end - start) / 2
+			continue
+		}
+		for i := start; i < end; i++ {
+			if err != nil {
+				results[i].Error = err.Error()
+			} else {
+				results[i].TxHash = txHash
+			}
+		}
+		if err != nil {
+			log.Error("Exchange batchPay", "from", start, "to", end, "error", err)
+		}
+		// only the chunk with too many inputs is halved
+		start, size = end, batchMaxReceptions
+	}
+	return
+}
+
+func (self *Exchange) payChunk(param *BatchPayParam, receptions []prepare.Reception) (txHash *c_type.Uint256, e error) {
+	preTx := prepare.PreTxParam{
+		From:       param.From,
+		RefundTo:   param.RefundTo,
+		Receptions: receptions,
+		Fee: assets.Token{
+			Currency: utils.CurrencyToUint256("SERO"),
+			Value:    utils.U256(*new(big.Int).Mul(new(big.Int).SetUint64(param.Gas), param.GasPrice)),
+		},
+		GasPrice: param.GasPrice,
+		Selector: param.Selector,
+	}
+	if preTx.Selector == "" {
+		preTx.Selector = self.config.CoinSelector
+	}
+	// the inputs are counted before signing, the tx then spends the same
+	// utxos
+	roots, err := prepare.SelectUtxos(&preTx, self)
+	if err != nil {
+		e = err
+		return
+	}
+	if len(roots) > batchMaxIns {
+		e = errTooManyIns
+		return
+	}
+	for _, root := range roots {
+		preTx.Roots = append(preTx.Roots, root.Root)
+	}
+	pretx, tx, err := batchSign(self, preTx)
+	if err != nil {
+		e = err
+		return
+	}
+	if err = self.commitTx(pretx, tx); err != nil {
+		self.ClearTxParam(pretx)
+		e = err
+		return
+	}
+	txHash = tx.Hash.NewRef()
+	return
+}
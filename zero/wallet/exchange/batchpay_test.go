@@ -0,0 +1,70 @@
+package exchange
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/sero-cash/go-czero-import/c_type"
+	"github.com/sero-cash/go-sero/zero/txs/assets"
+	"github.com/sero-cash/go-sero/zero/txtool"
+	"github.com/sero-cash/go-sero/zero/txtool/prepare"
+	"github.com/sero-cash/go-sero/zero/utils"
+)
+
+func TestBatchPayHalvesChunks(t *testing.T) {
+	env := newTestEnv(6)
+	account := newTestAccount(1)
+	account.wallet = testWallet{}
+	exchange, closer := newTestExchange(t, env.chain, *account)
+	defer closer()
+	exchange.indexUtxo(testBlocks(account, env.blocks, []int64{100, 100, 100, 100, 100, 100}, nil, map[uint64]txtool.Out{}), []c_type.Uint512{*account.pk})
+
+	defer func(max int) { batchMaxIns = max }(batchMaxIns)
+	batchMaxIns = 2
+	var signed []prepare.PreTxParam
+	errSign := errors.New("not signed")
+	batchSign = func(self *Exchange, param prepare.PreTxParam) (*txtool.GTxParam, *txtool.GTx, error) {
+		signed = append(signed, param)
+		return nil, nil, errSign
+	}
+	defer func() { batchSign = (*Exchange).GenTxWithSign }()
+
+	param := BatchPayParam{From: *account.pk, RefundTo: account.balancePkr, GasPrice: big.NewInt(1)}
+	for i := 0; i < 5; i++ {
+		param.Receptions = append(param.Receptions, prepare.Reception{
+			Addr: *account.balancePkr,
+			Asset: assets.Asset{Tkn: &assets.Token{
+				Currency: utils.CurrencyToUint256("SERO"),
+				Value:    utils.U256(*big.NewInt(100)),
+			}},
+		})
+	}
+	param.Receptions[4].Asset.Tkn.Value = utils.U256(*big.NewInt(150))
+
+	results, err := exchange.BatchPay(param)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 5 receptions need 6 inputs, halved to a chunk of 2. The rest starts
+	// with all 3 again, which needs 4 inputs and is halved to 1, and so on;
+	// the last one still needs 2 inputs and is signed as well
+	if len(signed) != 4 {
+		t.Fatalf("signed %v txs, want 4", len(signed))
+	}
+	for i, param := range signed {
+		if len(param.Roots) > batchMaxIns {
+			t.Fatalf("tx %v signed with %v inputs", i, len(param.Roots))
+		}
+	}
+	for i, want := range []int{2, 1, 1, 1} {
+		if len(signed[i].Receptions) != want {
+			t.Fatalf("chunk %v of %v receptions, want %v", i, len(signed[i].Receptions), want)
+		}
+	}
+	for _, result := range results {
+		if result.Error != errSign.Error() {
+			t.Fatalf("reception %v failed with %q", result.Index, result.Error)
+		}
+	}
+}
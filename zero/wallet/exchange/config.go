@@ -0,0 +1,45 @@
+package exchange
+
+import (
+	"errors"
+	"time"
+
+	"github.com/sero-cash/go-sero/zero/txtool/prepare"
+)
+
+type Config struct {
+	// MergePolicy decides when the auto merge runs, without rules it merges
+	// SERO with the default thresholds.
+	MergePolicy MergePolicy
+	// CoinSelector names the prepare.CoinSelector used when a request does
+	// not choose one, empty keeps the order of the utxo index.
+	CoinSelector string
+
+	// WebhookURL receives every exchange event as a signed JSON POST once
+	// its block is WebhookConfirmations deep, empty disables the webhook. It
+	// needs a WebhookSecret.
+	WebhookURL           string
+	WebhookSecret        string
+	WebhookConfirmations uint64
+
+	// PendingTimeout releases the used flags of a committed tx that was not
+	// mined in time.
+	PendingTimeout time.Duration
+}
+
+var DefaultConfig = Config{
+	PendingTimeout: time.Hour,
+}
+
+var errWebhookSecret = errors.New("exchange webhook needs a secret")
+
+// Validate checks the options that can not be fixed up at start.
+func (self *Config) Validate() error {
+	if self.WebhookURL != "" && self.WebhookSecret == "" {
+		return errWebhookSecret
+	}
+	if _, err := prepare.GetCoinSelector(self.CoinSelector); err != nil {
+		return err
+	}
+	return self.MergePolicy.Validate()
+}
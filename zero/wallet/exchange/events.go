@@ -0,0 +1,250 @@
+package exchange
+
+import (
+	"errors"
+	"time"
+
+	"github.com/sero-cash/go-czero-import/c_type"
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/common/address"
+	"github.com/sero-cash/go-sero/event"
+	"github.com/sero-cash/go-sero/zero/utils"
+)
+
+const (
+	EventUtxoIn      = "utxoIn"
+	EventUtxoSpent   = "utxoSpent"
+	EventTxConfirmed = "txConfirmed"
+	EventPkgReceived = "pkgReceived"
+	// EventRollback tells that the blocks above Num left the canonical chain,
+	// the events of those blocks that were already delivered are void.
+	EventRollback = "rollback"
+)
+
+var (
+	eventCheckInterval = 10 * time.Second
+	// maxQueuedEvents bounds the events a subscription holds for its reader.
+	maxQueuedEvents = 10000
+
+	errEventQueueFull = errors.New("exchange subscription fell behind, events dropped")
+)
+
+type Event struct {
+	Type          string
+	Num           uint64
+	BlockHash     c_type.Uint256
+	Confirmations uint64
+	PK            *address.PKAddress       `json:",omitempty"`
+	Pkr           address.MixBase58Adrress `json:",omitempty"`
+	Root          *c_type.Uint256          `json:",omitempty"`
+	Nil           *c_type.Uint256          `json:",omitempty"`
+	TxHash        *c_type.Uint256          `json:",omitempty"`
+	Currency      string                   `json:",omitempty"`
+	Value         *utils.U256              `json:",omitempty"`
+	Category      string                   `json:",omitempty"`
+	Ticket        *c_type.Uint256          `json:",omitempty"`
+	PkgId         *c_type.Uint256          `json:",omitempty"`
+}
+
+func newUtxoEvent(typ string, pk c_type.Uint512, blockHash c_type.Uint256, num uint64, utxo *Utxo) (ev Event) {
+	pkAddr := address.PKAddress(pk)
+	ev = Event{
+		Type:      typ,
+		Num:       num,
+		BlockHash: blockHash,
+		PK:        &pkAddr,
+		Pkr:       address.MixBase58Adrress(common.CopyBytes(utxo.Pkr[:])),
+		Root:      utxo.Root.NewRef(),
+		Nil:       utxo.Nil.NewRef(),
+		TxHash:    utxo.TxHash.NewRef(),
+	}
+	if utxo.Asset.Tkn != nil {
+		ev.Currency = common.BytesToString(utxo.Asset.Tkn.Currency[:])
+		value := utxo.Asset.Tkn.Value
+		ev.Value = &value
+	}
+	if utxo.Asset.Tkt != nil {
+		ev.Category = common.BytesToString(utxo.Asset.Tkt.Category[:])
+		ev.Ticket = utxo.Asset.Tkt.Value.NewRef()
+	}
+	return
+}
+
+// EventCriteria selects the events of a subscription. Empty lists match
+// everything, rollbacks are always delivered.
+type EventCriteria struct {
+	Types []string
+	PKs   []c_type.Uint512
+	Pkrs  []c_type.PKr
+	// Confirmations holds events back until their block is that deep. The
+	// index itself only follows blocks past the confirmed block delay.
+	Confirmations uint64
+}
+
+func (self *EventCriteria) match(ev *Event) bool {
+	if ev.Type == EventRollback {
+		return true
+	}
+	if len(self.Types) > 0 {
+		found := false
+		for _, typ := range self.Types {
+			if typ == ev.Type || (typ == EventTxConfirmed && ev.Type == EventUtxoIn) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(self.PKs) == 0 && len(self.Pkrs) == 0 {
+		return true
+	}
+	if ev.PK != nil {
+		for _, pk := range self.PKs {
+			if pk == ev.PK.ToUint512() {
+				return true
+			}
+		}
+	}
+	if len(ev.Pkr) == len(c_type.PKr{}) {
+		for _, pkr := range self.Pkrs {
+			if pkr == *pkrOf(ev.Pkr) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (self *EventCriteria) wants(typ string) bool {
+	if len(self.Types) == 0 {
+		return true
+	}
+	for _, t := range self.Types {
+		if t == typ {
+			return true
+		}
+	}
+	return false
+}
+
+func pkrOf(b address.MixBase58Adrress) *c_type.PKr {
+	pkr := c_type.PKr{}
+	copy(pkr[:], b)
+	return &pkr
+}
+
+func (self *Exchange) publish(events []Event) {
+	if len(events) > 0 {
+		self.feed.Send(events)
+	}
+}
+
+func (self *Exchange) currentNumber() (num uint64, ok bool) {
+	chain := self.headerReader()
+	if chain == nil {
+		return
+	}
+	if header := chain.GetCurrenHeader(); header != nil {
+		return header.Number.Uint64(), true
+	}
+	return
+}
+
+// txBlock is a tx in a block, the key of the delivered txConfirmed events.
+type txBlock struct {
+	tx    c_type.Uint256
+	block c_type.Uint256
+}
+
+// SubscribeEvents delivers the events matching crit to ch once their block has
+// enough confirmations. A slow reader never stalls the indexer, the events
+// queue up in the subscription instead. Past maxQueuedEvents the subscription
+// ends with errEventQueueFull. A tx is confirmed once per block, a
+// rescan indexing the block again does not repeat it, but after a rollback
+// the tx is confirmed again in its new block.
+func (self *Exchange) SubscribeEvents(crit EventCriteria, ch chan<- Event) event.Subscription {
+	events := make(chan []Event, 16)
+	sub := self.feed.Subscribe(events)
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		defer sub.Unsubscribe()
+
+		ticker := time.NewTicker(eventCheckInterval)
+		defer ticker.Stop()
+
+		var pending, ready []Event
+		confirmed := map[txBlock]uint64{}
+		release := func() {
+			head, ok := self.currentNumber()
+			if !ok {
+				return
+			}
+			i := 0
+			for ; i < len(pending); i++ {
+				ev := pending[i]
+				if ev.Num > head || head-ev.Num+1 < crit.Confirmations {
+					break
+				}
+				ev.Confirmations = head - ev.Num + 1
+				if ev.Type == EventUtxoIn && crit.wants(EventTxConfirmed) {
+					key := txBlock{*ev.TxHash, ev.BlockHash}
+					if _, done := confirmed[key]; !done {
+						confirmed[key] = ev.Num
+						ready = append(ready, Event{Type: EventTxConfirmed, Num: ev.Num, BlockHash: ev.BlockHash, Confirmations: ev.Confirmations, TxHash: ev.TxHash})
+					}
+				}
+				if crit.wants(ev.Type) {
+					ready = append(ready, ev)
+				}
+			}
+			pending = pending[i:]
+		}
+
+		for {
+			var out chan<- Event
+			var next Event
+			if len(ready) > 0 {
+				out = ch
+				next = ready[0]
+			}
+			select {
+			case list := <-events:
+				for _, ev := range list {
+					if !crit.match(&ev) {
+						continue
+					}
+					if ev.Type == EventRollback {
+						kept := pending[:0]
+						for _, p := range pending {
+							if p.Num <= ev.Num {
+								kept = append(kept, p)
+							}
+						}
+						pending = kept
+						for key, num := range confirmed {
+							if num > ev.Num {
+								delete(confirmed, key)
+							}
+						}
+						ready = append(ready, ev)
+					} else {
+						pending = append(pending, ev)
+					}
+				}
+				release()
+				if len(pending)+len(ready) > maxQueuedEvents {
+					return errEventQueueFull
+				}
+			case <-ticker.C:
+				release()
+			case out <- next:
+				ready = ready[1:]
+			case err := <-sub.Err():
+				return err
+			case <-quit:
+				return nil
+			}
+		}
+	})
+}
@@ -0,0 +1,214 @@
+package exchange
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sero-cash/go-czero-import/c_type"
+	"github.com/sero-cash/go-sero/event"
+	"github.com/sero-cash/go-sero/zero/txtool"
+)
+
+func nextEvent(t *testing.T, events chan Event) Event {
+	select {
+	case ev := <-events:
+		return ev
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for an event")
+	}
+	return Event{}
+}
+
+func TestSubscribeEventsConfirmations(t *testing.T) {
+	env := newTestEnv(6)
+
+	account := newTestAccount(1)
+	exchange, closer := newTestExchange(t, env.chain, *account)
+	defer closer()
+
+	events := make(chan Event)
+	sub := exchange.SubscribeEvents(EventCriteria{Types: []string{EventUtxoIn}, PKs: []c_type.Uint512{*account.pk}, Confirmations: 5}, events)
+	defer sub.Unsubscribe()
+	all := make(chan Event)
+	allSub := exchange.SubscribeEvents(EventCriteria{}, all)
+	defer allSub.Unsubscribe()
+
+	outs := map[uint64]txtool.Out{}
+	infos := testBlocks(account, env.blocks, []int64{1, 2, 3, 4, 5, 6}, nil, outs)
+	exchange.indexUtxo(infos, []c_type.Uint512{*account.pk})
+
+	// The head is 6, so only the blocks 1 and 2 are 5 blocks deep.
+	for _, num := range []uint64{1, 2} {
+		if ev := nextEvent(t, events); ev.Type != EventUtxoIn || ev.Num != num || ev.Confirmations != 7-num {
+			t.Fatalf("got %v at %v with %v confirmations, want utxoIn at %v", ev.Type, ev.Num, ev.Confirmations, num)
+		}
+	}
+	select {
+	case ev := <-events:
+		t.Fatalf("unexpected %v event at %v", ev.Type, ev.Num)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	if ev := nextEvent(t, all); ev.Type != EventTxConfirmed || ev.Num != 1 {
+		t.Fatalf("got %v at %v, want txConfirmed at 1", ev.Type, ev.Num)
+	}
+	if ev := nextEvent(t, all); ev.Type != EventUtxoIn || ev.Num != 1 {
+		t.Fatalf("got %v at %v, want utxoIn at 1", ev.Type, ev.Num)
+	}
+}
+
+func TestWebhookSignature(t *testing.T) {
+	attempts := 0
+	received := make(chan bool, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		received <- r.Header.Get(webhookSignatureHeader) == SignWebhook([]byte("secret"), body) && r.Header.Get(webhookEventHeader) == EventRollback
+	}))
+	defer server.Close()
+
+	hook := newWebhook(server.URL, []byte("secret"), server.Client())
+	r := hook.deliver(&Event{Type: EventRollback, Num: 3})
+	if r == nil {
+		t.Fatal("failed event not retried")
+	}
+	if hook.retry(r) {
+		t.Fatal("delivered event retried again")
+	}
+	select {
+	case ok := <-received:
+		if !ok {
+			t.Fatal("webhook signature mismatch")
+		}
+	default:
+		t.Fatal("webhook not retried")
+	}
+}
+
+func TestWebhookRetryDoesNotBlock(t *testing.T) {
+	received := make(chan string, 4)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(webhookEventHeader) == EventRollback {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		received <- r.Header.Get(webhookEventHeader)
+	}))
+	defer server.Close()
+
+	backoff := webhookMinBackoff
+	webhookMinBackoff = time.Hour
+	defer func() { webhookMinBackoff = backoff }()
+	hook := newWebhook(server.URL, []byte("secret"), server.Client())
+	feed := make(chan Event)
+	go hook.loop(func(events chan Event) event.Subscription {
+		go func() {
+			for ev := range feed {
+				events <- ev
+			}
+		}()
+		return event.NewSubscription(func(quit <-chan struct{}) error {
+			<-quit
+			return nil
+		})
+	})
+
+	// the failing rollback waits for its retry, the later event goes out
+	feed <- Event{Type: EventRollback, Num: 1}
+	feed <- Event{Type: EventUtxoIn, Num: 2}
+	select {
+	case typ := <-received:
+		if typ != EventUtxoIn {
+			t.Fatalf("got %v, want utxoIn", typ)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("event held back by a retry")
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		hook.stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("webhook not stopped")
+	}
+	close(feed)
+}
+
+func TestSubscribeEventsOverflow(t *testing.T) {
+	env := newTestEnv(1)
+	exchange, closer := newTestExchange(t, env.chain, *newTestAccount(1))
+	defer closer()
+
+	max := maxQueuedEvents
+	maxQueuedEvents = 2
+	defer func() { maxQueuedEvents = max }()
+	sub := exchange.SubscribeEvents(EventCriteria{}, make(chan Event))
+	defer sub.Unsubscribe()
+
+	exchange.publish([]Event{{Type: EventRollback}, {Type: EventRollback}, {Type: EventRollback}})
+	select {
+	case err := <-sub.Err():
+		if err != errEventQueueFull {
+			t.Fatalf("got %v, want errEventQueueFull", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("unread events kept growing")
+	}
+}
+
+func TestTxConfirmedOnce(t *testing.T) {
+	env := newTestEnv(3)
+	exchange, closer := newTestExchange(t, env.chain, *newTestAccount(1))
+	defer closer()
+
+	events := make(chan Event, 16)
+	sub := exchange.SubscribeEvents(EventCriteria{Types: []string{EventTxConfirmed, EventRollback}}, events)
+	defer sub.Unsubscribe()
+
+	tx, blockA, blockB := c_type.Uint256{1}, c_type.Uint256{2}, c_type.Uint256{3}
+	utxoIn := func(block c_type.Uint256, root byte) Event {
+		return Event{Type: EventUtxoIn, Num: 2, BlockHash: block, TxHash: tx.NewRef(), Root: &c_type.Uint256{root}}
+	}
+	// two outs of the tx, then a rescan indexing the block again
+	exchange.publish([]Event{utxoIn(blockA, 1), utxoIn(blockA, 2)})
+	exchange.publish([]Event{utxoIn(blockA, 1)})
+	if ev := nextEvent(t, events); ev.Type != EventTxConfirmed || ev.BlockHash != blockA {
+		t.Fatalf("got %v in %v, want txConfirmed", ev.Type, ev.BlockHash)
+	}
+
+	// the tx moves to another block at the same height
+	exchange.publish([]Event{{Type: EventRollback, Num: 1}})
+	exchange.publish([]Event{utxoIn(blockB, 1)})
+	if ev := nextEvent(t, events); ev.Type != EventRollback {
+		t.Fatalf("got %v, want rollback", ev.Type)
+	}
+	if ev := nextEvent(t, events); ev.Type != EventTxConfirmed || ev.BlockHash != blockB {
+		t.Fatalf("got %v in %v, want txConfirmed in the new block", ev.Type, ev.BlockHash)
+	}
+	select {
+	case ev := <-events:
+		t.Fatalf("unexpected %v in %v", ev.Type, ev.BlockHash)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestWebhookNeedsSecret(t *testing.T) {
+	config := Config{WebhookURL: "http://localhost"}
+	if config.Validate() == nil {
+		t.Fatal("webhook without a secret accepted")
+	}
+	if (&Exchange{config: config}).startWebhook() != nil {
+		t.Fatal("webhook started without a secret")
+	}
+}
@@ -33,6 +33,7 @@ import (
 	"github.com/sero-cash/go-sero/serodb"
 	"github.com/sero-cash/go-sero/zero/txs/assets"
 	"github.com/sero-cash/go-sero/zero/utils"
+	"github.com/sero-cash/go-sero/zero/wallet/reorg"
 )
 
 type Account struct {
@@ -100,22 +101,43 @@ type FetchJob struct {
 	accounts []Account
 }
 
+// TxPool is the part of the tx pool the exchange commits its txs to.
+type TxPool interface {
+	AddLocal(tx *types.Transaction) error
+	Get(hash common.Hash) *types.Transaction
+	RemoveTxs(txs types.Transactions)
+}
+
 type Exchange struct {
 	db             *serodb.LDBDatabase
-	txPool         *core.TxPool
+	txPool         TxPool
 	accountManager *accounts.Manager
+	autoMerging    bool
+	config         Config
+	chain          HeaderReader
 
 	accounts    sync.Map
 	pkrAccounts sync.Map
 
 	usedFlag sync.Map
 	numbers  sync.Map
+	// rescanned holds the number a pk was indexed to before its rescan
+	rescanned sync.Map
 
 	feed    event.Feed
 	updater event.Subscription        // Wallet update subscriptions for all backends
 	update  chan accounts.WalletEvent // Subscription sink for backend wallet changes
 	quit    chan chan error
 	lock    sync.RWMutex
+	// indexLock serializes the fetch job with rescans
+	indexLock sync.Mutex
+
+	mergeLock    sync.Mutex
+	mergeStatus  map[mergeKey]*MergeStatus
+	quietHours   []quietWindow
+	mergeTargets map[c_type.Uint512]c_type.PKr
+
+	webhook *webhook
 }
 
 var current_exchange *Exchange
@@ -124,17 +146,24 @@ func CurrentExchange() *Exchange {
 	return current_exchange
 }
 
-func NewExchange(dbpath string, txPool *core.TxPool, accountManager *accounts.Manager, autoMerge bool) (exchange *Exchange) {
+func NewExchange(dbpath string, txPool *core.TxPool, accountManager *accounts.Manager, autoMerge bool, config Config) (exchange *Exchange, err error) {
+	if err = config.Validate(); err != nil {
+		return
+	}
 
 	update := make(chan accounts.WalletEvent, 1)
 	updater := accountManager.Subscribe(update)
 
 	exchange = &Exchange{
-		txPool:         txPool,
 		accountManager: accountManager,
+		autoMerging:    autoMerge,
+		config:         config,
 		update:         update,
 		updater:        updater,
 	}
+	if txPool != nil {
+		exchange.txPool = txPool
+	}
 	current_exchange = exchange
 
 	db, err := serodb.NewLDBDatabase(dbpath, 1024, 1024)
@@ -142,30 +171,93 @@ func NewExchange(dbpath string, txPool *core.TxPool, accountManager *accounts.Ma
 		panic(err)
 	}
 	exchange.db = db
+	exchange.migratePkgIndex()
 
 	exchange.numbers = sync.Map{}
 	exchange.accounts = sync.Map{}
-	for _, w := range accountManager.Wallets() {
-		exchange.initWallet(w)
-	}
+	exchange.loadAccounts()
 
 	exchange.pkrAccounts = sync.Map{}
 	exchange.usedFlag = sync.Map{}
 
 	AddJob("0/10 * * * * ?", exchange.fetchBlockInfo)
 
+	exchange.mergeStatus = map[mergeKey]*MergeStatus{}
+	if len(exchange.config.MergePolicy.Rules) == 0 {
+		exchange.config.MergePolicy.Rules = map[string]MergeRule{"SERO": defaultMergeRule}
+	}
+	// the policy passed Validate above, parse does not fail here
+	exchange.quietHours, exchange.mergeTargets, _ = exchange.config.MergePolicy.parse()
 	if autoMerge {
 		AddJob("0 0/5 * * * ?", exchange.merge)
 	}
 
+	if exchange.config.PendingTimeout == 0 {
+		exchange.config.PendingTimeout = DefaultConfig.PendingTimeout
+	}
+	exchange.recoverUsedFlags()
+	AddJob("0/30 * * * * ?", exchange.checkPendingTxs)
+
+	if config.WebhookURL != "" {
+		exchange.startWebhook()
+	}
+
 	go exchange.updateAccount()
 	log.Info("Init NewExchange success")
 	return
 }
 
+// OpenExchange opens the index at dbpath for offline maintenance, it does not
+// follow the chain or the wallets.
+func OpenExchange(dbpath string, accountManager *accounts.Manager) (exchange *Exchange, err error) {
+	exchange = &Exchange{accountManager: accountManager}
+	if exchange.db, err = serodb.NewLDBDatabase(dbpath, 1024, 1024); err != nil {
+		return
+	}
+	exchange.migratePkgIndex()
+	exchange.loadAccounts()
+	return
+}
+
+// Stop ends the webhook deliveries.
+func (self *Exchange) Stop() {
+	if self.webhook != nil {
+		self.webhook.stop()
+	}
+}
+
+func (self *Exchange) Close() {
+	self.Stop()
+	self.db.Close()
+}
+
+func (self *Exchange) loadAccounts() {
+	for _, w := range self.accountManager.Wallets() {
+		self.initWallet(w)
+	}
+	self.initWatchAccounts()
+}
+
+// Pks returns the pks of all indexed accounts.
+func (self *Exchange) Pks() (pks []c_type.Uint512) {
+	self.accounts.Range(func(key, value interface{}) bool {
+		pks = append(pks, *value.(*Account).pk)
+		return true
+	})
+	return
+}
+
 func (self *Exchange) initWallet(w accounts.Wallet) {
 
-	if _, ok := self.accounts.Load(w.Accounts()[0].GetPk()); !ok {
+	if value, ok := self.accounts.Load(w.Accounts()[0].GetPk()); ok {
+		// the account is read without a lock, a copy replaces it
+		if account := *value.(*Account); account.watchOnly() {
+			account.wallet = w
+			account.version = w.Accounts()[0].Version
+			self.accounts.Store(*account.pk, &account)
+			log.Info("Watch-only PK got its wallet", "pk", w.Accounts()[0].Address)
+		}
+	} else {
 		account := Account{}
 		account.wallet = w
 		account.pk = w.Accounts()[0].GetPk().NewRef()
@@ -183,6 +275,7 @@ func (self *Exchange) initWallet(w accounts.Wallet) {
 			}
 		}
 
+		self.markHistoryFrom(*account.pk)
 		if num := self.starNum(account.pk); num > w.Accounts()[0].At {
 			self.numbers.Store(*account.pk, num)
 		} else {
@@ -193,6 +286,21 @@ func (self *Exchange) initWallet(w accounts.Wallet) {
 	}
 }
 
+// dropWallet stops indexing the account of the wallet, unless its TK is
+// watched, which keeps it as a watch-only account.
+func (self *Exchange) dropWallet(w accounts.Wallet) {
+	pk := w.Accounts()[0].Address.ToUint512()
+	if has, _ := self.db.Has(watchTkKey(pk)); has {
+		if value, ok := self.accounts.Load(pk); ok {
+			account := *value.(*Account)
+			account.wallet = nil
+			self.accounts.Store(pk, &account)
+		}
+		return
+	}
+	self.numbers.Delete(pk)
+}
+
 func (self *Exchange) starNum(pk *c_type.Uint512) uint64 {
 	value, err := self.db.Get(numKey(*pk))
 	if err != nil {
@@ -262,8 +370,7 @@ func (self *Exchange) updateAccount() {
 				// wallet := event.Wallet
 				self.initWallet(event.Wallet)
 			case accounts.WalletDropped:
-				address := event.Wallet.Accounts()[0].Address
-				self.numbers.Delete(address.ToUint512())
+				self.dropWallet(event.Wallet)
 			}
 			self.lock.Unlock()
 
@@ -314,7 +421,7 @@ func (self *Exchange) GetPkr(pk *c_type.Uint512, index *c_type.Uint256) (pkr c_t
 		return pkr, errors.New("not found Pk")
 	} else {
 		acc := value.(*Account)
-		return acc.wallet.Accounts()[0].GetPkr(index), nil
+		return superzk.Pk2PKr(acc.pk, index), nil
 
 	}
 
@@ -577,6 +684,9 @@ func (self *Exchange) GenTxWithSign(param prepare.PreTxParam) (pretx *txtool.GTx
 		e = errors.New("exchange instance is nil")
 		return
 	}
+	if param.Selector == "" {
+		param.Selector = self.config.CoinSelector
+	}
 	var roots prepare.Utxos
 	if roots, e = prepare.SelectUtxos(&param, self); e != nil {
 		return
@@ -606,6 +716,13 @@ func (self *Exchange) GenTxWithSign(param prepare.PreTxParam) (pretx *txtool.GTx
 		param.Cmds,
 	}
 
+	if account.watchOnly() {
+		if pretx, e = self.buildTxParam(&bparam); e == nil {
+			e = ErrWatchOnly
+		}
+		return
+	}
+
 	if pretx, tx, e = self.genTx(account, &bparam); e != nil {
 		log.Error("Exchange genTx", "error", e)
 		return
@@ -670,13 +787,31 @@ func (self *Exchange) genTx(account *Account, param *prepare.BeforeTxParam) (txP
 	}
 }
 
-func (self *Exchange) commitTx(tx *txtool.GTx) (err error) {
+// CommitTx sends a tx of GenTxWithSign to the tx pool and tracks it, the
+// used flags of its utxos are cleared when the pool refuses it.
+func (self *Exchange) CommitTx(txParam *txtool.GTxParam, tx *txtool.GTx) (err error) {
+	if err = self.commitTx(txParam, tx); err != nil {
+		self.ClearTxParam(txParam)
+	}
+	return
+}
+
+func (self *Exchange) commitTx(txParam *txtool.GTxParam, tx *txtool.GTx) (err error) {
 	gasPrice := big.Int(tx.GasPrice)
 	gas := uint64(tx.Gas)
 	signedTx := types.NewTxWithGTx(gas, &gasPrice, &tx.Tx)
 	log.Info("Exchange commitTx", "txhash", signedTx.Hash().String())
-	err = self.txPool.AddLocal(signedTx)
-	return err
+	if err = self.txPool.AddLocal(signedTx); err != nil {
+		return
+	}
+	roots := []c_type.Uint256{}
+	for _, in := range txParam.Ins {
+		roots = append(roots, in.Out.Root)
+	}
+	if e := self.TrackTx(tx, roots); e != nil {
+		log.Error("Exchange track tx", "txhash", signedTx.Hash().String(), "error", e)
+	}
+	return
 }
 
 func (self *Exchange) iteratorUtxo(Pk *c_type.Uint512, begin, end uint64, handler HandleUtxoFunc) (e error) {
@@ -796,6 +931,30 @@ func (self *Exchange) findUtxos(pk *c_type.Uint512, currency string, amount *big
 	return
 }
 
+func (self *Exchange) findCandidates(pk *c_type.Uint512, currency string) (utxos []Utxo) {
+	currency = strings.ToUpper(currency)
+	prefix := append(pkPrefix, append(pk[:], common.LeftPadBytes([]byte(currency), 32)...)...)
+	iterator := self.db.NewIteratorWithPrefix(prefix)
+
+	for iterator.Next() {
+		key := iterator.Key()
+		var root c_type.Uint256
+		copy(root[:], key[98:130])
+
+		if utxo, err := self.getUtxo(root); err == nil {
+			if utxo.Ignore {
+				continue
+			}
+			if utxo.Asset.Tkn != nil {
+				if _, ok := self.usedFlag.Load(utxo.Root); !ok {
+					utxos = append(utxos, utxo)
+				}
+			}
+		}
+	}
+	return
+}
+
 func DecOuts(outs []txtool.Out, skr *c_type.PKr) (douts []txtool.DOut) {
 	tk := c_type.Tk{}
 	copy(tk[:], skr[:])
@@ -831,6 +990,9 @@ func (self *Exchange) fetchBlockInfo() {
 	if txtool.Ref_inst.Bc == nil || !txtool.Ref_inst.Bc.IsValid() {
 		return
 	}
+	self.indexLock.Lock()
+	defer self.indexLock.Unlock()
+	self.checkReorg()
 	for {
 		indexs := map[uint64][]c_type.Uint512{}
 		orders := uint64Slice{}
@@ -885,9 +1047,25 @@ func (self *Exchange) fetchAndIndexUtxo(start, countBlock uint64, pks []c_type.U
 		return
 	}
 
+	return self.indexUtxo(blocks, pks)
+}
+
+func (self *Exchange) indexUtxo(blocks []txtool.Block, pks []c_type.Uint512) (count int) {
+	if fork, stale := self.staleBlocks(blocks); stale {
+		log.Warn("Exchange indexed a stale block", "fork", fork)
+		if err := self.rollback(fork); err != nil {
+			log.Error("Exchange rollback", "fork", fork, "error", err)
+		}
+		return
+	}
+
+	var err error
 	utxosMap := map[PkKey][]Utxo{}
 	nilsMap := map[c_type.Uint256]Utxo{}
 	nils := []c_type.Uint256{}
+	rootPks := map[c_type.Uint256]c_type.Uint512{}
+	spentMap := map[PkKey][]c_type.Uint256{}
+	events := []Event{}
 	blockMap := map[uint64]*BlockInfo{}
 	for _, block := range blocks {
 		num := uint64(block.Num)
@@ -915,6 +1093,7 @@ func (self *Exchange) fetchAndIndexUtxo(start, countBlock uint64, pks []c_type.U
 			utxo := Utxo{Pkr: *pkr, Root: out.Root, Nil: dout.Nil, TxHash: out.State.TxHash, Num: out.State.Num, Asset: dout.Asset, IsZ: out.State.OS.IsZero()}
 			nilsMap[utxo.Root] = utxo
 			nilsMap[utxo.Nil] = utxo
+			rootPks[utxo.Root] = *account.pk
 
 			if list, ok := utxosMap[key]; ok {
 				utxosMap[key] = append(list, utxo)
@@ -922,6 +1101,7 @@ func (self *Exchange) fetchAndIndexUtxo(start, countBlock uint64, pks []c_type.U
 				utxosMap[key] = []Utxo{utxo}
 			}
 			utxos = append(utxos, utxo)
+			events = append(events, newUtxoEvent(EventUtxoIn, *account.pk, block.Hash, num, &utxo))
 		}
 
 		if len(utxos) > 0 {
@@ -932,8 +1112,10 @@ func (self *Exchange) fetchAndIndexUtxo(start, countBlock uint64, pks []c_type.U
 			roots := []c_type.Uint256{}
 			for _, Nil := range block.Nils {
 				var utxo Utxo
+				var pk c_type.Uint512
 				if value, ok := nilsMap[Nil]; ok {
 					utxo = value
+					pk = rootPks[utxo.Root]
 				} else {
 					value, _ := self.db.Get(nilKey(Nil))
 					if value != nil {
@@ -943,7 +1125,6 @@ func (self *Exchange) fetchAndIndexUtxo(start, countBlock uint64, pks []c_type.U
 						if utxo, err = self.getUtxo(root); err != nil {
 							continue
 						} else {
-							var pk c_type.Uint512
 							copy(pk[:], value[2:66])
 						}
 					} else {
@@ -952,6 +1133,9 @@ func (self *Exchange) fetchAndIndexUtxo(start, countBlock uint64, pks []c_type.U
 				}
 				nils = append(nils, Nil)
 				roots = append(roots, utxo.Root)
+				key := PkKey{key: pk, Num: num}
+				spentMap[key] = append(spentMap[key], utxo.Root)
+				events = append(events, newUtxoEvent(EventUtxoSpent, pk, block.Hash, num, &utxo))
 			}
 			if len(roots) > 0 {
 				if blockInfo, ok := blockMap[num]; ok {
@@ -965,7 +1149,7 @@ func (self *Exchange) fetchAndIndexUtxo(start, countBlock uint64, pks []c_type.U
 
 	batch := self.db.NewBatch()
 
-	self.indexPkgs(pks, batch, blocks)
+	events = append(events, self.indexPkgs(pks, batch, blocks)...)
 
 	var roots []c_type.Uint256
 	if len(utxosMap) > 0 || len(nils) > 0 {
@@ -975,6 +1159,26 @@ func (self *Exchange) fetchAndIndexUtxo(start, countBlock uint64, pks []c_type.U
 		}
 	}
 
+	// "SPENT" + num + PK => [roots], kept to undo the spending on reorg
+	for key, spents := range spentMap {
+		data, e := rlp.EncodeToBytes(&spents)
+		if e != nil {
+			log.Error("index spent roots", "error", e)
+			return
+		}
+		batch.Put(spentKey(key.Num, key.key), data)
+	}
+
+	// "HASH" + num => hash, only the last reorg.MaxDepth blocks are kept
+	for _, block := range blocks {
+		num := uint64(block.Num)
+		batch.Put(hashKey(num), block.Hash[:])
+		if stale, ok := reorg.Stale(num); ok {
+			batch.Delete(hashKey(stale))
+			batch.Delete(undoPkgKey(stale))
+		}
+	}
+
 	count = len(blocks)
 	num := uint64(blocks[count-1].Num) + 1
 	// "NUM"+PK  => Num
@@ -985,9 +1189,17 @@ func (self *Exchange) fetchAndIndexUtxo(start, countBlock uint64, pks []c_type.U
 
 	err = batch.Write()
 	if err == nil {
+		events = self.unseenEvents(events)
 		for _, pk := range pks {
 			self.numbers.Store(pk, num)
+			if value, ok := self.rescanned.Load(pk); ok && value.(uint64) <= num {
+				self.rescanned.Delete(pk)
+			}
 		}
+		sort.SliceStable(events, func(i, j int) bool {
+			return events[i].Num < events[j].Num
+		})
+		self.publish(events)
 	}
 
 	for _, root := range roots {
@@ -1001,6 +1213,9 @@ func (self *Exchange) indexBlocks(batch serodb.Batch, utxosMap map[PkKey][]Utxo,
 	ops := map[string]string{}
 
 	for num, blockInfo := range blockMap {
+		if stored := self.getBlockInfo(num); stored != nil {
+			blockInfo.merge(stored)
+		}
 		data, e := rlp.EncodeToBytes(&blockInfo)
 		if e != nil {
 			err = e
@@ -1174,7 +1389,10 @@ type MergeUtxos struct {
 	//tickets map[c_type.Uint256]c_type.Uint256
 }
 
-var default_fee_value = new(big.Int).Mul(big.NewInt(25000), big.NewInt(1000000000))
+// DefaultGas is the gas of the txs the exchange builds on its own.
+const DefaultGas = 25000
+
+var default_fee_value = new(big.Int).Mul(big.NewInt(DefaultGas), big.NewInt(1000000000))
 
 func (self *Exchange) getMergeUtxos(from *c_type.Uint512, currency string, zcount int, left int, icount int) (mu MergeUtxos, e error) {
 	if zcount > 400 {
@@ -1256,7 +1474,7 @@ func (self *Exchange) GenMergeTx(mp *MergeParam) (txParam *txtool.GTxParam, e er
 		return
 	}
 	if mp.To == nil {
-		mp.To = account.wallet.Accounts()[0].GetDefaultPkr(1).NewRef()
+		mp.To = account.mainPkr.NewRef()
 	}
 	var mu MergeUtxos
 	if mu, e = self.getMergeUtxos(account.pk, mp.Currency, int(mp.Zcount), int(mp.Left), int(mp.Icount)); e != nil {
@@ -1324,6 +1542,10 @@ func (self *Exchange) Merge(pk *c_type.Uint512, currency string, force bool) (co
 		e = errors.New("account is nil")
 		return
 	}
+	if account.watchOnly() {
+		e = ErrWatchOnly
+		return
+	}
 
 	seed, err := account.wallet.GetSeed()
 	if err != nil || seed == nil {
@@ -1331,12 +1553,14 @@ func (self *Exchange) Merge(pk *c_type.Uint512, currency string, force bool) (co
 		return
 	}
 
-	var mu MergeUtxos
-	if mu, e = self.getMergeUtxos(account.pk, currency, 100, 50, 0); e != nil {
-		return
-	}
-
-	if mu.zcount >= 100 || mu.ocount >= 2400 || time.Now().After(account.nextMergeTime) || force {
+	rule := self.mergeRule(currency)
+	reason, utxoCount := self.needMerge(account, currency, &rule)
+	if reason != "" || force {
+		var mu MergeUtxos
+		if mu, e = self.getMergeUtxos(account.pk, currency, rule.MaxInputs, rule.Keep, 0); e != nil {
+			return
+		}
+		to := self.mergeTarget(account)
 
 		count = mu.list.Len()
 		bytes := common.LeftPadBytes([]byte(currency), 32)
@@ -1353,7 +1577,7 @@ func (self *Exchange) Merge(pk *c_type.Uint512, currency string, force bool) (co
 
 		for _, utxo := range ck.Tkns() {
 			receptions = append(receptions, prepare.Reception{
-				Addr: account.mainPkr,
+				Addr: to,
 				Asset: assets.Asset{
 					Tkn: &assets.Token{
 						Currency: utxo.Currency,
@@ -1365,7 +1589,7 @@ func (self *Exchange) Merge(pk *c_type.Uint512, currency string, force bool) (co
 
 		for _, utxo := range ck.Tkts() {
 			receptions = append(receptions, prepare.Reception{
-				Addr: account.mainPkr,
+				Addr: to,
 				Asset: assets.Asset{
 					Tkt: &assets.Ticket{
 						Category: utxo.Category,
@@ -1382,32 +1606,52 @@ func (self *Exchange) Merge(pk *c_type.Uint512, currency string, force bool) (co
 			},
 			*big.NewInt(1000000000),
 			mu.list.Roots(),
-			account.mainPkr,
+			to,
 			receptions,
 			prepare.Cmds{},
 		}
 
 		pretx, gtx, err := self.genTx(account, &bparam)
 		if err != nil {
-			account.nextMergeTime = time.Now().Add(time.Hour * 6)
+			account.nextMergeTime = time.Now().Add(rule.Interval)
 			e = err
 			return
 		}
 		txhash = gtx.Hash
-		if err := self.commitTx(gtx); err != nil {
-			account.nextMergeTime = time.Now().Add(time.Hour * 6)
+		if err := self.commitTx(pretx, gtx); err != nil {
+			account.nextMergeTime = time.Now().Add(rule.Interval)
 			self.ClearTxParam(pretx)
 			e = err
 			return
 		}
-		if mu.list.Len() < 100 {
-			account.nextMergeTime = time.Now().Add(time.Hour * 6)
+		self.mergeDone(*account.pk, currency, count, txhash, default_fee_value)
+		if mu.list.Len() < rule.MaxInputs {
+			account.nextMergeTime = time.Now().Add(rule.Interval)
 		}
 		return
 	} else {
-		e = fmt.Errorf("no need to merge the account, utxo count == %v", mu.list.Len())
+		e = fmt.Errorf("no need to merge the account, utxo count == %v", utxoCount)
+		return
+	}
+}
+
+func (self *Exchange) autoMerge(account *Account, currency string) {
+	now := time.Now()
+	if self.inQuietHours(now) {
+		self.mergeSkipped(*account.pk, currency, "quiet hours")
 		return
 	}
+	if !self.mergeFeeAllowed(now, default_fee_value) {
+		self.mergeSkipped(*account.pk, currency, "daily fee budget spent")
+		return
+	}
+	if count, txhash, err := self.Merge(account.pk, currency, false); err != nil {
+		self.mergeSkipped(*account.pk, currency, err.Error())
+		log.Error("autoMerge fail", "accountKey", *utils.Base58Encode(account.pk[:]), "currency", currency, "count", count, "error", err)
+	} else {
+		self.mergeSkipped(*account.pk, currency, "")
+		log.Info("autoMerge succ", "accountKey", *utils.Base58Encode(account.pk[:]), "currency", currency, "tx", hexutil.Encode(txhash[:]), "count", count)
+	}
 }
 
 func (self *Exchange) merge() {
@@ -1416,10 +1660,11 @@ func (self *Exchange) merge() {
 	}
 	self.accounts.Range(func(key, value interface{}) bool {
 		account := value.(*Account)
-		if count, txhash, err := self.Merge(account.pk, "SERO", false); err != nil {
-			log.Error("autoMerge fail", "accountKey", *utils.Base58Encode(account.pk[:]), "count", count, "error", err)
-		} else {
-			log.Info("autoMerge succ", "accountKey", *utils.Base58Encode(account.pk[:]), "tx", hexutil.Encode(txhash[:]), "count", count)
+		if account.watchOnly() {
+			return true
+		}
+		for _, currency := range self.mergeCurrencies() {
+			self.autoMerge(account, currency)
 		}
 		return true
 	})
@@ -12,6 +12,9 @@ import (
 )
 
 func (self *Exchange) GenTx(param prepare.PreTxParam) (txParam *txtool.GTxParam, e error) {
+	if param.Selector == "" {
+		param.Selector = self.config.CoinSelector
+	}
 	txParam, e = prepare.GenTxParam(&param, self, &prepare.DefaultTxParamState{})
 	if e == nil && txParam != nil {
 		for _, in := range txParam.Ins {
@@ -21,6 +24,29 @@ func (self *Exchange) GenTx(param prepare.PreTxParam) (txParam *txtool.GTxParam,
 	return
 }
 
+func (self *Exchange) CompareSelectors(param prepare.PreTxParam) (selections []prepare.Selection) {
+	for _, name := range prepare.CoinSelectorNames() {
+		param.Selector = name
+		_, selection, e := prepare.SelectUtxosWithReport(&param, self)
+		if e != nil {
+			selection.Strategy = name
+			selection.Error = e.Error()
+		}
+		selections = append(selections, selection)
+	}
+	return
+}
+
+// EstimateSelection selects the utxos of param like GenTx does without
+// locking them.
+func (self *Exchange) EstimateSelection(param prepare.PreTxParam) (prepare.Selection, error) {
+	if param.Selector == "" {
+		param.Selector = self.config.CoinSelector
+	}
+	_, selection, e := prepare.SelectUtxosWithReport(&param, self)
+	return selection, e
+}
+
 func (self *Exchange) buildTxParam(param *prepare.BeforeTxParam) (txParam *txtool.GTxParam, e error) {
 
 	txParam, e = prepare.BuildTxParam(&prepare.DefaultTxParamState{}, param)
@@ -42,6 +68,13 @@ func (self *Exchange) FindRoots(pk *c_type.Uint512, currency string, amount *big
 	return
 }
 
+func (self *Exchange) FindCandidates(pk *c_type.Uint512, currency string) (roots prepare.Utxos) {
+	for _, utxo := range self.findCandidates(pk, currency) {
+		roots = append(roots, prepare.Utxo{utxo.Root, utxo.Asset})
+	}
+	return
+}
+
 func (self *Exchange) FindRootsByTicket(pk *c_type.Uint512, tickets []assets.Ticket) (roots prepare.Utxos, remain map[c_type.Uint256]c_type.Uint256) {
 	utxos, remain := self.findUtxosByTicket(pk, tickets)
 	for _, utxo := range utxos {
@@ -0,0 +1,359 @@
+package exchange
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/btcsuite/btcutil/base58"
+	"github.com/sero-cash/go-czero-import/c_type"
+	"github.com/sero-cash/go-czero-import/superzk"
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/common/address"
+	"github.com/sero-cash/go-sero/common/hexutil"
+	"github.com/sero-cash/go-sero/core/types"
+	"github.com/sero-cash/go-sero/log"
+	"github.com/sero-cash/go-sero/rlp"
+	"github.com/sero-cash/go-sero/zero/txs/stx"
+	"github.com/sero-cash/go-sero/zero/utils"
+)
+
+const (
+	HistorySend         = "send"
+	HistoryReceive      = "receive"
+	HistorySelfTransfer = "self-transfer"
+	HistoryMerge        = "merge"
+	HistoryStake        = "stake"
+	HistoryPkg          = "pkg"
+)
+
+// HistoryTx is one tx of an account. Amounts holds the net change per
+// currency, for the txs the account paid the fee is part of it.
+type HistoryTx struct {
+	TxHash      c_type.Uint256
+	Num         uint64
+	BlockHash   c_type.Uint256
+	Timestamp   uint64
+	Type        string
+	From        address.MixBase58Adrress   `json:",omitempty"`
+	To          []address.MixBase58Adrress `json:",omitempty"`
+	Ins         []Utxo
+	Outs        []Utxo
+	Amounts     map[string]*big.Int
+	FeeCurrency string   `json:",omitempty"`
+	Fee         *big.Int `json:",omitempty"`
+}
+
+var historyFromPrefix = []byte("HISTORYFROM")
+
+// "HISTORYFROM" + pk => num the spends of the pk are indexed from
+func historyFromKey(pk c_type.Uint512) []byte {
+	return append(historyFromPrefix, pk[:]...)
+}
+
+// markHistoryFrom notes the block the SPENT records of the pk start at. An
+// account indexed before they were kept has them from its indexed number on.
+func (self *Exchange) markHistoryFrom(pk c_type.Uint512) {
+	if has, _ := self.db.Has(historyFromKey(pk)); has {
+		return
+	}
+	if err := self.db.Put(historyFromKey(pk), utils.EncodeNumber(self.starNum(&pk))); err != nil {
+		log.Error("Exchange mark history", "error", err)
+	}
+}
+
+func (self *Exchange) historyFrom(pk c_type.Uint512) uint64 {
+	value, err := self.db.Get(historyFromKey(pk))
+	if err != nil {
+		return 0
+	}
+	return utils.DecodeNumber(value)
+}
+
+type blockReader interface {
+	GetBlockByNumber(number uint64) *types.Block
+}
+
+// txNils lists the nils and roots the tx spends, the index knows a spent
+// utxo by either of them.
+func txNils(tx *stx.T) (nils []c_type.Uint256) {
+	if tx0 := tx.Tx0(); tx0 != nil {
+		for _, in := range tx0.Desc_O.Ins {
+			nils = append(nils, in.Nil, in.Root)
+		}
+		for _, in := range tx0.Desc_Z.Ins {
+			nils = append(nils, in.Nil)
+		}
+	}
+	for _, in := range tx.Tx1.Ins_P0 {
+		nils = append(nils, in.Nil, in.Root)
+	}
+	for _, in := range tx.Tx1.Ins_P {
+		nils = append(nils, in.Nil, in.Root)
+	}
+	for _, in := range tx.Tx1.Ins_C {
+		nils = append(nils, in.Nil)
+	}
+	return
+}
+
+func txPkrs(tx *stx.T) (pkrs []c_type.PKr) {
+	if tx0 := tx.Tx0(); tx0 != nil {
+		for _, out := range tx0.Desc_O.Outs {
+			pkrs = append(pkrs, out.Addr)
+		}
+		for _, out := range tx0.Desc_Z.Outs {
+			pkrs = append(pkrs, out.PKr)
+		}
+	}
+	for _, out := range tx.Tx1.Outs_P {
+		pkrs = append(pkrs, out.PKr)
+	}
+	for _, out := range tx.Tx1.Outs_C {
+		pkrs = append(pkrs, out.PKr)
+	}
+	return
+}
+
+func addAmount(amounts map[string]*big.Int, utxo *Utxo, sign int) {
+	if utxo.Asset.Tkn == nil {
+		return
+	}
+	currency := common.BytesToString(utxo.Asset.Tkn.Currency[:])
+	if _, ok := amounts[currency]; !ok {
+		amounts[currency] = new(big.Int)
+	}
+	value := utxo.Asset.Tkn.Value.ToIntRef()
+	if sign < 0 {
+		amounts[currency].Sub(amounts[currency], value)
+	} else {
+		amounts[currency].Add(amounts[currency], value)
+	}
+}
+
+func (self *HistoryTx) classify(account *Account, tx *stx.T) {
+	self.Type = HistorySend
+	if len(self.Ins) == 0 {
+		self.Type = HistoryReceive
+	}
+	if tx == nil {
+		return
+	}
+	if tx.From != (c_type.PKr{}) {
+		self.From = address.MixBase58Adrress(common.CopyBytes(tx.From[:]))
+	}
+	if len(self.Ins) > 0 {
+		self.FeeCurrency = common.BytesToString(tx.Fee.Currency[:])
+		self.Fee = tx.Fee.Value.ToIntRef()
+		ours := map[c_type.PKr]bool{}
+		for _, out := range self.Outs {
+			ours[out.Pkr] = true
+		}
+		for _, pkr := range txPkrs(tx) {
+			if !ours[pkr] && !superzk.IsMyPKr(account.tk, &pkr) {
+				self.To = append(self.To, address.MixBase58Adrress(common.CopyBytes(pkr[:])))
+			}
+		}
+	}
+	switch {
+	case tx.Desc_Cmd.BuyShare != nil || tx.Desc_Cmd.RegistPool != nil || tx.Desc_Cmd.ClosePool != nil:
+		self.Type = HistoryStake
+	case tx.Desc_Pkg.Create != nil || tx.Desc_Pkg.Transfer != nil || tx.Desc_Pkg.Close != nil:
+		self.Type = HistoryPkg
+	case len(self.Ins) > 0 && len(self.To) == 0:
+		if len(self.Ins) > 1 && len(self.Outs) == 1 {
+			self.Type = HistoryMerge
+		} else {
+			self.Type = HistorySelfTransfer
+		}
+	}
+}
+
+func (self *Exchange) iterateRoots(prefix []byte, pk c_type.Uint512, begin, end uint64, handler func(num uint64, root c_type.Uint256)) (e error) {
+	iterator := self.db.NewIteratorWithPrefix(prefix)
+	defer iterator.Release()
+	for ok := iterator.Seek(append(append([]byte{}, prefix...), utils.EncodeNumber(begin)...)); ok; ok = iterator.Next() {
+		key := iterator.Key()
+		if len(key) != len(prefix)+8+64 {
+			continue
+		}
+		num := utils.DecodeNumber(key[len(prefix) : len(prefix)+8])
+		if num >= end {
+			break
+		}
+		if !bytes.Equal(key[len(prefix)+8:], pk[:]) {
+			continue
+		}
+		var roots []c_type.Uint256
+		if e = rlp.DecodeBytes(iterator.Value(), &roots); e != nil {
+			return
+		}
+		for _, root := range roots {
+			handler(num, root)
+		}
+	}
+	return
+}
+
+// GetHistory groups the utxos the pk created and spent in [begin, end) by
+// their tx. The spending txs are looked up in the chain, the spent utxos of a
+// block that has no body at hand are reported under an empty tx hash. The
+// blocks indexed before the spends were kept need a rescan first.
+func (self *Exchange) GetHistory(pk c_type.Uint512, begin, end uint64) (txs []HistoryTx, e error) {
+	account := self.getAccountByPk(pk)
+	if account == nil {
+		e = errors.New("not found Pk")
+		return
+	}
+	if from := self.historyFrom(pk); begin < from {
+		e = fmt.Errorf("the spends of the pk before block %v are not indexed, rescan it from %v first", from, begin)
+		return
+	}
+
+	type txKey struct {
+		num  uint64
+		hash c_type.Uint256
+	}
+	history := map[txKey]*HistoryTx{}
+	get := func(num uint64, hash c_type.Uint256) *HistoryTx {
+		key := txKey{num, hash}
+		if h, ok := history[key]; ok {
+			return h
+		}
+		h := &HistoryTx{TxHash: hash, Num: num, Amounts: map[string]*big.Int{}}
+		history[key] = h
+		return h
+	}
+
+	spent := map[uint64][]Utxo{}
+	nums := map[uint64]bool{}
+	if e = self.iterateRoots(utxoPrefix, pk, begin, end, func(num uint64, root c_type.Uint256) {
+		if utxo, err := self.getUtxo(root); err == nil && utxo.Root == root {
+			h := get(num, utxo.TxHash)
+			h.Outs = append(h.Outs, utxo)
+			addAmount(h.Amounts, &utxo, 1)
+			nums[num] = true
+		}
+	}); e != nil {
+		return
+	}
+	if e = self.iterateRoots(spentPrefix, pk, begin, end, func(num uint64, root c_type.Uint256) {
+		if utxo, err := self.getUtxo(root); err == nil && utxo.Root == root {
+			spent[num] = append(spent[num], utxo)
+			nums[num] = true
+		}
+	}); e != nil {
+		return
+	}
+
+	chain := self.headerReader()
+	bodies, _ := chain.(blockReader)
+	stxs := map[c_type.Uint256]*stx.T{}
+	headers := map[uint64]*types.Header{}
+	for num := range nums {
+		var block *types.Block
+		if bodies != nil {
+			block = bodies.GetBlockByNumber(num)
+		}
+		nilTxs := map[c_type.Uint256]c_type.Uint256{}
+		if block != nil {
+			for _, tx := range block.Transactions() {
+				hash := *tx.Hash().HashToUint256()
+				stxs[hash] = tx.GetZZSTX()
+				for _, n := range txNils(tx.GetZZSTX()) {
+					nilTxs[n] = hash
+				}
+			}
+		}
+		for _, utxo := range spent[num] {
+			hash, ok := nilTxs[utxo.Nil]
+			if !ok {
+				hash = nilTxs[utxo.Root]
+			}
+			h := get(num, hash)
+			h.Ins = append(h.Ins, utxo)
+			addAmount(h.Amounts, &utxo, -1)
+		}
+		if block != nil {
+			headers[num] = block.Header()
+		} else if chain != nil {
+			headers[num] = chain.GetHeaderByNumber(num)
+		}
+	}
+
+	for _, h := range history {
+		if header := headers[h.Num]; header != nil {
+			h.BlockHash = *header.Hash().HashToUint256()
+			h.Timestamp = header.Time.Uint64()
+		}
+		h.classify(account, stxs[h.TxHash])
+		txs = append(txs, *h)
+	}
+	sort.Slice(txs, func(i, j int) bool {
+		if txs[i].Num != txs[j].Num {
+			return txs[i].Num < txs[j].Num
+		}
+		return string(txs[i].TxHash[:]) < string(txs[j].TxHash[:])
+	})
+	return
+}
+
+var historyCSVHeader = []string{"txHash", "block", "timestamp", "type", "currency", "amount", "feeCurrency", "fee", "from", "to"}
+
+// WriteHistoryCSV writes a row per tx and currency.
+func WriteHistoryCSV(w io.Writer, txs []HistoryTx) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(historyCSVHeader); err != nil {
+		return err
+	}
+	for _, tx := range txs {
+		currencies := []string{}
+		for currency := range tx.Amounts {
+			currencies = append(currencies, currency)
+		}
+		sort.Strings(currencies)
+		if len(currencies) == 0 {
+			currencies = append(currencies, "")
+		}
+		var from, fee string
+		if tx.From != nil {
+			from = base58.Encode(tx.From)
+		}
+		if tx.Fee != nil {
+			fee = tx.Fee.String()
+		}
+		to := []string{}
+		for _, pkr := range tx.To {
+			to = append(to, base58.Encode(pkr))
+		}
+		for _, currency := range currencies {
+			amount := ""
+			if value, ok := tx.Amounts[currency]; ok {
+				amount = value.String()
+			}
+			row := []string{
+				hexutil.Encode(tx.TxHash[:]),
+				strconv.FormatUint(tx.Num, 10),
+				strconv.FormatUint(tx.Timestamp, 10),
+				tx.Type,
+				currency,
+				amount,
+				tx.FeeCurrency,
+				fee,
+				from,
+				strings.Join(to, ";"),
+			}
+			if err := writer.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,103 @@
+package exchange
+
+import (
+	"bytes"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/sero-cash/go-czero-import/c_type"
+	"github.com/sero-cash/go-sero/core/types"
+	"github.com/sero-cash/go-sero/zero/txs/assets"
+	"github.com/sero-cash/go-sero/zero/txs/stx"
+	"github.com/sero-cash/go-sero/zero/txs/stx/stx_v1"
+	"github.com/sero-cash/go-sero/zero/txtool"
+	"github.com/sero-cash/go-sero/zero/utils"
+)
+
+func TestHistory(t *testing.T) {
+	env := newTestEnv(3)
+	blocks := env.blocks
+	account := newTestAccount(1)
+	outs := map[uint64]txtool.Out{}
+	infos := testBlocks(account, blocks[:2], []int64{1, 2}, nil, outs)
+	nils := DecOuts([]txtool.Out{outs[1], outs[2]}, &account.skr)
+
+	// Block 3 holds a tx spending both outs, paying a foreign pkr and 2 back.
+	foreign := c_type.PKr{0xff}
+	tx := types.NewTxWithGTx(25000, big.NewInt(1), &stx.T{
+		Fee: assets.Token{Currency: utils.CurrencyToUint256("SERO"), Value: utils.U256(*big.NewInt(1))},
+		Tx1: stx_v1.Tx{
+			Ins_P:  []stx_v1.In_P{{Root: outs[1].Root, Nil: nils[0].Nil}, {Root: outs[2].Root, Nil: nils[1].Nil}},
+			Outs_P: []stx_v1.Out_P{{PKr: foreign}, {PKr: *account.balancePkr}},
+		},
+	})
+	block := types.NewBlock(blocks[2].Header(), []*types.Transaction{tx}, nil)
+	chain := &testChain{blocks: []*types.Block{env.genesis, blocks[0], blocks[1], block}}
+	info := testBlocks(account, []*types.Block{block}, []int64{2}, nil, outs)[0]
+	info.Outs[0].State.TxHash = *tx.Hash().HashToUint256()
+	info.Nils = []c_type.Uint256{nils[0].Nil, nils[1].Nil}
+	infos = append(infos, info)
+
+	exchange, closer := newTestExchange(t, chain, *account)
+	defer closer()
+	exchange.indexUtxo(infos, []c_type.Uint512{*account.pk})
+
+	txs, err := exchange.GetHistory(*account.pk, 1, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(txs) != 3 {
+		t.Fatalf("got %v txs, want 3", len(txs))
+	}
+	for i, value := range []int64{1, 2} {
+		if txs[i].Type != HistoryReceive || txs[i].Amounts["SERO"].Int64() != value {
+			t.Fatalf("tx %v is %v of %v, want receive of %v", i, txs[i].Type, txs[i].Amounts["SERO"], value)
+		}
+	}
+	send := txs[2]
+	if send.Type != HistorySend || send.TxHash != *tx.Hash().HashToUint256() || len(send.Ins) != 2 || len(send.Outs) != 1 {
+		t.Fatalf("got %v with %v ins and %v outs, want send with 2 ins and 1 out", send.Type, len(send.Ins), len(send.Outs))
+	}
+	if send.Amounts["SERO"].Int64() != -1 || send.Fee.Int64() != 1 || send.FeeCurrency != "SERO" {
+		t.Fatalf("got amount %v and fee %v %v, want -1 and 1 SERO", send.Amounts["SERO"], send.Fee, send.FeeCurrency)
+	}
+	if len(send.To) != 1 || !bytes.Equal(send.To[0], foreign[:]) {
+		t.Fatalf("got counterparties %v, want the foreign pkr", send.To)
+	}
+	if send.Timestamp != block.Time().Uint64() {
+		t.Fatalf("got timestamp %v, want %v", send.Timestamp, block.Time())
+	}
+
+	if txs, _ = exchange.GetHistory(*account.pk, 2, 3); len(txs) != 1 || txs[0].Num != 2 {
+		t.Fatalf("got %v txs for block 2, want the receive at 2", len(txs))
+	}
+
+	var buf bytes.Buffer
+	if err := WriteHistoryCSV(&buf, txs); err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(buf.String()), "\n"); len(lines) != 2 || !strings.Contains(lines[1], ",receive,SERO,2,") {
+		t.Fatalf("unexpected csv %q", buf.String())
+	}
+
+	// An account indexed before the spends were kept has no SPENT records,
+	// its history needs a rescan.
+	pk := *account.pk
+	exchange.db.Delete(spentKey(3, pk))
+	exchange.db.Delete(historyFromKey(pk))
+	exchange.markHistoryFrom(pk)
+	if _, err := exchange.GetHistory(pk, 1, 4); err == nil {
+		t.Fatal("history without the spends returned")
+	}
+	if _, err := exchange.GetHistory(pk, 4, 5); err != nil {
+		t.Fatal(err)
+	}
+	if err := exchange.prepareRescan([]c_type.Uint512{pk}, 1); err != nil {
+		t.Fatal(err)
+	}
+	exchange.indexUtxo(infos, []c_type.Uint512{pk})
+	if txs, err = exchange.GetHistory(pk, 1, 4); err != nil || len(txs) != 3 || txs[2].Type != HistorySend {
+		t.Fatalf("got %v txs and %v after the rescan, want the send", len(txs), err)
+	}
+}
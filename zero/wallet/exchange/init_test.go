@@ -0,0 +1,153 @@
+package exchange
+
+import (
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/sero-cash/go-czero-import/c_type"
+	"github.com/sero-cash/go-czero-import/cpt"
+	"github.com/sero-cash/go-czero-import/superzk"
+	"github.com/sero-cash/go-sero/accounts"
+	"github.com/sero-cash/go-sero/common/hexutil"
+	"github.com/sero-cash/go-sero/consensus/ethash"
+	"github.com/sero-cash/go-sero/core"
+	"github.com/sero-cash/go-sero/core/types"
+	"github.com/sero-cash/go-sero/params"
+	"github.com/sero-cash/go-sero/serodb"
+	"github.com/sero-cash/go-sero/zero/localdb"
+	"github.com/sero-cash/go-sero/zero/txs/assets"
+	"github.com/sero-cash/go-sero/zero/txs/stx/stx_v1"
+	"github.com/sero-cash/go-sero/zero/txtool"
+	"github.com/sero-cash/go-sero/zero/utils"
+)
+
+// testEnv is a chain of blocks generated on a memory database.
+type testEnv struct {
+	db      *serodb.MemDatabase
+	genesis *types.Block
+	blocks  []*types.Block
+	chain   *testChain
+}
+
+// newTestEnv generates n blocks after the genesis, its chain serves all of
+// them.
+func newTestEnv(n int) *testEnv {
+	cpt.ZeroInit(cpt.NET_Alpha)
+	db := serodb.NewMemDatabase()
+	genesis := (&core.Genesis{Config: params.TestChainConfig}).MustCommit(db)
+	blocks, _ := core.GenerateChain(params.TestChainConfig, genesis, ethash.NewFaker(), db, n, nil)
+	return &testEnv{db, genesis, blocks, &testChain{blocks: append([]*types.Block{genesis}, blocks...)}}
+}
+
+// fork generates n blocks on parent that differ from the blocks of the env.
+func (self *testEnv) fork(parent *types.Block, n int) []*types.Block {
+	blocks, _ := core.GenerateChain(params.TestChainConfig, parent, ethash.NewFaker(), self.db, n, func(i int, b *core.BlockGen) {
+		b.SetExtra([]byte("fork"))
+	})
+	return blocks
+}
+
+// testChain serves the headers of the canonical blocks.
+type testChain struct {
+	blocks []*types.Block
+}
+
+func (self *testChain) GetCurrenHeader() *types.Header {
+	return self.blocks[len(self.blocks)-1].Header()
+}
+
+func (self *testChain) GetHeaderByNumber(number uint64) *types.Header {
+	if block := self.GetBlockByNumber(number); block != nil {
+		return block.Header()
+	}
+	return nil
+}
+
+func (self *testChain) GetBlockByNumber(number uint64) *types.Block {
+	for _, block := range self.blocks {
+		if block.NumberU64() == number {
+			return block
+		}
+	}
+	return nil
+}
+
+func newTestAccount(seed byte) *Account {
+	s := c_type.Uint256{seed}
+	sk := superzk.Seed2Sk(&s, 1)
+	tk, _ := superzk.Sk2Tk(&sk)
+	pk, _ := superzk.Tk2Pk(&tk)
+	r := c_type.Uint256{seed}
+	pkr := superzk.Pk2PKr(&pk, &r)
+	account := &Account{pk: &pk, tk: &tk, balancePkr: &pkr, isChanged: true}
+	copy(account.skr[:], tk[:])
+	return account
+}
+
+func newTestExchange(t *testing.T, chain HeaderReader, account Account) (*Exchange, func()) {
+	dir, err := ioutil.TempDir("", "exchange")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := serodb.NewLDBDatabase(dir, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exchange := &Exchange{db: db, chain: chain}
+	exchange.accounts.Store(*account.pk, &account)
+	exchange.numbers.Store(*account.pk, uint64(1))
+	return exchange, func() {
+		db.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+// testBlocks builds the block infos of chain for the account, block i pays
+// values[i] and spends the outs of the heights in spends.
+func testBlocks(account *Account, chain []*types.Block, values []int64, spends map[uint64]txtool.Out, outs map[uint64]txtool.Out) (blocks []txtool.Block) {
+	for i, block := range chain {
+		num := block.NumberU64()
+		hash := block.Hash().HashToUint256()
+		info := txtool.Block{Num: hexutil.Uint64(num), Hash: *hash}
+
+		root := *hash
+		rootCM := *hash
+		out := txtool.Out{Root: root, State: localdb.RootState{
+			OS: localdb.OutState{
+				Out_P: &stx_v1.Out_P{
+					PKr: *account.balancePkr,
+					Asset: assets.Asset{Tkn: &assets.Token{
+						Currency: utils.CurrencyToUint256("SERO"),
+						Value:    utils.U256(*big.NewInt(values[i])),
+					}},
+				},
+				RootCM: &rootCM,
+			},
+			TxHash: root,
+			Num:    num,
+		}}
+		info.Outs = append(info.Outs, out)
+		outs[num] = out
+
+		if spent, ok := spends[num]; ok {
+			info.Nils = append(info.Nils, DecOuts([]txtool.Out{spent}, &account.skr)[0].Nil)
+		}
+		blocks = append(blocks, info)
+	}
+	return
+}
+
+func balanceOf(exchange *Exchange, account *Account) int64 {
+	balances, _ := exchange.GetBalances(*account.pk)
+	if amount, ok := balances["SERO"]; ok {
+		return amount.Int64()
+	}
+	return 0
+}
+
+// testWallet lets an account sign, batchSign stands in for the signing.
+type testWallet struct {
+	accounts.Wallet
+}
@@ -0,0 +1,351 @@
+package exchange
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/btcsuite/btcutil/base58"
+	"github.com/sero-cash/go-czero-import/c_type"
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/common/address"
+	"github.com/sero-cash/go-sero/rlp"
+)
+
+// MergeRule holds the thresholds of one currency for the auto merge.
+type MergeRule struct {
+	// MinUtxos merges once the account holds that many utxos.
+	MinUtxos int
+	// DustValue marks the utxos worth less as dust, MinDust of them trigger
+	// a merge as well.
+	DustValue *big.Int
+	MinDust   int
+	// Interval merges the utxos above Keep after that long without a merge,
+	// zero disables it.
+	Interval time.Duration
+	// Keep utxos stay unmerged, MaxInputs caps the utxos of a merge tx.
+	Keep      int
+	MaxInputs int
+}
+
+// MergePolicy controls the auto merge of the exchange accounts.
+type MergePolicy struct {
+	// Rules maps a currency to its thresholds, only those currencies merge.
+	Rules map[string]MergeRule
+	// MaxDailyFee caps the SERO the merges of a day spend on fees, nil is
+	// unlimited.
+	MaxDailyFee *big.Int
+	// QuietHours are windows of the local time like "22:00-06:00" in which
+	// the auto merge does not run.
+	QuietHours []string
+	// Targets maps the base58 pk of an account to the base58 PKr its merges
+	// pay to, the main PKr of the account by default.
+	Targets map[string]string
+}
+
+// mergeCheckInterval is the period of the auto merge job.
+const mergeCheckInterval = 5 * time.Minute
+
+var defaultMergeRule = MergeRule{
+	MinUtxos:  100,
+	Interval:  6 * time.Hour,
+	Keep:      50,
+	MaxInputs: 100,
+}
+
+// quietWindow spans the minutes of the day [from, to), wrapping at midnight
+// when to is not above from.
+type quietWindow struct {
+	from, to int
+}
+
+func (self quietWindow) contains(t time.Time) bool {
+	minute := t.Hour()*60 + t.Minute()
+	if self.from < self.to {
+		return minute >= self.from && minute < self.to
+	}
+	return minute >= self.from || minute < self.to
+}
+
+func parseClock(s string) (minute int, err error) {
+	var hour, min int
+	if _, err = fmt.Sscanf(s, "%d:%d", &hour, &min); err != nil {
+		return
+	}
+	if hour < 0 || hour > 24 || min < 0 || min > 59 || hour*60+min > 24*60 {
+		err = fmt.Errorf("invalid time %q", s)
+		return
+	}
+	return hour*60 + min, nil
+}
+
+func (self *MergePolicy) parse() (windows []quietWindow, targets map[c_type.Uint512]c_type.PKr, err error) {
+	for currency, rule := range self.Rules {
+		if currency == "" || strings.ToUpper(currency) != currency {
+			return nil, nil, fmt.Errorf("merge currency %q must be upper case", currency)
+		}
+		if rule.MaxInputs > 400 {
+			return nil, nil, fmt.Errorf("merge inputs of %v must <= 400", currency)
+		}
+	}
+	for _, quiet := range self.QuietHours {
+		clocks := strings.Split(quiet, "-")
+		if len(clocks) != 2 {
+			return nil, nil, fmt.Errorf("invalid quiet hours %q", quiet)
+		}
+		var window quietWindow
+		if window.from, err = parseClock(strings.TrimSpace(clocks[0])); err != nil {
+			return
+		}
+		if window.to, err = parseClock(strings.TrimSpace(clocks[1])); err != nil {
+			return
+		}
+		windows = append(windows, window)
+	}
+	targets = map[c_type.Uint512]c_type.PKr{}
+	for pkStr, pkrStr := range self.Targets {
+		var pk address.PKAddress
+		if err = pk.UnmarshalText([]byte(pkStr)); err != nil {
+			return
+		}
+		out := base58.Decode(pkrStr)
+		if err = address.ValidPkr(out); err != nil {
+			return nil, nil, fmt.Errorf("merge target of %v: %v", pkStr, err)
+		}
+		var pkr c_type.PKr
+		copy(pkr[:], out)
+		targets[pk.ToUint512()] = pkr
+	}
+	return
+}
+
+// Validate checks the quiet hours, the targets and the rules.
+func (self *MergePolicy) Validate() error {
+	_, _, err := self.parse()
+	return err
+}
+
+// MergeStatus reports the auto merge of one currency of an account, the
+// times are unix seconds.
+type MergeStatus struct {
+	Pk        address.PKAddress
+	Currency  string
+	LastCheck uint64
+	LastMerge uint64
+	LastTx    *c_type.Uint256 `json:",omitempty"`
+	LastCount int
+	NextMerge uint64
+	Skipped   string `json:",omitempty"`
+}
+
+type mergeKey struct {
+	pk       c_type.Uint512
+	currency string
+}
+
+type mergeFees struct {
+	Day uint64
+	Fee *big.Int
+}
+
+var mergeFeeKey = []byte("MERGEFEE")
+
+func mergeDay(t time.Time) uint64 {
+	return uint64(t.Year())*1000 + uint64(t.YearDay())
+}
+
+func (self *Exchange) mergeRule(currency string) MergeRule {
+	rule, ok := self.config.MergePolicy.Rules[currency]
+	if !ok {
+		rule = defaultMergeRule
+	}
+	if rule.Keep < 1 {
+		rule.Keep = 1
+	}
+	if rule.MaxInputs <= 0 {
+		rule.MaxInputs = defaultMergeRule.MaxInputs
+	}
+	return rule
+}
+
+func (self *Exchange) mergeCurrencies() (currencies []string) {
+	for currency := range self.config.MergePolicy.Rules {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+	return
+}
+
+func (self *Exchange) mergeTarget(account *Account) c_type.PKr {
+	if pkr, ok := self.mergeTargets[*account.pk]; ok {
+		return pkr
+	}
+	return account.mainPkr
+}
+
+func (self *Exchange) inQuietHours(t time.Time) bool {
+	for _, window := range self.quietHours {
+		if window.contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+func (self *Exchange) getMergeFees(t time.Time) (fees mergeFees) {
+	if value, err := self.db.Get(mergeFeeKey); err == nil {
+		rlp.DecodeBytes(value, &fees)
+	}
+	if fees.Day != mergeDay(t) || fees.Fee == nil {
+		fees = mergeFees{Day: mergeDay(t), Fee: new(big.Int)}
+	}
+	return
+}
+
+// mergeFeeAllowed tells whether fee stays within the daily merge budget.
+func (self *Exchange) mergeFeeAllowed(t time.Time, fee *big.Int) bool {
+	if self.config.MergePolicy.MaxDailyFee == nil {
+		return true
+	}
+	fees := self.getMergeFees(t)
+	return new(big.Int).Add(fees.Fee, fee).Cmp(self.config.MergePolicy.MaxDailyFee) <= 0
+}
+
+// countMergeUtxos counts the free utxos of the currency and the dust among
+// them, it stops once a threshold is reached.
+func (self *Exchange) countMergeUtxos(pk *c_type.Uint512, currency string, rule *MergeRule) (count, dust int) {
+	prefix := utxoPkKey(*pk, common.LeftPadBytes([]byte(currency), 32), nil)
+	iterator := self.db.NewIteratorWithPrefix(prefix)
+	defer iterator.Release()
+	for iterator.Next() {
+		key := iterator.Key()
+		var root c_type.Uint256
+		copy(root[:], key[98:130])
+		utxo, err := self.getUtxo(root)
+		if err != nil || utxo.Ignore {
+			continue
+		}
+		if _, ok := self.usedFlag.Load(utxo.Root); ok {
+			continue
+		}
+		count++
+		if rule.DustValue != nil && utxo.Asset.Tkn != nil && utxo.Asset.Tkn.Value.ToIntRef().Cmp(rule.DustValue) < 0 {
+			dust++
+		}
+		if rule.MinUtxos > 0 && count >= rule.MinUtxos || rule.MinDust > 0 && dust >= rule.MinDust {
+			break
+		}
+	}
+	return
+}
+
+// needMerge returns why the account should merge the currency now, empty
+// when it should not.
+func (self *Exchange) needMerge(account *Account, currency string, rule *MergeRule) (reason string, count int) {
+	count, dust := self.countMergeUtxos(account.pk, currency, rule)
+	switch {
+	case rule.MinUtxos > 0 && count >= rule.MinUtxos:
+		reason = "utxo count"
+	case rule.DustValue != nil && rule.MinDust > 0 && dust >= rule.MinDust:
+		reason = "dust"
+	case rule.Interval > 0 && count > rule.Keep && time.Now().After(account.nextMergeTime):
+		reason = "interval"
+	}
+	return
+}
+
+func (self *Exchange) mergeStatusOf(pk c_type.Uint512, currency string) *MergeStatus {
+	key := mergeKey{pk, currency}
+	status, ok := self.mergeStatus[key]
+	if !ok {
+		status = &MergeStatus{Pk: address.NewPKAddres(pk[:]), Currency: currency}
+		self.mergeStatus[key] = status
+	}
+	return status
+}
+
+// mergeDone records a committed merge tx and its fee.
+func (self *Exchange) mergeDone(pk c_type.Uint512, currency string, count int, txhash c_type.Uint256, fee *big.Int) {
+	self.mergeLock.Lock()
+	defer self.mergeLock.Unlock()
+	now := time.Now()
+	status := self.mergeStatusOf(pk, currency)
+	status.LastMerge = uint64(now.Unix())
+	status.LastTx = &txhash
+	status.LastCount = count
+	status.Skipped = ""
+
+	fees := self.getMergeFees(now)
+	fees.Fee.Add(fees.Fee, fee)
+	if data, err := rlp.EncodeToBytes(&fees); err == nil {
+		self.db.Put(mergeFeeKey, data)
+	}
+}
+
+func (self *Exchange) mergeSkipped(pk c_type.Uint512, currency string, reason string) {
+	self.mergeLock.Lock()
+	defer self.mergeLock.Unlock()
+	status := self.mergeStatusOf(pk, currency)
+	status.LastCheck = uint64(time.Now().Unix())
+	status.Skipped = reason
+}
+
+// GetMergeStatus reports the merges of the pk, or of every account when pk
+// is nil, for each currency of the policy.
+func (self *Exchange) GetMergeStatus(pk *c_type.Uint512) (statuses []MergeStatus, e error) {
+	var pks []c_type.Uint512
+	if pk != nil {
+		if self.getAccountByPk(*pk) == nil {
+			e = errors.New("not found Pk")
+			return
+		}
+		pks = append(pks, *pk)
+	} else {
+		pks = self.Pks()
+		sort.Slice(pks, func(i, j int) bool {
+			return string(pks[i][:]) < string(pks[j][:])
+		})
+	}
+
+	now := time.Now()
+	for _, pk := range pks {
+		account := self.getAccountByPk(pk)
+		for _, currency := range self.mergeCurrencies() {
+			var next uint64
+			if self.autoMerging && account != nil {
+				next = self.nextMerge(account, currency, now)
+			}
+			self.mergeLock.Lock()
+			status := *self.mergeStatusOf(pk, currency)
+			self.mergeLock.Unlock()
+			status.NextMerge = next
+			statuses = append(statuses, status)
+		}
+	}
+	return
+}
+
+// nextMerge returns the unix time of the merge job that will merge the
+// currency of the account, zero when no threshold is going to be reached.
+func (self *Exchange) nextMerge(account *Account, currency string, now time.Time) uint64 {
+	rule := self.mergeRule(currency)
+	var due time.Time
+	if reason, count := self.needMerge(account, currency, &rule); reason != "" {
+		due = now
+	} else if rule.Interval > 0 && count > rule.Keep {
+		due = account.nextMergeTime
+	} else {
+		return 0
+	}
+	tick := due.Truncate(mergeCheckInterval)
+	if tick.Before(due) || !tick.After(now) {
+		tick = tick.Add(mergeCheckInterval)
+	}
+	for i := 0; i < int(24*time.Hour/mergeCheckInterval) && self.inQuietHours(tick); i++ {
+		tick = tick.Add(mergeCheckInterval)
+	}
+	return uint64(tick.Unix())
+}
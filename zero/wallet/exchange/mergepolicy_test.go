@@ -0,0 +1,100 @@
+package exchange
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/sero-cash/go-czero-import/c_type"
+	"github.com/sero-cash/go-sero/zero/txtool"
+)
+
+func TestMergePolicyParse(t *testing.T) {
+	policy := MergePolicy{QuietHours: []string{"22:00-06:30", "12:00-13:00"}}
+	windows, _, err := policy.parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for clock, quiet := range map[string]bool{"23:10": true, "06:29": true, "06:30": false, "12:30": true, "13:00": false} {
+		at, _ := time.Parse("15:04", clock)
+		if (windows[0].contains(at) || windows[1].contains(at)) != quiet {
+			t.Fatalf("quiet at %v is %v, want %v", clock, !quiet, quiet)
+		}
+	}
+	for _, policy := range []MergePolicy{
+		{QuietHours: []string{"22:00"}},
+		{QuietHours: []string{"25:00-01:00"}},
+		{Rules: map[string]MergeRule{"sero": {}}},
+		{Targets: map[string]string{"x": "y"}},
+	} {
+		if policy.Validate() == nil {
+			t.Fatalf("invalid policy %+v accepted", policy)
+		}
+	}
+}
+
+func TestMergeThresholds(t *testing.T) {
+	env := newTestEnv(5)
+	account := newTestAccount(1)
+	exchange, closer := newTestExchange(t, nil, *account)
+	defer closer()
+	exchange.indexUtxo(testBlocks(account, env.blocks, []int64{1, 2, 300, 400, 500}, nil, map[uint64]txtool.Out{}), []c_type.Uint512{*account.pk})
+	account.nextMergeTime = time.Now().Add(time.Hour)
+
+	for _, test := range []struct {
+		rule   MergeRule
+		reason string
+	}{
+		{MergeRule{MinUtxos: 5}, "utxo count"},
+		{MergeRule{MinUtxos: 6}, ""},
+		{MergeRule{MinUtxos: 6, DustValue: big.NewInt(10), MinDust: 2}, "dust"},
+		{MergeRule{MinUtxos: 6, DustValue: big.NewInt(2), MinDust: 2}, ""},
+		{MergeRule{MinUtxos: 6, Interval: time.Hour, Keep: 2}, ""},
+	} {
+		if reason, _ := exchange.needMerge(account, "SERO", &test.rule); reason != test.reason {
+			t.Fatalf("rule %+v merges for %q, want %q", test.rule, reason, test.reason)
+		}
+	}
+	account.nextMergeTime = time.Now().Add(-time.Second)
+	if reason, _ := exchange.needMerge(account, "SERO", &MergeRule{Interval: time.Hour, Keep: 2}); reason != "interval" {
+		t.Fatalf("interval rule merges for %q", reason)
+	}
+
+	exchange.mergeStatus = map[mergeKey]*MergeStatus{}
+	exchange.config.MergePolicy = MergePolicy{
+		Rules:       map[string]MergeRule{"SERO": {}},
+		MaxDailyFee: new(big.Int).Mul(default_fee_value, big.NewInt(2)),
+	}
+	now := time.Now()
+	for i := 0; i < 2; i++ {
+		if !exchange.mergeFeeAllowed(now, default_fee_value) {
+			t.Fatalf("merge %v denied", i)
+		}
+		exchange.mergeDone(*account.pk, "SERO", 3, c_type.Uint256{byte(i)}, default_fee_value)
+	}
+	if exchange.mergeFeeAllowed(now, default_fee_value) {
+		t.Fatal("merge above the daily fee budget allowed")
+	}
+	if !exchange.mergeFeeAllowed(now.Add(24*time.Hour), default_fee_value) {
+		t.Fatal("budget of the next day spent")
+	}
+	statuses, err := exchange.GetMergeStatus(account.pk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != 1 || statuses[0].LastCount != 3 || statuses[0].LastTx == nil || statuses[0].LastTx[0] != 1 {
+		t.Fatalf("unexpected status %+v", statuses)
+	}
+
+	exchange.autoMerging = true
+	exchange.config.MergePolicy.Rules = map[string]MergeRule{"SERO": {Interval: time.Hour, Keep: 2}}
+	due := now.Add(time.Hour)
+	exchange.getAccountByPk(*account.pk).nextMergeTime = due
+	if statuses, _ = exchange.GetMergeStatus(account.pk); statuses[0].NextMerge < uint64(due.Unix()) || statuses[0].NextMerge > uint64(due.Add(mergeCheckInterval).Unix()) {
+		t.Fatalf("next merge at %v, want the tick after %v", statuses[0].NextMerge, due.Unix())
+	}
+	exchange.config.MergePolicy.Rules = map[string]MergeRule{"SERO": {Interval: time.Hour, Keep: 10}}
+	if statuses, _ = exchange.GetMergeStatus(account.pk); statuses[0].NextMerge != 0 {
+		t.Fatalf("next merge at %v below the thresholds", statuses[0].NextMerge)
+	}
+}
@@ -0,0 +1,213 @@
+package exchange
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/sero-cash/go-czero-import/c_type"
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/core/rawdb"
+	"github.com/sero-cash/go-sero/core/types"
+	"github.com/sero-cash/go-sero/log"
+	"github.com/sero-cash/go-sero/rlp"
+	"github.com/sero-cash/go-sero/zero/txs/stx"
+	"github.com/sero-cash/go-sero/zero/txtool"
+)
+
+const (
+	PendingTxPending  = "pending"
+	PendingTxIncluded = "included"
+	PendingTxInvalid  = "invalid"
+	PendingTxExpired  = "expired"
+)
+
+// pendingKeepTime is how long the invalid and expired txs stay queryable.
+var pendingKeepTime = 24 * time.Hour
+
+var pendingTxPrefix = []byte("PENDINGTX")
+
+// "PENDINGTX" + txHash => PendingTx
+func pendingTxKey(hash c_type.Uint256) []byte {
+	return append(pendingTxPrefix, hash[:]...)
+}
+
+// PendingTx is a tx committed by the exchange that is not indexed yet. Its
+// roots keep their used flags until the tx is indexed, invalid or expired.
+type PendingTx struct {
+	Hash         c_type.Uint256
+	From         c_type.Uint512
+	Roots        []c_type.Uint256
+	Gas          uint64
+	GasPrice     *big.Int
+	Tx           stx.T `json:"-"`
+	Status       string
+	Reason       string
+	Block        uint64
+	CreatedAt    uint64
+	UpdatedAt    uint64
+	Rebroadcasts uint64
+}
+
+func (self *PendingTx) toTransaction() *types.Transaction {
+	return types.NewTxWithGTx(self.Gas, self.GasPrice, &self.Tx)
+}
+
+func (self *Exchange) putPendingTx(ptx *PendingTx) error {
+	data, err := rlp.EncodeToBytes(ptx)
+	if err != nil {
+		return err
+	}
+	return self.db.Put(pendingTxKey(ptx.Hash), data)
+}
+
+// TrackTx remembers a committed tx so that it is rebroadcast while pending
+// and its used flags are released once it can not be mined anymore.
+func (self *Exchange) TrackTx(tx *txtool.GTx, roots []c_type.Uint256) error {
+	if self == nil {
+		return nil
+	}
+	owned := []c_type.Uint256{}
+	for _, root := range roots {
+		if _, err := self.db.Get(rootKey(root)); err == nil {
+			owned = append(owned, root)
+		}
+	}
+	if len(owned) == 0 {
+		return nil
+	}
+	roots = owned
+	gasPrice := big.Int(tx.GasPrice)
+	now := uint64(time.Now().Unix())
+	ptx := PendingTx{
+		Roots:     roots,
+		Gas:       uint64(tx.Gas),
+		GasPrice:  &gasPrice,
+		Tx:        tx.Tx,
+		Status:    PendingTxPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	ptx.Hash = *ptx.toTransaction().Hash().HashToUint256()
+	if value, _ := self.db.Get(nilKey(roots[0])); len(value) >= 66 {
+		copy(ptx.From[:], value[2:66])
+	}
+	for _, root := range roots {
+		self.usedFlag.Store(root, 1)
+	}
+	return self.putPendingTx(&ptx)
+}
+
+func (self *Exchange) GetPendingTxs(pk *c_type.Uint512) (ptxs []PendingTx) {
+	iterator := self.db.NewIteratorWithPrefix(pendingTxPrefix)
+	defer iterator.Release()
+	for iterator.Next() {
+		var ptx PendingTx
+		if err := rlp.DecodeBytes(iterator.Value(), &ptx); err != nil {
+			log.Error("Exchange invalid pending tx", "key", common.Bytes2Hex(iterator.Key()), "error", err)
+			continue
+		}
+		if pk == nil || ptx.From == *pk {
+			ptxs = append(ptxs, ptx)
+		}
+	}
+	return
+}
+
+// recoverUsedFlags flags the roots of the pending txs again after a restart.
+func (self *Exchange) recoverUsedFlags() {
+	count := 0
+	for _, ptx := range self.GetPendingTxs(nil) {
+		if ptx.Status == PendingTxPending || ptx.Status == PendingTxIncluded {
+			for _, root := range ptx.Roots {
+				self.usedFlag.Store(root, 1)
+				count++
+			}
+		}
+	}
+	if count > 0 {
+		log.Info("Exchange recovered used flags", "count", count)
+	}
+}
+
+func (self *Exchange) releasePendingTx(ptx *PendingTx, status string, reason string) {
+	for _, root := range ptx.Roots {
+		self.usedFlag.Delete(root)
+	}
+	ptx.Status = status
+	ptx.Reason = reason
+	log.Info("Exchange released pending tx", "txhash", common.Bytes2Hex(ptx.Hash[:]), "status", status, "reason", reason)
+}
+
+// expirePendingTx drops the tx from the pool before it releases its roots,
+// so that the pool does not mine it next to the txs reusing them.
+func (self *Exchange) expirePendingTx(ptx *PendingTx) {
+	if self.txPool != nil {
+		self.txPool.RemoveTxs(types.Transactions{ptx.toTransaction()})
+	}
+	self.releasePendingTx(ptx, PendingTxExpired, "not mined in "+self.config.PendingTimeout.String())
+}
+
+func (self *Exchange) spentRoot(root c_type.Uint256) bool {
+	if _, err := self.db.Get(rootKey(root)); err != nil {
+		return false
+	}
+	_, err := self.db.Get(nilKey(root))
+	return err != nil
+}
+
+func (self *Exchange) checkPendingTxs() {
+	if txtool.Ref_inst.Bc == nil || !txtool.Ref_inst.Bc.IsValid() {
+		return
+	}
+	now := uint64(time.Now().Unix())
+	for _, ptx := range self.GetPendingTxs(nil) {
+		ptx := ptx
+		hash := common.BytesToHash(ptx.Hash[:])
+		switch ptx.Status {
+		case PendingTxPending, PendingTxIncluded:
+			if _, num, _ := rawdb.ReadTxLookupEntry(txtool.Ref_inst.Bc.GetDB(), hash); num > 0 {
+				if num <= self.GetCurrencyNumber(ptx.From) {
+					// indexed, the used flags went away with the spent roots
+					self.db.Delete(pendingTxKey(ptx.Hash))
+					continue
+				}
+				ptx.Status = PendingTxIncluded
+				ptx.Block = num
+				break
+			}
+			ptx.Status = PendingTxPending
+			invalid := false
+			for _, root := range ptx.Roots {
+				if self.spentRoot(root) {
+					self.releasePendingTx(&ptx, PendingTxInvalid, "input "+common.Bytes2Hex(root[:])+" spent by another tx")
+					invalid = true
+					break
+				}
+			}
+			if invalid {
+				break
+			}
+			if now > ptx.CreatedAt+uint64(self.config.PendingTimeout/time.Second) {
+				self.expirePendingTx(&ptx)
+				break
+			}
+			if self.txPool != nil && self.txPool.Get(hash) == nil {
+				if err := self.txPool.AddLocal(ptx.toTransaction()); err != nil {
+					log.Warn("Exchange rebroadcast", "txhash", hash.String(), "error", err)
+				} else {
+					ptx.Rebroadcasts++
+					log.Info("Exchange rebroadcast", "txhash", hash.String(), "times", ptx.Rebroadcasts)
+				}
+			}
+		default:
+			if now > ptx.UpdatedAt+uint64(pendingKeepTime/time.Second) {
+				self.db.Delete(pendingTxKey(ptx.Hash))
+			}
+			continue
+		}
+		ptx.UpdatedAt = now
+		if err := self.putPendingTx(&ptx); err != nil {
+			log.Error("Exchange update pending tx", "txhash", hash.String(), "error", err)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package exchange
+
+import (
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sero-cash/go-czero-import/c_type"
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/common/hexutil"
+	"github.com/sero-cash/go-sero/core/types"
+	"github.com/sero-cash/go-sero/zero/txtool"
+)
+
+func TestTrackTxRecovery(t *testing.T) {
+	env := newTestEnv(4)
+
+	account := newTestAccount(1)
+	exchange, closer := newTestExchange(t, env.chain, *account)
+	defer closer()
+	pks := []c_type.Uint512{*account.pk}
+
+	outs := map[uint64]txtool.Out{}
+	exchange.indexUtxo(testBlocks(account, env.blocks[:3], []int64{1, 2, 3}, nil, outs), pks)
+
+	tx := &txtool.GTx{Gas: hexutil.Uint64(25000), GasPrice: hexutil.Big(*big.NewInt(1000000000))}
+	if err := exchange.TrackTx(tx, []c_type.Uint256{outs[1].Root, outs[2].Root, {0xff}}); err != nil {
+		t.Fatal(err)
+	}
+	ptxs := exchange.GetPendingTxs(account.pk)
+	if len(ptxs) != 1 || len(ptxs[0].Roots) != 2 || ptxs[0].Status != PendingTxPending {
+		t.Fatalf("got pending txs %v, want one pending tx with 2 roots", ptxs)
+	}
+
+	// A restart loses the used flags in memory.
+	exchange.usedFlag = sync.Map{}
+	exchange.recoverUsedFlags()
+	for _, num := range []uint64{1, 2} {
+		if _, ok := exchange.usedFlag.Load(outs[num].Root); !ok {
+			t.Fatalf("used flag of block %v not recovered", num)
+		}
+	}
+
+	if exchange.spentRoot(outs[2].Root) {
+		t.Fatal("unspent root reported as spent")
+	}
+	exchange.indexUtxo(testBlocks(account, env.blocks[3:], []int64{4}, map[uint64]txtool.Out{4: outs[2]}, outs), pks)
+	if !exchange.spentRoot(outs[2].Root) {
+		t.Fatal("root spent by another tx not detected")
+	}
+	exchange.releasePendingTx(&ptxs[0], PendingTxInvalid, "spent")
+	if _, ok := exchange.usedFlag.Load(outs[1].Root); ok {
+		t.Fatal("used flag kept after release")
+	}
+}
+
+// testPool keeps the txs the exchange adds and removes.
+type testPool struct {
+	txs map[common.Hash]*types.Transaction
+}
+
+func (self *testPool) AddLocal(tx *types.Transaction) error {
+	self.txs[tx.Hash()] = tx
+	return nil
+}
+
+func (self *testPool) Get(hash common.Hash) *types.Transaction {
+	return self.txs[hash]
+}
+
+func (self *testPool) RemoveTxs(txs types.Transactions) {
+	for _, tx := range txs {
+		delete(self.txs, tx.Hash())
+	}
+}
+
+func TestPendingTxExpiry(t *testing.T) {
+	env := newTestEnv(2)
+
+	account := newTestAccount(1)
+	exchange, closer := newTestExchange(t, env.chain, *account)
+	defer closer()
+	pool := &testPool{txs: map[common.Hash]*types.Transaction{}}
+	exchange.txPool = pool
+	exchange.config.PendingTimeout = time.Minute
+
+	outs := map[uint64]txtool.Out{}
+	exchange.indexUtxo(testBlocks(account, env.blocks, []int64{1, 2}, nil, outs), []c_type.Uint512{*account.pk})
+	tx := &txtool.GTx{Gas: hexutil.Uint64(25000), GasPrice: hexutil.Big(*big.NewInt(1000000000))}
+	if err := exchange.TrackTx(tx, []c_type.Uint256{outs[1].Root}); err != nil {
+		t.Fatal(err)
+	}
+	ptx := exchange.GetPendingTxs(account.pk)[0]
+	pool.AddLocal(ptx.toTransaction())
+
+	exchange.expirePendingTx(&ptx)
+	if ptx.Status != PendingTxExpired {
+		t.Fatal("status", ptx.Status)
+	}
+	if pool.Get(ptx.toTransaction().Hash()) != nil {
+		t.Fatal("expired tx left in the pool")
+	}
+	if _, ok := exchange.usedFlag.Load(outs[1].Root); ok {
+		t.Fatal("used flag kept after expiry")
+	}
+}
@@ -2,18 +2,51 @@ package exchange
 
 import (
 	"github.com/sero-cash/go-czero-import/c_type"
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/common/address"
 	"github.com/sero-cash/go-sero/log"
 	"github.com/sero-cash/go-sero/rlp"
 	"github.com/sero-cash/go-sero/serodb"
 	"github.com/sero-cash/go-sero/zero/localdb"
 	"github.com/sero-cash/go-sero/zero/txtool"
+	"github.com/sero-cash/go-sero/zero/wallet/reorg"
 )
 
 var (
 	pk_from_id_2_id_KeyPrefix = []byte("PK_FROM_ID_2_ID")
 	id_2_pkg_KeyPrefix        = []byte("ID_2_PKG")
+	pkgIndexVersionKey        = []byte("VERSION_PKG_INDEX")
 )
 
+// migratePkgIndex drops, once, the sent pkgs of an index written before
+// version 1. It put them under the pk of the receiver, which cannot be told
+// apart from a pkg the receiver sent itself, so the accounts that sent pkgs
+// need a rescan to index them again.
+func (self *Exchange) migratePkgIndex() {
+	if has, _ := self.db.Has(pkgIndexVersionKey); has {
+		return
+	}
+	batch := self.db.NewBatch()
+	count := 0
+	iterator := self.db.NewIteratorWithPrefix(pk_from_id_2_id_KeyPrefix)
+	for iterator.Next() {
+		key := iterator.Key()
+		if len(key) == len(pk_from_id_2_id_KeyPrefix)+64+1+32 && key[len(pk_from_id_2_id_KeyPrefix)+64] == 1 {
+			batch.Delete(common.CopyBytes(key))
+			count++
+		}
+	}
+	iterator.Release()
+	batch.Put(pkgIndexVersionKey, []byte{1})
+	if e := batch.Write(); e != nil {
+		log.Error("Exchange migrate pkg index", "error", e)
+		return
+	}
+	if count > 0 {
+		log.Warn("Exchange dropped the sent pkgs of the old index, rescan the accounts that sent pkgs", "pkgs", count)
+	}
+}
+
 func pk_from_id_2_id_Key(pk *c_type.Uint512, from *bool, id *c_type.Uint256) []byte {
 	ret := append(pk_from_id_2_id_KeyPrefix, pk[:]...)
 	if from != nil {
@@ -77,19 +110,45 @@ type pkgIndexes struct {
 	pk_from_id_maps map[string]c_type.Uint256
 }
 
-func (self *Exchange) indexPkgs(pks []c_type.Uint512, batch serodb.Batch, blocks []txtool.Block) {
+// pkgJournal writes the pkg index into a batch and remembers, for every
+// block, the values it overwrote so that a reorg can restore them.
+type pkgJournal struct {
+	*reorg.Journal
+}
+
+func (self pkgJournal) findPkgById(id *c_type.Uint256) (pkg *Pkg) {
+	if bs, ok := self.Get(id_2_pkg_key(id)); ok {
+		pkg = &Pkg{}
+		if e := rlp.DecodeBytes(bs, pkg); e != nil {
+			panic(e)
+		}
+	}
+	return
+}
+
+// commit stores the undo records of the block num, if there are any.
+func (self pkgJournal) commit(num uint64) {
+	if e := self.Commit(undoPkgKey(num)); e != nil {
+		panic(e)
+	}
+}
+
+func (self *Exchange) indexPkgs(pks []c_type.Uint512, batch serodb.Batch, blocks []txtool.Block) (events []Event) {
+	journal := pkgJournal{reorg.NewJournal(self.db, batch)}
 	for _, block := range blocks {
 		for _, pkg := range block.Pkgs {
-			if p := self.FindPkgById(&pkg.Pack.Id); p != nil {
+			received := true
+			if p := journal.findPkgById(&pkg.Pack.Id); p != nil {
+				received = false
 				if p.to != nil {
 					from := false
-					batch.Delete(pk_from_id_2_id_Key(p.to, &from, &p.z.Pack.Id))
+					journal.Delete(pk_from_id_2_id_Key(p.to, &from, &p.z.Pack.Id))
 				}
 				if p.from != nil {
 					from := true
-					batch.Delete(pk_from_id_2_id_Key(p.to, &from, &p.z.Pack.Id))
+					journal.Delete(pk_from_id_2_id_Key(p.from, &from, &p.z.Pack.Id))
 				}
-				batch.Delete(id_2_pkg_key(&p.z.Pack.Id))
+				journal.Delete(id_2_pkg_key(&pkg.Pack.Id))
 			}
 			var p Pkg
 			if account, ok := self.ownPkr(pks, pkg.Pack.PKr); ok {
@@ -104,18 +163,16 @@ func (self *Exchange) indexPkgs(pks []c_type.Uint512, batch serodb.Batch, blocks
 					if bs, e := rlp.EncodeToBytes(&p); e == nil {
 						if p.to != nil {
 							from := false
-							if e := batch.Put(pk_from_id_2_id_Key(p.to, &from, &p.z.Pack.Id), p.z.Pack.Id[:]); e != nil {
-								panic(e)
-							}
+							journal.Put(pk_from_id_2_id_Key(p.to, &from, &p.z.Pack.Id), p.z.Pack.Id[:])
 						}
 						if p.from != nil {
 							from := true
-							if e := batch.Put(pk_from_id_2_id_Key(p.to, &from, &p.z.Pack.Id), p.z.Pack.Id[:]); e != nil {
-								panic(e)
-							}
+							journal.Put(pk_from_id_2_id_Key(p.from, &from, &p.z.Pack.Id), p.z.Pack.Id[:])
 						}
-						if e := batch.Put(id_2_pkg_key(&p.z.Pack.Id), bs); e != nil {
-							panic(e)
+						journal.Put(id_2_pkg_key(&p.z.Pack.Id), bs)
+						if received && p.to != nil {
+							pkAddr := address.PKAddress(*p.to)
+							events = append(events, Event{Type: EventPkgReceived, Num: uint64(block.Num), BlockHash: block.Hash, PK: &pkAddr, Pkr: address.MixBase58Adrress(common.CopyBytes(pkg.Pack.PKr[:])), PkgId: pkg.Pack.Id.NewRef()})
 						}
 					} else {
 						panic(e)
@@ -123,6 +180,7 @@ func (self *Exchange) indexPkgs(pks []c_type.Uint512, batch serodb.Batch, blocks
 				}
 			}
 		}
+		journal.commit(uint64(block.Num))
 	}
 	return
 }
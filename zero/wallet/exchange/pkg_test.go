@@ -0,0 +1,35 @@
+package exchange
+
+import (
+	"testing"
+
+	"github.com/sero-cash/go-czero-import/c_type"
+)
+
+func TestMigratePkgIndex(t *testing.T) {
+	env := newTestEnv(1)
+	sender, receiver := newTestAccount(1), newTestAccount(2)
+	exchange, closer := newTestExchange(t, env.chain, *sender)
+	defer closer()
+
+	// the old index put the pkg sent by sender under the receiver
+	id := c_type.Uint256{1}
+	from, to := true, false
+	exchange.db.Put(pk_from_id_2_id_Key(receiver.pk, &from, &id), id[:])
+	exchange.db.Put(pk_from_id_2_id_Key(receiver.pk, &to, &id), id[:])
+
+	exchange.migratePkgIndex()
+	if has, _ := exchange.db.Has(pk_from_id_2_id_Key(receiver.pk, &from, &id)); has {
+		t.Fatal("sent pkg kept under the receiver")
+	}
+	if has, _ := exchange.db.Has(pk_from_id_2_id_Key(receiver.pk, &to, &id)); !has {
+		t.Fatal("received pkg dropped")
+	}
+
+	// a pkg sent after the migration stays
+	exchange.db.Put(pk_from_id_2_id_Key(sender.pk, &from, &id), id[:])
+	exchange.migratePkgIndex()
+	if has, _ := exchange.db.Has(pk_from_id_2_id_Key(sender.pk, &from, &id)); !has {
+		t.Fatal("pkg index migrated twice")
+	}
+}
@@ -0,0 +1,361 @@
+package exchange
+
+import (
+	"bytes"
+
+	"github.com/sero-cash/go-czero-import/c_type"
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/core/types"
+	"github.com/sero-cash/go-sero/log"
+	"github.com/sero-cash/go-sero/rlp"
+	"github.com/sero-cash/go-sero/serodb"
+	"github.com/sero-cash/go-sero/zero/txtool"
+	"github.com/sero-cash/go-sero/zero/utils"
+	"github.com/sero-cash/go-sero/zero/wallet/reorg"
+)
+
+var (
+	hashPrefix    = []byte("HASH")
+	spentPrefix   = []byte("SPENT")
+	undoPkgPrefix = []byte("UNDOPKG")
+)
+
+// "HASH" + num => hash of the indexed block
+func hashKey(number uint64) []byte {
+	return append(hashPrefix, utils.EncodeNumber(number)...)
+}
+
+// "SPENT" + num + pk => [roots] spent by the pk at num
+func spentKey(number uint64, pk c_type.Uint512) []byte {
+	return append(spentPrefix, append(utils.EncodeNumber(number), pk[:]...)...)
+}
+
+// "UNDOPKG" + num => [reorg.Record] restoring the pkg index before num
+func undoPkgKey(number uint64) []byte {
+	return append(undoPkgPrefix, utils.EncodeNumber(number)...)
+}
+
+// HeaderReader is the part of txtool.BlockChain the index checks its blocks
+// against.
+type HeaderReader interface {
+	GetCurrenHeader() *types.Header
+	GetHeaderByNumber(number uint64) *types.Header
+}
+
+func (self *Exchange) headerReader() HeaderReader {
+	if self.chain != nil {
+		return self.chain
+	}
+	if txtool.Ref_inst.Bc != nil {
+		return txtool.Ref_inst.Bc
+	}
+	return nil
+}
+
+func (self *Exchange) indexedHash(num uint64) *c_type.Uint256 {
+	value, err := self.db.Get(hashKey(num))
+	if err != nil || len(value) != 32 {
+		return nil
+	}
+	hash := c_type.Uint256{}
+	copy(hash[:], value)
+	return &hash
+}
+
+func (self *Exchange) canonicalHash(num uint64) *c_type.Uint256 {
+	chain := self.headerReader()
+	if chain == nil {
+		return nil
+	}
+	if header := chain.GetHeaderByNumber(num); header != nil {
+		return header.Hash().HashToUint256()
+	}
+	return nil
+}
+
+func (self *Exchange) indexedTop() (top uint64) {
+	self.numbers.Range(func(key, value interface{}) bool {
+		if num := value.(uint64); num > top {
+			top = num
+		}
+		return true
+	})
+	if top > 0 {
+		top--
+	}
+	return
+}
+
+// checkReorg compares the hashes of the indexed blocks with the canonical
+// chain and rolls the index back to the last block both agree on. The blocks
+// indexed before the HASH, SPENT and UNDOPKG records were kept have no hash,
+// the search stops at them, so a reorg reaching below the upgrade is not
+// rolled back; the accounts need a rescan from below the fork instead.
+func (self *Exchange) checkReorg() (fork uint64, reorged bool) {
+	if self.headerReader() == nil {
+		return
+	}
+	top := self.indexedTop()
+	hashOf := func(lookup func(uint64) *c_type.Uint256) func(uint64) *common.Hash {
+		return func(num uint64) *common.Hash {
+			if hash := lookup(num); hash != nil {
+				ret := common.BytesToHash(hash[:])
+				return &ret
+			}
+			return nil
+		}
+	}
+	fork, reorged = reorg.FindFork(top, hashOf(self.indexedHash), hashOf(self.canonicalHash))
+	if reorged {
+		log.Warn("Exchange detected reorg", "top", top, "fork", fork)
+		if err := self.rollback(fork); err != nil {
+			log.Error("Exchange rollback", "fork", fork, "error", err)
+			reorged = false
+		}
+	}
+	return
+}
+
+// staleBlocks reports the first fetched block whose hash differs from the one
+// already indexed at the same height by another group of accounts.
+func (self *Exchange) staleBlocks(blocks []txtool.Block) (fork uint64, stale bool) {
+	for _, block := range blocks {
+		num := uint64(block.Num)
+		if hash := self.indexedHash(num); hash != nil && *hash != block.Hash {
+			return num - 1, true
+		}
+	}
+	return
+}
+
+func utxoPkKeys(pk c_type.Uint512, utxo *Utxo) (pkKeys []byte) {
+	if utxo.Asset.Tkn != nil {
+		pkKeys = append(pkKeys, utxoPkKey(pk, utxo.Asset.Tkn.Currency[:], &utxo.Root)...)
+	}
+	if utxo.Asset.Tkt != nil {
+		pkKeys = append(pkKeys, utxoPkKey(pk, utxo.Asset.Tkt.Value[:], &utxo.Root)...)
+	}
+	return
+}
+
+func iterateFrom(db *serodb.LDBDatabase, prefix []byte, num uint64, handler func(num uint64, key, value []byte)) {
+	iterator := db.NewIteratorWithPrefix(prefix)
+	defer iterator.Release()
+	for ok := iterator.Seek(append(append([]byte{}, prefix...), utils.EncodeNumber(num)...)); ok; ok = iterator.Next() {
+		key := iterator.Key()
+		if !bytes.HasPrefix(key, prefix) || len(key) < len(prefix)+8 {
+			break
+		}
+		handler(utils.DecodeNumber(key[len(prefix):len(prefix)+8]), common.CopyBytes(key), common.CopyBytes(iterator.Value()))
+	}
+}
+
+// undoUtxos undoes in batch the utxos created and spent from the block from on
+// by the pks in only, or by every pk when only is nil. It returns the roots it
+// touched by height.
+func (self *Exchange) undoUtxos(batch serodb.Batch, from uint64, only map[c_type.Uint512]bool) (changed map[c_type.Uint512]bool, touched map[uint64]map[c_type.Uint256]bool, err error) {
+	type heightKey struct {
+		num uint64
+		key []byte
+	}
+	var created, spent []heightKey
+	iterateFrom(self.db, utxoPrefix, from, func(num uint64, key, value []byte) {
+		created = append(created, heightKey{num, key})
+	})
+	iterateFrom(self.db, spentPrefix, from, func(num uint64, key, value []byte) {
+		spent = append(spent, heightKey{num, key})
+	})
+
+	changed = map[c_type.Uint512]bool{}
+	touched = map[uint64]map[c_type.Uint256]bool{}
+	touch := func(num uint64, root c_type.Uint256) {
+		if _, ok := touched[num]; !ok {
+			touched[num] = map[c_type.Uint256]bool{}
+		}
+		touched[num][root] = true
+	}
+
+	// Later operations on the same key win in a batch, so the heights are
+	// undone from the top down.
+	for i := len(spent) - 1; i >= 0; i-- {
+		var pk c_type.Uint512
+		copy(pk[:], spent[i].key[len(spentPrefix)+8:])
+		if only != nil && !only[pk] {
+			continue
+		}
+		var roots []c_type.Uint256
+		if value, e := self.db.Get(spent[i].key); e != nil {
+			err = e
+			return
+		} else if e := rlp.DecodeBytes(value, &roots); e != nil {
+			err = e
+			return
+		}
+		for _, root := range roots {
+			touch(spent[i].num, root)
+			utxo, e := self.getUtxo(root)
+			if e != nil || utxo.Root != root {
+				log.Error("Exchange rollback spent utxo not found", "root", common.Bytes2Hex(root[:]))
+				continue
+			}
+			pkKeys := utxoPkKeys(pk, &utxo)
+			for j := 0; j+130 <= len(pkKeys); j += 130 {
+				batch.Put(pkKeys[j:j+130], []byte{0})
+			}
+			batch.Put(nilKey(utxo.Nil), pkKeys)
+			batch.Put(nilKey(utxo.Root), pkKeys)
+		}
+		batch.Delete(spent[i].key)
+		changed[pk] = true
+	}
+
+	txRoots := map[c_type.Uint256]map[c_type.Uint256]bool{}
+	for i := len(created) - 1; i >= 0; i-- {
+		var pk c_type.Uint512
+		copy(pk[:], created[i].key[len(utxoPrefix)+8:])
+		if only != nil && !only[pk] {
+			continue
+		}
+		var roots []c_type.Uint256
+		if value, e := self.db.Get(created[i].key); e != nil {
+			err = e
+			return
+		} else if e := rlp.DecodeBytes(value, &roots); e != nil {
+			err = e
+			return
+		}
+		for _, root := range roots {
+			touch(created[i].num, root)
+			utxo, e := self.getUtxo(root)
+			if e != nil || utxo.Root != root {
+				continue
+			}
+			pkKeys := utxoPkKeys(pk, &utxo)
+			for j := 0; j+130 <= len(pkKeys); j += 130 {
+				batch.Delete(pkKeys[j : j+130])
+			}
+			batch.Delete(nilKey(utxo.Nil))
+			batch.Delete(nilKey(utxo.Root))
+			batch.Delete(nilToRootKey(utxo.Nil))
+			batch.Delete(rootKey(utxo.Root))
+			if _, ok := txRoots[utxo.TxHash]; !ok {
+				txRoots[utxo.TxHash] = map[c_type.Uint256]bool{}
+			}
+			txRoots[utxo.TxHash][root] = true
+			self.usedFlag.Delete(root)
+		}
+		batch.Delete(created[i].key)
+		changed[pk] = true
+	}
+
+	for txHash, roots := range txRoots {
+		records, e := self.GetRecordsByTxHash(txHash)
+		if e != nil {
+			continue
+		}
+		kept := []Utxo{}
+		for _, record := range records {
+			if !roots[record.Root] {
+				kept = append(kept, record)
+			}
+		}
+		if len(kept) == 0 {
+			batch.Delete(txKey(txHash))
+		} else if data, e := rlp.EncodeToBytes(&kept); e != nil {
+			err = e
+			return
+		} else {
+			batch.Put(txKey(txHash), data)
+		}
+	}
+	return
+}
+
+// rollback removes everything indexed above fork: the utxos created there are
+// dropped, the utxos spent there become unspent again and the pkg index is
+// restored from its undo records.
+func (self *Exchange) rollback(fork uint64) (err error) {
+	type heightKey struct {
+		num uint64
+		key []byte
+	}
+	var undos []heightKey
+	var heights []uint64
+
+	iterateFrom(self.db, undoPkgPrefix, fork+1, func(num uint64, key, value []byte) {
+		undos = append(undos, heightKey{num, key})
+	})
+	iterateFrom(self.db, hashPrefix, fork+1, func(num uint64, key, value []byte) {
+		heights = append(heights, num)
+	})
+	iterateFrom(self.db, blockPrefix, fork+1, func(num uint64, key, value []byte) {
+		heights = append(heights, num)
+	})
+
+	batch := self.db.NewBatch()
+	changed, _, err := self.undoUtxos(batch, fork+1, nil)
+	if err != nil {
+		return
+	}
+
+	for i := len(undos) - 1; i >= 0; i-- {
+		value, e := self.db.Get(undos[i].key)
+		if e != nil {
+			return e
+		}
+		records, e := reorg.Decode(value)
+		if e != nil {
+			return e
+		}
+		reorg.Undo(batch, records)
+		batch.Delete(undos[i].key)
+	}
+
+	for _, num := range heights {
+		batch.Delete(hashKey(num))
+		batch.Delete(blockKey(num))
+	}
+
+	// The accounts of dropped wallets keep their number in the db too.
+	next := fork + 1
+	data := utils.EncodeNumber(next)
+	iterator := self.db.NewIteratorWithPrefix(numPrefix)
+	for iterator.Next() {
+		if len(iterator.Key()) == len(numPrefix)+64 && utils.DecodeNumber(iterator.Value()) > next {
+			batch.Put(common.CopyBytes(iterator.Key()), data)
+		}
+	}
+	iterator.Release()
+
+	if err = batch.Write(); err != nil {
+		return
+	}
+
+	self.numbers.Range(func(key, value interface{}) bool {
+		if value.(uint64) > next {
+			self.numbers.Store(key, next)
+		}
+		return true
+	})
+	// the blocks indexed again after the fork are new to the subscribers
+	self.rescanned.Range(func(key, value interface{}) bool {
+		if value.(uint64) > next {
+			self.rescanned.Store(key, next)
+		}
+		return true
+	})
+	self.accounts.Range(func(key, value interface{}) bool {
+		account := value.(*Account)
+		if changed[*account.pk] {
+			account.isChanged = true
+		}
+		return true
+	})
+	ev := Event{Type: EventRollback, Num: fork}
+	if hash := self.canonicalHash(fork); hash != nil {
+		ev.BlockHash = *hash
+	}
+	self.publish([]Event{ev})
+	log.Info("Exchange rolled back", "fork", fork)
+	return
+}
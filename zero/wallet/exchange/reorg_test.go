@@ -0,0 +1,67 @@
+package exchange
+
+import (
+	"testing"
+
+	"github.com/sero-cash/go-czero-import/c_type"
+	"github.com/sero-cash/go-sero/core/types"
+	"github.com/sero-cash/go-sero/zero/txtool"
+)
+
+func TestReorgRollback(t *testing.T) {
+	env := newTestEnv(6)
+	blocksA, blocksB := env.blocks, env.fork(env.blocks[2], 5)
+	chain := env.chain
+
+	account := newTestAccount(1)
+	exchange, closer := newTestExchange(t, chain, *account)
+	defer closer()
+	pks := []c_type.Uint512{*account.pk}
+
+	// Chain A pays the height to the account and spends the out of block 2 at block 5.
+	outs := map[uint64]txtool.Out{}
+	infosA := testBlocks(account, blocksA[:4], []int64{1, 2, 3, 4}, nil, outs)
+	infosA = append(infosA, testBlocks(account, blocksA[4:], []int64{5, 6}, map[uint64]txtool.Out{5: outs[2]}, outs)...)
+	if count := exchange.indexUtxo(infosA, pks); count != len(infosA) {
+		t.Fatalf("indexed %v blocks of chain A, want %v", count, len(infosA))
+	}
+	if balance := balanceOf(exchange, account); balance != 19 {
+		t.Fatalf("balance on chain A is %v, want 19", balance)
+	}
+	if _, reorged := exchange.checkReorg(); reorged {
+		t.Fatal("reorg detected on the canonical chain")
+	}
+
+	chain.blocks = append(append([]*types.Block{env.genesis}, blocksA[:3]...), blocksB...)
+	fork, reorged := exchange.checkReorg()
+	if !reorged || fork != 3 {
+		t.Fatalf("reorg detected %v at %v, want fork at 3", reorged, fork)
+	}
+	if num := exchange.GetCurrencyNumber(*account.pk); num != 3 {
+		t.Fatalf("indexed number after rollback is %v, want 3", num)
+	}
+	if balance := balanceOf(exchange, account); balance != 6 {
+		t.Fatalf("balance after rollback is %v, want 6", balance)
+	}
+	if _, err := exchange.db.Get(rootKey(outs[6].Root)); err == nil {
+		t.Fatal("utxo of the stale block still indexed")
+	}
+
+	// Chain B spends the out of block 1 at block 6 instead.
+	infosB := testBlocks(account, blocksB[:2], []int64{40, 50}, nil, outs)
+	infosB = append(infosB, testBlocks(account, blocksB[2:], []int64{60, 70, 80}, map[uint64]txtool.Out{6: outs[1]}, outs)...)
+	exchange.indexUtxo(infosB, pks)
+	if balance := balanceOf(exchange, account); balance != 305 {
+		t.Fatalf("balance on chain B is %v, want 305", balance)
+	}
+
+	fresh, closer := newTestExchange(t, chain, *newTestAccount(1))
+	defer closer()
+	fresh.indexUtxo(append(infosA[:3:3], infosB...), pks)
+	if want, have := balanceOf(fresh, account), balanceOf(exchange, account); want != have {
+		t.Fatalf("balance after reorg is %v, a fresh index of chain B has %v", have, want)
+	}
+	if _, reorged := exchange.checkReorg(); reorged {
+		t.Fatal("reorg detected after reindexing chain B")
+	}
+}
@@ -0,0 +1,206 @@
+package exchange
+
+import (
+	"errors"
+
+	"github.com/sero-cash/go-czero-import/c_type"
+	"github.com/sero-cash/go-czero-import/seroparam"
+	"github.com/sero-cash/go-sero/log"
+	"github.com/sero-cash/go-sero/rlp"
+	"github.com/sero-cash/go-sero/zero/txtool"
+	"github.com/sero-cash/go-sero/zero/utils"
+)
+
+func (self *Exchange) getBlockInfo(num uint64) *BlockInfo {
+	value, err := self.db.Get(blockKey(num))
+	if err != nil {
+		return nil
+	}
+	var block BlockInfo
+	if err = rlp.DecodeBytes(value, &block); err != nil {
+		log.Error("Exchange Invalid block RLP", "Num", num, "err", err)
+		return nil
+	}
+	return &block
+}
+
+// merge adds the ins and outs of stored, which another group of accounts
+// indexed at the same height.
+func (self *BlockInfo) merge(stored *BlockInfo) {
+	roots := map[c_type.Uint256]bool{}
+	for _, root := range self.Ins {
+		roots[root] = true
+	}
+	for _, out := range self.Outs {
+		roots[out.Root] = true
+	}
+	for _, root := range stored.Ins {
+		if !roots[root] {
+			self.Ins = append(self.Ins, root)
+		}
+	}
+	for _, out := range stored.Outs {
+		if !roots[out.Root] {
+			self.Outs = append(self.Outs, out)
+		}
+	}
+}
+
+func (self *Exchange) checkRescan(pks []c_type.Uint512, from uint64) error {
+	if len(pks) == 0 {
+		return errors.New("have no pks")
+	}
+	for _, pk := range pks {
+		if self.getAccountByPk(pk) == nil {
+			return errors.New("not found Pk")
+		}
+		if value, ok := self.numbers.Load(pk); !ok || value.(uint64) < from {
+			return errors.New("from is above the indexed number")
+		}
+	}
+	return nil
+}
+
+// dropIndex removes what the pks indexed from the block from on and moves
+// their number back to from. The entries of the other accounts are kept.
+func (self *Exchange) dropIndex(pks []c_type.Uint512, from uint64) (err error) {
+	only := map[c_type.Uint512]bool{}
+	for _, pk := range pks {
+		only[pk] = true
+	}
+
+	batch := self.db.NewBatch()
+	changed, touched, err := self.undoUtxos(batch, from, only)
+	if err != nil {
+		return
+	}
+
+	for num, roots := range touched {
+		block := self.getBlockInfo(num)
+		if block == nil {
+			continue
+		}
+		kept := BlockInfo{Num: block.Num, Hash: block.Hash}
+		for _, root := range block.Ins {
+			if !roots[root] {
+				kept.Ins = append(kept.Ins, root)
+			}
+		}
+		for _, out := range block.Outs {
+			if !roots[out.Root] {
+				kept.Outs = append(kept.Outs, out)
+			}
+		}
+		if len(kept.Ins) == 0 && len(kept.Outs) == 0 {
+			batch.Delete(blockKey(num))
+		} else if data, e := rlp.EncodeToBytes(&kept); e != nil {
+			return e
+		} else {
+			batch.Put(blockKey(num), data)
+		}
+	}
+
+	data := utils.EncodeNumber(from)
+	for _, pk := range pks {
+		batch.Put(numKey(pk), data)
+		if from < self.historyFrom(pk) {
+			batch.Put(historyFromKey(pk), data)
+		}
+	}
+	if err = batch.Write(); err != nil {
+		return
+	}
+
+	for _, pk := range pks {
+		if value, ok := self.numbers.Load(pk); ok && value.(uint64) > from {
+			if mark, ok := self.rescanned.Load(pk); !ok || mark.(uint64) < value.(uint64) {
+				self.rescanned.Store(pk, value)
+			}
+		}
+		self.numbers.Store(pk, from)
+		if account := self.getAccountByPk(pk); account != nil && changed[pk] {
+			account.isChanged = true
+		}
+	}
+	log.Info("Exchange dropped index", "pks", len(pks), "from", from)
+	return
+}
+
+// unseenEvents drops the events of the blocks a rescan indexes again, they
+// were published when the blocks were indexed the first time.
+func (self *Exchange) unseenEvents(events []Event) []Event {
+	kept := events[:0]
+	for _, ev := range events {
+		if ev.PK != nil {
+			if value, ok := self.rescanned.Load(ev.PK.ToUint512()); ok && ev.Num < value.(uint64) {
+				continue
+			}
+		}
+		kept = append(kept, ev)
+	}
+	return kept
+}
+
+// catchUp indexes the blocks of the pks until the confirmed head, unless the
+// fetch job takes them over at a height shared with other accounts.
+func (self *Exchange) catchUp(pks []c_type.Uint512) (count uint64) {
+	target := txtool.Ref_inst.GetDelayedNum(seroparam.DefaultConfirmedBlock())
+	progress := utils.NewProgress("Exchange rescan: ", target)
+	for {
+		self.indexLock.Lock()
+		value, ok := self.numbers.Load(pks[0])
+		if !ok {
+			self.indexLock.Unlock()
+			return
+		}
+		start := value.(uint64)
+		for _, pk := range pks[1:] {
+			if value, ok := self.numbers.Load(pk); !ok || value.(uint64) != start {
+				self.indexLock.Unlock()
+				return
+			}
+		}
+		indexed := self.fetchAndIndexUtxo(start, fetchCount, pks)
+		self.indexLock.Unlock()
+
+		count += uint64(indexed)
+		if indexed > 0 && start+uint64(indexed)-1 <= target {
+			progress.Tick(start+uint64(indexed)-1, "pks", len(pks))
+		}
+		if indexed < int(fetchCount) {
+			return
+		}
+	}
+}
+
+func (self *Exchange) prepareRescan(pks []c_type.Uint512, from uint64) error {
+	self.indexLock.Lock()
+	defer self.indexLock.Unlock()
+	if err := self.checkRescan(pks, from); err != nil {
+		return err
+	}
+	return self.dropIndex(pks, from)
+}
+
+// Rescan indexes the blocks from the block from on again for the pks and
+// returns after they reached the confirmed head.
+func (self *Exchange) Rescan(pks []c_type.Uint512, from uint64) (count uint64, err error) {
+	if err = self.prepareRescan(pks, from); err != nil {
+		return
+	}
+	count = self.catchUp(pks)
+	log.Info("Exchange rescan done", "pks", len(pks), "from", from, "blocks", count)
+	return
+}
+
+// StartRescan is Rescan in the background, the progress goes to the log.
+func (self *Exchange) StartRescan(pks []c_type.Uint512, from uint64) error {
+	if err := self.prepareRescan(pks, from); err != nil {
+		return err
+	}
+	go func() {
+		count := self.catchUp(pks)
+		log.Info("Exchange rescan done", "pks", len(pks), "from", from, "blocks", count)
+	}()
+	return nil
+}
@@ -0,0 +1,102 @@
+package exchange
+
+import (
+	"testing"
+
+	"github.com/sero-cash/go-czero-import/c_type"
+	"github.com/sero-cash/go-sero/zero/txtool"
+)
+
+func blockRoots(t *testing.T, exchange *Exchange) map[c_type.Uint256]uint64 {
+	blocks, err := exchange.GetBlocksInfo(1, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := map[c_type.Uint256]uint64{}
+	for _, block := range blocks {
+		for _, root := range block.Ins {
+			roots[root] = block.Num
+		}
+		for _, out := range block.Outs {
+			roots[out.Root] = block.Num
+		}
+	}
+	return roots
+}
+
+func TestRescanKeepsOtherAccounts(t *testing.T) {
+	env := newTestEnv(6)
+
+	a, b := newTestAccount(1), newTestAccount(2)
+	exchange, closer := newTestExchange(t, env.chain, *a)
+	defer closer()
+	exchange.accounts.Store(*b.pk, b)
+	exchange.numbers.Store(*b.pk, uint64(1))
+
+	// Account a spends the out of block 2 at block 5, account b only receives.
+	outs := map[uint64]txtool.Out{}
+	infos := testBlocks(a, env.blocks[:4], []int64{1, 2, 3, 4}, nil, outs)
+	infos = append(infos, testBlocks(a, env.blocks[4:], []int64{5, 6}, map[uint64]txtool.Out{5: outs[2]}, outs)...)
+	for i, info := range testBlocks(b, env.blocks, []int64{10, 20, 30, 40, 50, 60}, nil, map[uint64]txtool.Out{}) {
+		out := info.Outs[0]
+		out.Root[0] ^= 0xff
+		infos[i].Outs = append(infos[i].Outs, out)
+	}
+	exchange.indexUtxo(infos, []c_type.Uint512{*a.pk, *b.pk})
+	if balanceOf(exchange, a) != 19 || balanceOf(exchange, b) != 210 {
+		t.Fatalf("balances are %v and %v, want 19 and 210", balanceOf(exchange, a), balanceOf(exchange, b))
+	}
+	roots := blockRoots(t, exchange)
+
+	if err := exchange.prepareRescan([]c_type.Uint512{*a.pk}, 3); err != nil {
+		t.Fatal(err)
+	}
+	if num := exchange.GetCurrencyNumber(*a.pk); num != 2 {
+		t.Fatalf("indexed number after drop is %v, want 2", num)
+	}
+	if balanceOf(exchange, a) != 3 || balanceOf(exchange, b) != 210 {
+		t.Fatalf("balances after drop are %v and %v, want 3 and 210", balanceOf(exchange, a), balanceOf(exchange, b))
+	}
+	if err := exchange.prepareRescan([]c_type.Uint512{*a.pk}, 4); err == nil {
+		t.Fatal("rescan above the indexed number accepted")
+	}
+
+	exchange.indexUtxo(infos[2:], []c_type.Uint512{*a.pk})
+	if balanceOf(exchange, a) != 19 || balanceOf(exchange, b) != 210 {
+		t.Fatalf("balances after rescan are %v and %v, want 19 and 210", balanceOf(exchange, a), balanceOf(exchange, b))
+	}
+	rescanned := blockRoots(t, exchange)
+	if len(rescanned) != len(roots) {
+		t.Fatalf("got %v roots in the env.blocks, want %v", len(rescanned), len(roots))
+	}
+	for root, num := range roots {
+		if rescanned[root] != num {
+			t.Fatalf("root %x at block %v, want %v", root, rescanned[root], num)
+		}
+	}
+}
+
+func TestRescanPublishesOnlyNewBlocks(t *testing.T) {
+	env := newTestEnv(3)
+	account := newTestAccount(1)
+	exchange, closer := newTestExchange(t, env.chain, *account)
+	defer closer()
+
+	infos := testBlocks(account, env.blocks, []int64{1, 2, 3}, nil, map[uint64]txtool.Out{})
+	exchange.indexUtxo(infos[:2], []c_type.Uint512{*account.pk})
+	if err := exchange.prepareRescan([]c_type.Uint512{*account.pk}, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	published := make(chan []Event, 4)
+	sub := exchange.feed.Subscribe(published)
+	defer sub.Unsubscribe()
+	exchange.indexUtxo(infos, []c_type.Uint512{*account.pk})
+	events := <-published
+	if len(events) != 1 || events[0].Type != EventUtxoIn || events[0].Num != 3 {
+		t.Fatalf("got %v events, want the utxoIn of the new block 3", events)
+	}
+	if _, ok := exchange.rescanned.Load(*account.pk); ok {
+		t.Fatal("rescan mark kept after catching up")
+	}
+}
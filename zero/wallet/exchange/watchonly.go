@@ -0,0 +1,141 @@
+package exchange
+
+import (
+	"errors"
+	"time"
+
+	"github.com/sero-cash/go-czero-import/c_superzk"
+	"github.com/sero-cash/go-czero-import/c_type"
+	"github.com/sero-cash/go-czero-import/seroparam"
+	"github.com/sero-cash/go-czero-import/superzk"
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/common/address"
+	"github.com/sero-cash/go-sero/log"
+	"github.com/sero-cash/go-sero/rlp"
+	"github.com/sero-cash/go-sero/zero/utils"
+)
+
+// ErrWatchOnly is returned when a tx of a watch-only account has to be signed,
+// the unsigned GTxParam must be signed offline and sent by exchange_commitTx.
+var ErrWatchOnly = errors.New("watch-only account can not sign, sign the tx param offline")
+
+var watchTkPrefix = []byte("WATCHTK")
+
+// "WATCHTK" + pk => WatchAccount
+func watchTkKey(pk c_type.Uint512) []byte {
+	return append(watchTkPrefix, pk[:]...)
+}
+
+// WatchAccount is an account known to the exchange by its TK only.
+type WatchAccount struct {
+	Tk c_type.Tk
+	At uint64
+}
+
+func (self *Account) watchOnly() bool {
+	return self.wallet == nil
+}
+
+func defaultPkr(pk *c_type.Uint512, index uint64) c_type.PKr {
+	r := c_type.Uint256{}
+	copy(r[:], common.LeftPadBytes(utils.EncodeNumber(index), 32))
+	return superzk.Pk2PKr(pk, &r)
+}
+
+func (self *Exchange) initWatchAccounts() {
+	iterator := self.db.NewIteratorWithPrefix(watchTkPrefix)
+	defer iterator.Release()
+	for iterator.Next() {
+		var wa WatchAccount
+		if err := rlp.DecodeBytes(iterator.Value(), &wa); err != nil {
+			log.Error("Exchange invalid watch account", "key", common.Bytes2Hex(iterator.Key()), "error", err)
+			continue
+		}
+		self.initWatchAccount(&wa)
+	}
+}
+
+func (self *Exchange) initWatchAccount(wa *WatchAccount) (pk c_type.Uint512, e error) {
+	if pk, e = superzk.Tk2Pk(&wa.Tk); e != nil {
+		return
+	}
+	if _, ok := self.accounts.Load(pk); ok {
+		return
+	}
+	account := Account{}
+	account.pk = pk.NewRef()
+	account.tk = wa.Tk.NewRef()
+	copy(account.skr[:], account.tk[:])
+	account.mainPkr = defaultPkr(account.pk, 1)
+	account.isChanged = true
+	account.nextMergeTime = time.Now()
+	if balancePkr := self.getBalancePkr(account.pk); balancePkr != nil {
+		if superzk.IsMyPKr(account.tk, balancePkr) {
+			account.balancePkr = balancePkr
+		}
+	}
+	self.accounts.Store(pk, &account)
+
+	self.markHistoryFrom(pk)
+	if num := self.starNum(account.pk); num > wa.At {
+		self.numbers.Store(pk, num)
+	} else {
+		self.numbers.Store(pk, wa.At)
+	}
+	log.Info("Add watch-only PK", "pk", address.PKAddress(pk).String(), "At", self.GetCurrencyNumber(pk))
+	return
+}
+
+// AddWatchTk registers a TK with the exchange, its outs are indexed from the
+// block at on. Nothing is rescanned for a PK that was indexed before.
+func (self *Exchange) AddWatchTk(tk c_type.Tk, at uint64) (pk c_type.Uint512, e error) {
+	if pk, e = superzk.Tk2Pk(&tk); e != nil {
+		return
+	}
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if _, ok := self.accounts.Load(pk); ok {
+		e = errors.New("account already exists")
+		return
+	}
+	if c_superzk.IsFlagSet(tk[:]) && at < seroparam.SIP5() {
+		at = seroparam.SIP5()
+	}
+	wa := WatchAccount{Tk: tk, At: at}
+	data, err := rlp.EncodeToBytes(&wa)
+	if err != nil {
+		e = err
+		return
+	}
+	if e = self.db.Put(watchTkKey(pk), data); e != nil {
+		return
+	}
+	return self.initWatchAccount(&wa)
+}
+
+// RemoveWatchTk stops indexing a watch-only account, the indexed outs stay in
+// the database.
+func (self *Exchange) RemoveWatchTk(pk c_type.Uint512) error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	account := self.getAccountByPk(pk)
+	if account == nil || !account.watchOnly() {
+		return errors.New("not a watch-only account")
+	}
+	if err := self.db.Delete(watchTkKey(pk)); err != nil {
+		return err
+	}
+	self.accounts.Delete(pk)
+	self.numbers.Delete(pk)
+	return nil
+}
+
+func (self *Exchange) GetWatchAccounts() (pks []address.PKAddress) {
+	self.accounts.Range(func(key, value interface{}) bool {
+		if account := value.(*Account); account.watchOnly() {
+			pks = append(pks, address.PKAddress(*account.pk))
+		}
+		return true
+	})
+	return
+}
@@ -0,0 +1,105 @@
+package exchange
+
+import (
+	"testing"
+
+	"github.com/sero-cash/go-czero-import/c_type"
+	"github.com/sero-cash/go-czero-import/cpt"
+	"github.com/sero-cash/go-sero/accounts"
+	"github.com/sero-cash/go-sero/common/address"
+	"github.com/sero-cash/go-sero/zero/txtool/prepare"
+)
+
+func TestWatchTk(t *testing.T) {
+	cpt.ZeroInit(cpt.NET_Alpha)
+	account := newTestAccount(2)
+	exchange, closer := newTestExchange(t, &testChain{}, *newTestAccount(1))
+	defer closer()
+
+	pk, err := exchange.AddWatchTk(*account.tk, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pk != *account.pk {
+		t.Fatalf("got pk %x, want %x", pk, account.pk)
+	}
+	if _, err := exchange.AddWatchTk(*account.tk, 100); err == nil {
+		t.Fatal("watch tk added twice")
+	}
+
+	// The watch accounts are loaded again after a restart.
+	restarted := &Exchange{db: exchange.db}
+	restarted.initWatchAccounts()
+	if num := restarted.GetCurrencyNumber(pk); num != 99 {
+		t.Fatalf("indexed number is %v, want 99", num)
+	}
+	if pks := restarted.GetWatchAccounts(); len(pks) != 1 || pks[0].ToUint512() != pk {
+		t.Fatalf("got watch accounts %v, want %x", pks, pk)
+	}
+
+	if _, _, err := restarted.Merge(&pk, "SERO", true); err != ErrWatchOnly {
+		t.Fatalf("merge returned %v, want %v", err, ErrWatchOnly)
+	}
+	if _, err := restarted.BatchPay(BatchPayParam{From: pk, Receptions: []prepare.Reception{{}}}); err != ErrWatchOnly {
+		t.Fatalf("batchPay returned %v, want %v", err, ErrWatchOnly)
+	}
+
+	if err := restarted.RemoveWatchTk(pk); err != nil {
+		t.Fatal(err)
+	}
+	if err := restarted.RemoveWatchTk(c_type.Uint512{}); err == nil {
+		t.Fatal("removed an unknown account")
+	}
+	restarted = &Exchange{db: exchange.db}
+	restarted.initWatchAccounts()
+	if pks := restarted.GetWatchAccounts(); len(pks) != 0 {
+		t.Fatalf("got watch accounts %v after removal", pks)
+	}
+}
+
+// heldWallet is a wallet holding the account of a pk.
+type heldWallet struct {
+	accounts.Wallet
+	account accounts.Account
+}
+
+func (self *heldWallet) Accounts() []accounts.Account {
+	return []accounts.Account{self.account}
+}
+
+func TestWatchTkWallet(t *testing.T) {
+	cpt.ZeroInit(cpt.NET_Alpha)
+	account := newTestAccount(2)
+	exchange, closer := newTestExchange(t, &testChain{}, *newTestAccount(1))
+	defer closer()
+
+	pk, err := exchange.AddWatchTk(*account.tk, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := &heldWallet{account: accounts.Account{Address: address.PKAddress(pk), Tk: address.TKAddress(*account.tk), Version: 2}}
+
+	// the indexing reads the account while its wallet arrives
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			if account := exchange.getAccountByPk(pk); account != nil {
+				account.watchOnly()
+			}
+		}
+	}()
+	exchange.initWallet(w)
+	<-done
+	if got := exchange.getAccountByPk(pk); got.watchOnly() || got.version != 2 {
+		t.Fatal("watched pk did not get its wallet")
+	}
+
+	exchange.dropWallet(w)
+	if got := exchange.getAccountByPk(pk); !got.watchOnly() {
+		t.Fatal("dropped wallet still held")
+	}
+	if num := exchange.GetCurrencyNumber(pk); num != 99 {
+		t.Fatalf("indexed number is %v after the wallet was dropped, want 99", num)
+	}
+}
@@ -0,0 +1,206 @@
+package exchange
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/sero-cash/go-sero/common/hexutil"
+	"github.com/sero-cash/go-sero/event"
+	"github.com/sero-cash/go-sero/log"
+)
+
+const (
+	webhookSignatureHeader = "X-Sero-Signature"
+	webhookEventHeader     = "X-Sero-Event"
+)
+
+var (
+	webhookTimeout     = 10 * time.Second
+	webhookMinBackoff  = time.Second
+	webhookMaxBackoff  = 5 * time.Minute
+	webhookMaxAttempts = 10
+	// webhookMaxRetries bounds the failed events waiting for a retry.
+	webhookMaxRetries = 1000
+)
+
+// webhook posts every event as JSON to url. The body is signed with
+// HMAC-SHA256 keyed by secret, the hex digest goes in X-Sero-Signature.
+type webhook struct {
+	url    string
+	secret []byte
+	client *http.Client
+	sub    event.Subscription
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// webhookRetry is an event waiting for its next attempt.
+type webhookRetry struct {
+	ev      Event
+	body    []byte
+	attempt int
+	backoff time.Duration
+	at      time.Time
+}
+
+func SignWebhook(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return hexutil.Encode(mac.Sum(nil))
+}
+
+func newWebhook(url string, secret []byte, client *http.Client) *webhook {
+	hook := &webhook{
+		url:    url,
+		secret: secret,
+		client: client,
+		done:   make(chan struct{}),
+	}
+	hook.ctx, hook.cancel = context.WithCancel(context.Background())
+	return hook
+}
+
+func (self *Exchange) startWebhook() *webhook {
+	if self.config.WebhookSecret == "" {
+		log.Error("Exchange webhook not started", "error", errWebhookSecret)
+		return nil
+	}
+	hook := newWebhook(self.config.WebhookURL, []byte(self.config.WebhookSecret), &http.Client{Timeout: webhookTimeout})
+	crit := EventCriteria{Confirmations: self.config.WebhookConfirmations}
+	go hook.loop(func(events chan Event) event.Subscription {
+		return self.SubscribeEvents(crit, events)
+	})
+	self.webhook = hook
+	log.Info("Exchange webhook started", "url", hook.url, "confirmations", self.config.WebhookConfirmations)
+	return hook
+}
+
+// stop ends the deliveries, a post in flight is cancelled.
+func (self *webhook) stop() {
+	self.cancel()
+	<-self.done
+}
+
+// loop delivers the events of the subscription. A failed event is retried
+// with an exponential backoff until the endpoint answers 2xx or
+// webhookMaxAttempts is reached, the later events are not held back by it,
+// so they may arrive out of order. A subscription that fell behind is
+// renewed, the events it dropped are lost.
+func (self *webhook) loop(subscribe func(events chan Event) event.Subscription) {
+	defer close(self.done)
+	events := make(chan Event)
+	self.sub = subscribe(events)
+	defer func() { self.sub.Unsubscribe() }()
+
+	var retries []*webhookRetry
+	for {
+		var wait <-chan time.Time
+		var timer *time.Timer
+		if len(retries) > 0 {
+			next := retries[0].at
+			for _, r := range retries[1:] {
+				if r.at.Before(next) {
+					next = r.at
+				}
+			}
+			timer = time.NewTimer(time.Until(next))
+			wait = timer.C
+		}
+		select {
+		case ev := <-events:
+			if r := self.deliver(&ev); r != nil {
+				if len(retries) >= webhookMaxRetries {
+					log.Error("Exchange webhook dropped event", "type", retries[0].ev.Type, "num", retries[0].ev.Num, "error", "too many retries")
+					retries = retries[1:]
+				}
+				retries = append(retries, r)
+			}
+		case now := <-wait:
+			kept := retries[:0]
+			for _, r := range retries {
+				if r.at.After(now) || self.retry(r) {
+					kept = append(kept, r)
+				}
+			}
+			retries = kept
+		case err := <-self.sub.Err():
+			if err != errEventQueueFull {
+				if err != nil {
+					log.Error("Exchange webhook subscription", "error", err)
+				}
+				return
+			}
+			log.Error("Exchange webhook subscription", "error", err)
+			self.sub = subscribe(events)
+		case <-self.ctx.Done():
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+// deliver posts the event once, a failed one is returned for a retry.
+func (self *webhook) deliver(ev *Event) *webhookRetry {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		log.Error("Exchange webhook marshal", "type", ev.Type, "error", err)
+		return nil
+	}
+	r := &webhookRetry{ev: *ev, body: body, backoff: webhookMinBackoff}
+	if self.retry(r) {
+		return r
+	}
+	return nil
+}
+
+// retry makes the next attempt of r and reports whether another one is due.
+func (self *webhook) retry(r *webhookRetry) bool {
+	r.attempt++
+	err := self.post(r.ev.Type, r.body)
+	if err == nil || self.ctx.Err() != nil {
+		return false
+	}
+	log.Warn("Exchange webhook failed", "type", r.ev.Type, "num", r.ev.Num, "attempt", r.attempt, "error", err)
+	if r.attempt >= webhookMaxAttempts {
+		log.Error("Exchange webhook dropped event", "type", r.ev.Type, "num", r.ev.Num, "error", err)
+		return false
+	}
+	r.at = time.Now().Add(r.backoff)
+	if r.backoff *= 2; r.backoff > webhookMaxBackoff {
+		r.backoff = webhookMaxBackoff
+	}
+	return true
+}
+
+func (self *webhook) post(typ string, body []byte) error {
+	req, err := http.NewRequest("POST", self.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(self.ctx)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhookEventHeader, typ)
+	req.Header.Set(webhookSignatureHeader, SignWebhook(self.secret, body))
+	resp, err := self.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %v", resp.Status)
+	}
+	return nil
+}
@@ -18,7 +18,7 @@ func (self *LightNode) CurrentLight() *LightNode {
 func (self *LightNode) GetOutsByPKr(pkrs []c_type.PKr, start, end uint64) (br BlockOutResp, e error) {
 	br.CurrentNum = self.getLastNumber()
 	blockOuts := []BlockOut{}
-	if end == 0  {
+	if end == 0 || end > br.CurrentNum {
 		end = br.CurrentNum
 	}
 	for _, pkr := range pkrs {
@@ -38,6 +38,9 @@ func (self *LightNode) GetOutsByPKr(pkrs []c_type.PKr, start, end uint64) (br Bl
 				log.Error("Light Invalid block RLP", "Num:", num, "err:", err)
 				return br, err
 			} else {
+				for i := range bds {
+					bds[i].Confirmations = br.CurrentNum - num + 1
+				}
 				blockOut := BlockOut{Num: num, Data: bds}
 				blockOuts = append(blockOuts, blockOut)
 			}
@@ -81,4 +84,6 @@ type BlockOut struct {
 type BlockData struct {
 	TxInfo TxInfo
 	Out txtool.Out
+	// Confirmations counts the indexed blocks from the one of the out.
+	Confirmations uint64 `rlp:"-"`
 }
@@ -0,0 +1,158 @@
+package light
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+
+	"github.com/sero-cash/go-czero-import/c_type"
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/common/hexutil"
+	"github.com/sero-cash/go-sero/rlp"
+	"github.com/sero-cash/go-sero/serodb"
+)
+
+// maxFilterCount bounds the filters returned at once.
+const maxFilterCount = 1000
+
+var (
+	filterPrefix = []byte("LIGHT_FILTER")
+	blockPrefix  = []byte("LIGHT_BLOCK")
+)
+
+func filterKey(num uint64) []byte {
+	return append(append([]byte{}, filterPrefix...), uint64ToBytes(num)...)
+}
+
+func blockKey(num uint64) []byte {
+	return append(append([]byte{}, blockPrefix...), uint64ToBytes(num)...)
+}
+
+// blockIndex lists the PKrs of the outs and the nils of a block.
+type blockIndex struct {
+	Hash common.Hash
+	Pkrs []c_type.PKr
+	Nils []c_type.Uint256
+}
+
+// BlockFilter matches the PKrs of the outs and the nils of the block Num,
+// it is keyed by the first 16 bytes of Hash. Filter is empty for the blocks
+// indexed before the filters.
+type BlockFilter struct {
+	Num    uint64
+	Hash   common.Hash
+	Filter hexutil.Bytes
+}
+
+// BlockOuts are the outs and the nils of the block Num.
+type BlockOuts struct {
+	Num        uint64
+	Hash       common.Hash
+	CurrentNum uint64
+	Outs       []BlockData
+	Nils       []c_type.Uint256
+}
+
+func filterItems(pkrs []c_type.PKr, nils []c_type.Uint256) (items [][]byte) {
+	for i := range pkrs {
+		items = append(items, pkrs[i][:])
+	}
+	for i := range nils {
+		items = append(items, nils[i][:])
+	}
+	return
+}
+
+// putFilter indexes the PKrs and the nils the block wrote the keys of, and
+// returns the keys with the ones of the index.
+func putFilter(batch serodb.Batch, num uint64, hash common.Hash, pkrMap map[c_type.PKr][]BlockData, keys [][]byte) ([][]byte, error) {
+	index := blockIndex{Hash: hash}
+	for pkr := range pkrMap {
+		index.Pkrs = append(index.Pkrs, pkr)
+	}
+	sort.Slice(index.Pkrs, func(i, j int) bool {
+		return bytes.Compare(index.Pkrs[i][:], index.Pkrs[j][:]) < 0
+	})
+	for _, key := range keys {
+		if bytes.HasPrefix(key, nilPrefix) {
+			var Nil c_type.Uint256
+			copy(Nil[:], key[len(nilPrefix):])
+			index.Nils = append(index.Nils, Nil)
+		}
+	}
+	data, err := rlp.EncodeToBytes(&index)
+	if err != nil {
+		return keys, err
+	}
+	batch.Put(blockKey(num), data)
+	batch.Put(filterKey(num), BuildFilter(hash[:16], filterItems(index.Pkrs, index.Nils)))
+	return append(keys, blockKey(num), filterKey(num)), nil
+}
+
+func (self *LightNode) blockIndex(num uint64) (*blockIndex, error) {
+	data, err := self.db.Get(blockKey(num))
+	if err != nil {
+		return nil, nil
+	}
+	var index blockIndex
+	if err := rlp.DecodeBytes(data, &index); err != nil {
+		return nil, err
+	}
+	return &index, nil
+}
+
+// GetFilters returns the filters of count blocks from start, so that the
+// wallets match their PKrs and nils without telling them.
+func (self *LightNode) GetFilters(start, count uint64) (filters []BlockFilter, e error) {
+	current := self.getLastNumber()
+	if count > maxFilterCount {
+		count = maxFilterCount
+	}
+	for num := start; num < start+count && num <= current; num++ {
+		filter := BlockFilter{Num: num}
+		index, err := self.blockIndex(num)
+		if err != nil {
+			return nil, err
+		}
+		if index != nil {
+			filter.Hash = index.Hash
+			filter.Filter, _ = self.db.Get(filterKey(num))
+		}
+		filters = append(filters, filter)
+	}
+	return
+}
+
+// GetBlockOuts returns all the outs and the nils of the block.
+func (self *LightNode) GetBlockOuts(num uint64) (outs BlockOuts, e error) {
+	outs.Num = num
+	outs.CurrentNum = self.getLastNumber()
+	if num > outs.CurrentNum {
+		e = errors.New("block not indexed")
+		return
+	}
+	index, e := self.blockIndex(num)
+	if e != nil {
+		return
+	}
+	if index == nil {
+		e = errors.New("block indexed without filter")
+		return
+	}
+	outs.Hash, outs.Nils = index.Hash, index.Nils
+	for _, pkr := range index.Pkrs {
+		data, err := self.db.Get(pkrKey(pkr, num))
+		if err != nil {
+			continue
+		}
+		var bds []BlockData
+		if e = rlp.DecodeBytes(data, &bds); e != nil {
+			return
+		}
+		for i := range bds {
+			bds[i].Confirmations = outs.CurrentNum - num + 1
+		}
+		outs.Outs = append(outs.Outs, bds...)
+	}
+	return
+}
@@ -0,0 +1,149 @@
+package light
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/bits"
+	"sort"
+
+	"github.com/sero-cash/go-sero/crypto"
+)
+
+// The Golomb-Rice parameters of BIP 158, a false positive rate of 1/M.
+const (
+	filterP = 19
+	filterM = 784931
+)
+
+var errFilterTruncated = errors.New("filter truncated")
+
+// filterHash maps the item into [0, f) with the key of the filter.
+func filterHash(key []byte, item []byte, f uint64) uint64 {
+	sum := crypto.Keccak256(key, item)
+	hi, _ := bits.Mul64(binary.BigEndian.Uint64(sum[:8]), f)
+	return hi
+}
+
+func filterValues(key []byte, items [][]byte, n uint64) []uint64 {
+	values := make([]uint64, 0, len(items))
+	for _, item := range items {
+		values = append(values, filterHash(key, item, n*filterM))
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+	return values
+}
+
+type bitWriter struct {
+	data []byte
+	bits uint
+}
+
+func (w *bitWriter) writeBit(bit bool) {
+	if w.bits%8 == 0 {
+		w.data = append(w.data, 0)
+	}
+	if bit {
+		w.data[len(w.data)-1] |= 0x80 >> (w.bits % 8)
+	}
+	w.bits++
+}
+
+func (w *bitWriter) writeBits(value uint64, count uint) {
+	for i := count; i > 0; i-- {
+		w.writeBit(value&(1<<(i-1)) != 0)
+	}
+}
+
+type bitReader struct {
+	data []byte
+	bits uint
+}
+
+func (r *bitReader) readBit() (bool, error) {
+	if r.bits/8 >= uint(len(r.data)) {
+		return false, errFilterTruncated
+	}
+	bit := r.data[r.bits/8]&(0x80>>(r.bits%8)) != 0
+	r.bits++
+	return bit, nil
+}
+
+func (r *bitReader) readValue() (uint64, error) {
+	var quotient uint64
+	for {
+		bit, err := r.readBit()
+		if err != nil {
+			return 0, err
+		}
+		if !bit {
+			break
+		}
+		quotient++
+	}
+	remainder := uint64(0)
+	for i := 0; i < filterP; i++ {
+		bit, err := r.readBit()
+		if err != nil {
+			return 0, err
+		}
+		remainder <<= 1
+		if bit {
+			remainder |= 1
+		}
+	}
+	return quotient<<filterP | remainder, nil
+}
+
+// BuildFilter makes the Golomb-coded set of the items keyed by key, the
+// first 16 bytes of the hash of the block.
+func BuildFilter(key []byte, items [][]byte) []byte {
+	n := make([]byte, binary.MaxVarintLen64)
+	filter := n[:binary.PutUvarint(n, uint64(len(items)))]
+	if len(items) == 0 {
+		return filter
+	}
+	w := &bitWriter{}
+	last := uint64(0)
+	for _, value := range filterValues(key, items, uint64(len(items))) {
+		delta := value - last
+		last = value
+		for q := delta >> filterP; q > 0; q-- {
+			w.writeBit(true)
+		}
+		w.writeBit(false)
+		w.writeBits(delta, filterP)
+	}
+	return append(filter, w.data...)
+}
+
+// MatchFilter tells whether the filter may hold any of the items, it has
+// false positives but no false negatives.
+func MatchFilter(filter []byte, key []byte, items [][]byte) (bool, error) {
+	n, read := binary.Uvarint(filter)
+	if read <= 0 {
+		return false, errFilterTruncated
+	}
+	if n == 0 || len(items) == 0 {
+		return false, nil
+	}
+	queries := filterValues(key, items, n)
+	r := &bitReader{data: filter[read:]}
+	value := uint64(0)
+	for i := uint64(0); i < n; i++ {
+		delta, err := r.readValue()
+		if err != nil {
+			return false, err
+		}
+		value += delta
+		for len(queries) > 0 && queries[0] < value {
+			queries = queries[1:]
+		}
+		if len(queries) == 0 {
+			return false, nil
+		}
+		if queries[0] == value {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,43 @@
+package light
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestFilterMatch(t *testing.T) {
+	key := make([]byte, 16)
+	key[0] = 1
+	var items [][]byte
+	for i := 0; i < 200; i++ {
+		item := make([]byte, 96)
+		binary.BigEndian.PutUint64(item, uint64(i))
+		items = append(items, item)
+	}
+	filter := BuildFilter(key, items)
+	for _, item := range items {
+		if ok, err := MatchFilter(filter, key, [][]byte{item}); !ok || err != nil {
+			t.Fatal("member not matched", err)
+		}
+	}
+	misses := 0
+	for i := 1000; i < 2000; i++ {
+		item := make([]byte, 96)
+		binary.BigEndian.PutUint64(item, uint64(i))
+		if ok, _ := MatchFilter(filter, key, [][]byte{item}); !ok {
+			misses++
+		}
+	}
+	if misses < 990 {
+		t.Fatal("false positives", 1000-misses)
+	}
+	if ok, _ := MatchFilter(filter, key, [][]byte{items[7], items[150]}); !ok {
+		t.Fatal("set not matched")
+	}
+	if ok, err := MatchFilter(BuildFilter(key, nil), key, items); ok || err != nil {
+		t.Fatal("empty filter matched", err)
+	}
+	if _, err := MatchFilter(filter[:len(filter)/2], key, [][]byte{items[199]}); err == nil {
+		t.Fatal("truncated filter decoded")
+	}
+}
@@ -94,6 +94,7 @@ func (self *LightNode) fetchBlockInfo() {
 	if txtool.Ref_inst.Bc == nil || !txtool.Ref_inst.Bc.IsValid() {
 		return
 	}
+	self.checkReorg()
 	start := self.getLastNumber()
 	blocks, err := self.sri.GetBlocksInfo(start+1, fetchCount)
 	if err != nil {
@@ -113,6 +114,7 @@ func (self *LightNode) fetchBlockInfo() {
 		copy(blockHash[:], block.Hash[:])
 		body := rawdb.ReadBody(self.bcDB, blockHash, blockNum)
 		blockDB := rawdb.ReadBlock(self.bcDB, blockHash, blockNum)
+		var keys [][]byte
 
 		for _, out := range outs {
 			txHash := common.Hash{}
@@ -169,6 +171,7 @@ func (self *LightNode) fetchBlockInfo() {
 				return
 			}
 			batch.Put(pkrKey(pkr, uint64(block.Num)), data)
+			keys = append(keys, pkrKey(pkr, uint64(block.Num)))
 		}
 		for _, tx := range body.Transactions {
 			hash := tx.Hash()
@@ -201,34 +204,40 @@ func (self *LightNode) fetchBlockInfo() {
 			} else {
 				if tx.Stxt().Tx0() != nil {
 					for _, in := range tx.Stxt().Tx0().Desc_O.Ins {
-						batch.Put(nilKey(in.Nil), nilValue)
-						batch.Put(nilKey(in.Root), nilValue)
+						keys = putNil(batch, keys, in.Nil, nilValue)
+						keys = putNil(batch, keys, in.Root, nilValue)
 					}
 					for _, in := range tx.Stxt().Tx0().Desc_Z.Ins {
-						batch.Put(nilKey(in.Trace), nilValue)
-						batch.Put(nilKey(in.Nil), nilValue)
+						keys = putNil(batch, keys, in.Trace, nilValue)
+						keys = putNil(batch, keys, in.Nil, nilValue)
 					}
 				}
 				if tx.Stxt().Tx1.Ins_C != nil {
 					for _, in := range tx.Stxt().Tx1.Ins_C {
-						batch.Put(nilKey(in.Nil), nilValue)
+						keys = putNil(batch, keys, in.Nil, nilValue)
 					}
 				}
 				if tx.Stxt().Tx1.Ins_P != nil {
 					for _, in := range tx.Stxt().Tx1.Ins_P {
-						batch.Put(nilKey(in.Nil), nilValue)
-						batch.Put(nilKey(in.Root), nilValue)
+						keys = putNil(batch, keys, in.Nil, nilValue)
+						keys = putNil(batch, keys, in.Root, nilValue)
 					}
 				}
 				if tx.Stxt().Tx1.Ins_P0 != nil {
 					for _, in := range tx.Stxt().Tx1.Ins_P0 {
-						batch.Put(nilKey(in.Nil), nilValue)
-						batch.Put(nilKey(in.Root), nilValue)
-						batch.Put(nilKey(in.Trace), nilValue)
+						keys = putNil(batch, keys, in.Nil, nilValue)
+						keys = putNil(batch, keys, in.Root, nilValue)
+						keys = putNil(batch, keys, in.Trace, nilValue)
 					}
 				}
 			}
 		}
+		if keys, err = putFilter(batch, blockNum, blockHash, pkrMap, keys); err != nil {
+			return
+		}
+		if err := recordBlock(batch, blockNum, blockHash, keys); err != nil {
+			return
+		}
 		// nils := block.Nils
 		// if len(nils) > 0 {
 		//	for _, Nil := range nils {
@@ -276,6 +285,11 @@ func nilKey(Nil c_type.Uint256) []byte {
 	return append(nilPrefix, Nil[:]...)
 }
 
+func putNil(batch serodb.Batch, keys [][]byte, Nil c_type.Uint256, value []byte) [][]byte {
+	batch.Put(nilKey(Nil), value)
+	return append(keys, nilKey(Nil))
+}
+
 func pkrKey(pkr c_type.PKr, num uint64) []byte {
 	key := append(pkrPrefix, pkr[:]...)
 	return append(key, uint64ToBytes(num)...)
@@ -0,0 +1,109 @@
+package light
+
+import (
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/core/rawdb"
+	"github.com/sero-cash/go-sero/log"
+	"github.com/sero-cash/go-sero/rlp"
+	"github.com/sero-cash/go-sero/serodb"
+	"github.com/sero-cash/go-sero/zero/wallet/reorg"
+)
+
+var (
+	hashPrefix = []byte("LIGHT_HASH")
+	undoPrefix = []byte("LIGHT_UNDO")
+)
+
+func hashKey(num uint64) []byte {
+	return append(append([]byte{}, hashPrefix...), uint64ToBytes(num)...)
+}
+
+func undoKey(num uint64) []byte {
+	return append(append([]byte{}, undoPrefix...), uint64ToBytes(num)...)
+}
+
+// recordBlock keeps the hash of the indexed block and the keys it wrote so
+// that a reorg can unwind them.
+func recordBlock(batch serodb.Batch, num uint64, hash common.Hash, keys [][]byte) error {
+	records := make([]reorg.Record, 0, len(keys))
+	for _, key := range keys {
+		records = append(records, reorg.Record{Key: key})
+	}
+	data, err := rlp.EncodeToBytes(records)
+	if err != nil {
+		return err
+	}
+	batch.Put(hashKey(num), hash[:])
+	batch.Put(undoKey(num), data)
+	if stale, ok := reorg.Stale(num); ok {
+		batch.Delete(hashKey(stale))
+		batch.Delete(undoKey(stale))
+	}
+	return nil
+}
+
+// decodeUndo reads the undo records of a block, the index used to keep only
+// the written keys.
+func decodeUndo(data []byte) ([]reorg.Record, error) {
+	records, err := reorg.Decode(data)
+	if err == nil {
+		return records, nil
+	}
+	var keys [][]byte
+	if rlp.DecodeBytes(data, &keys) != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		records = append(records, reorg.Record{Key: key})
+	}
+	return records, nil
+}
+
+func (self *LightNode) indexedHash(num uint64) *common.Hash {
+	value, err := self.db.Get(hashKey(num))
+	if err != nil || len(value) != common.HashLength {
+		return nil
+	}
+	hash := common.BytesToHash(value)
+	return &hash
+}
+
+// checkReorg compares the hashes of the indexed blocks with the canonical
+// chain and unwinds the blocks above the last one both agree on.
+func (self *LightNode) checkReorg() {
+	top := self.getLastNumber()
+	fork, reorged := reorg.FindFork(top, self.indexedHash, func(num uint64) *common.Hash {
+		hash := rawdb.ReadCanonicalHash(self.bcDB, num)
+		return &hash
+	})
+	if reorged {
+		log.Warn("Light detected reorg", "top", top, "fork", fork)
+		if err := self.unwind(top, fork); err != nil {
+			log.Error("Light unwind", "fork", fork, "err", err)
+		}
+	}
+}
+
+// unwind drops what the blocks above fork wrote.
+func (self *LightNode) unwind(top, fork uint64) error {
+	batch := self.db.NewBatch()
+	for num := top; num > fork; num-- {
+		value, err := self.db.Get(undoKey(num))
+		if err != nil {
+			continue
+		}
+		records, err := decodeUndo(value)
+		if err != nil {
+			return err
+		}
+		reorg.Undo(batch, records)
+		batch.Delete(undoKey(num))
+		batch.Delete(hashKey(num))
+	}
+	batch.Put(numKey(), uint64ToBytes(fork))
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	self.lastNumber = fork
+	return nil
+}
@@ -0,0 +1,102 @@
+package light
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/sero-cash/go-czero-import/c_type"
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/core/rawdb"
+	"github.com/sero-cash/go-sero/rlp"
+	"github.com/sero-cash/go-sero/serodb"
+	"github.com/sero-cash/go-sero/zero/txs/stx/stx_v1"
+)
+
+func TestUnwindReorg(t *testing.T) {
+	dir, err := ioutil.TempDir("", "light")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	db, err := serodb.NewLDBDatabase(dir, 16, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	bcDB := serodb.NewMemDatabase()
+	node := &LightNode{db: db, bcDB: bcDB}
+
+	pkr := c_type.PKr{1}
+	out := BlockData{}
+	out.Out.State.OS.Out_P = &stx_v1.Out_P{PKr: pkr}
+	data, err := rlp.EncodeToBytes([]BlockData{out})
+	if err != nil {
+		t.Fatal(err)
+	}
+	batch := db.NewBatch()
+	for num := uint64(1); num <= 5; num++ {
+		hash := common.Hash{byte(num)}
+		rawdb.WriteCanonicalHash(bcDB, hash, num)
+		batch.Put(pkrKey(pkr, num), data)
+		nilValue, _ := rlp.EncodeToBytes(NilValue{Num: num})
+		batch.Put(nilKey(c_type.Uint256{byte(num)}), nilValue)
+		keys, err := putFilter(batch, num, hash, map[c_type.PKr][]BlockData{pkr: nil}, [][]byte{pkrKey(pkr, num), nilKey(c_type.Uint256{byte(num)})})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := recordBlock(batch, num, hash, keys); err != nil {
+			t.Fatal(err)
+		}
+	}
+	batch.Put(numKey(), uint64ToBytes(5))
+	if err := batch.Write(); err != nil {
+		t.Fatal(err)
+	}
+
+	node.checkReorg()
+	if node.getLastNumber() != 5 {
+		t.Fatal("unwound without reorg", node.getLastNumber())
+	}
+	if br, err := node.GetOutsByPKr([]c_type.PKr{pkr}, 1, 0); err != nil || len(br.BlockOuts) != 5 || br.BlockOuts[0].Data[0].Confirmations != 5 {
+		t.Fatal(br, err)
+	}
+
+	filters, err := node.GetFilters(2, 10)
+	if err != nil || len(filters) != 4 || filters[0].Hash != (common.Hash{2}) {
+		t.Fatal(filters, err)
+	}
+	if ok, _ := MatchFilter(filters[0].Filter, filters[0].Hash[:16], [][]byte{pkr[:]}); !ok {
+		t.Fatal("pkr not matched")
+	}
+	if ok, _ := MatchFilter(filters[1].Filter, filters[1].Hash[:16], [][]byte{{3}}); ok {
+		t.Fatal("other matched")
+	}
+	outs, err := node.GetBlockOuts(3)
+	if err != nil || len(outs.Outs) != 1 || len(outs.Nils) != 1 || outs.Nils[0] != (c_type.Uint256{3}) || outs.Outs[0].Confirmations != 3 {
+		t.Fatal(outs, err)
+	}
+
+	rawdb.WriteCanonicalHash(bcDB, common.Hash{9, 4}, 4)
+	rawdb.WriteCanonicalHash(bcDB, common.Hash{9, 5}, 5)
+	node.checkReorg()
+	if node.getLastNumber() != 3 {
+		t.Fatal("fork", node.getLastNumber())
+	}
+	br, err := node.GetOutsByPKr([]c_type.PKr{pkr}, 1, 10)
+	if err != nil || len(br.BlockOuts) != 3 || br.BlockOuts[2].Data[0].Confirmations != 1 {
+		t.Fatal(br, err)
+	}
+	if nils, _ := node.CheckNil([]c_type.Uint256{{3}, {4}}); len(nils) != 1 {
+		t.Fatal("nils", len(nils))
+	}
+	if node.indexedHash(4) != nil || node.indexedHash(3) == nil {
+		t.Fatal("hashes not unwound")
+	}
+	if _, err := node.GetBlockOuts(4); err == nil {
+		t.Fatal("unwound block served")
+	}
+	if filters, _ := node.GetFilters(1, 10); len(filters) != 3 {
+		t.Fatal("filters", len(filters))
+	}
+}
@@ -0,0 +1,123 @@
+// Package reorg keeps the block hashes and undo records the wallet indexes
+// roll back a reorganization of the chain with.
+package reorg
+
+import (
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/rlp"
+	"github.com/sero-cash/go-sero/serodb"
+)
+
+// MaxDepth bounds how far back the indexes keep block hashes and undo
+// records, and so how deep a reorg can be rolled back.
+const MaxDepth = 1000
+
+// Stale returns the block whose hash and undo records are dropped once num
+// is indexed, false while the index is shallower than MaxDepth.
+func Stale(num uint64) (uint64, bool) {
+	return num - MaxDepth, num > MaxDepth
+}
+
+// Record restores a key to what it held before a block wrote it.
+type Record struct {
+	Key     []byte
+	Value   []byte
+	Existed bool
+}
+
+// Journal is a batch remembering what the keys it writes held before, so
+// that the blocks it indexes can be rolled back. The writes through the
+// embedded Batch are not recorded.
+type Journal struct {
+	serodb.Batch
+	db      serodb.Getter
+	pending map[string][]byte
+	touched map[string]bool
+	records []Record
+}
+
+func NewJournal(db serodb.Getter, batch serodb.Batch) *Journal {
+	return &Journal{Batch: batch, db: db, pending: map[string][]byte{}, touched: map[string]bool{}}
+}
+
+// Get returns the value of the key with the writes of the journal applied.
+func (self *Journal) Get(key []byte) (value []byte, ok bool) {
+	if value, ok = self.pending[string(key)]; ok {
+		return value, value != nil
+	}
+	if value, e := self.db.Get(key); e == nil {
+		return value, true
+	}
+	return nil, false
+}
+
+// record keeps the value of the key before its first write in the block.
+func (self *Journal) record(key []byte) {
+	if self.touched[string(key)] {
+		return
+	}
+	self.touched[string(key)] = true
+	value, ok := self.Get(key)
+	self.records = append(self.records, Record{Key: common.CopyBytes(key), Value: common.CopyBytes(value), Existed: ok})
+}
+
+func (self *Journal) Put(key []byte, value []byte) error {
+	self.record(key)
+	self.pending[string(key)] = common.CopyBytes(value)
+	return self.Batch.Put(key, value)
+}
+
+func (self *Journal) Delete(key []byte) error {
+	self.record(key)
+	self.pending[string(key)] = nil
+	return self.Batch.Delete(key)
+}
+
+// Commit puts the records of the block under key, if it wrote anything,
+// and starts the next block.
+func (self *Journal) Commit(key []byte) error {
+	if len(self.records) > 0 {
+		data, err := rlp.EncodeToBytes(&self.records)
+		if err != nil {
+			return err
+		}
+		self.Batch.Put(key, data)
+	}
+	self.touched = map[string]bool{}
+	self.records = nil
+	return nil
+}
+
+// Undo writes into batch what restores the records, the last one first.
+func Undo(batch serodb.Batch, records []Record) {
+	for i := len(records) - 1; i >= 0; i-- {
+		if records[i].Existed {
+			batch.Put(records[i].Key, records[i].Value)
+		} else {
+			batch.Delete(records[i].Key)
+		}
+	}
+}
+
+// Decode reads the records a Journal committed.
+func Decode(data []byte) (records []Record, err error) {
+	err = rlp.DecodeBytes(data, &records)
+	return
+}
+
+// FindFork walks down from top while the hash indexed at a height is not
+// the canonical one. It stops at the first block both agree on, at a block
+// without an indexed hash, or MaxDepth blocks down.
+func FindFork(top uint64, indexed, canonical func(num uint64) *common.Hash) (fork uint64, reorged bool) {
+	for num := top; num > 0 && top-num < MaxDepth; num-- {
+		hash := indexed(num)
+		if hash == nil {
+			break
+		}
+		if current := canonical(num); current != nil && *current == *hash {
+			break
+		}
+		fork, reorged = num-1, true
+	}
+	return
+}
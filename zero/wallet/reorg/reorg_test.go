@@ -0,0 +1,81 @@
+package reorg
+
+import (
+	"testing"
+
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/serodb"
+)
+
+func TestJournalUndo(t *testing.T) {
+	db := serodb.NewMemDatabase()
+	db.Put([]byte("a"), []byte{1})
+
+	journal := NewJournal(db, db.NewBatch())
+	journal.Put([]byte("a"), []byte{2})
+	journal.Put([]byte("b"), []byte{2})
+	journal.Commit([]byte("undo1"))
+	journal.Put([]byte("a"), []byte{3})
+	journal.Delete([]byte("b"))
+	journal.Commit([]byte("undo2"))
+	if err := journal.Write(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, undo := range []string{"undo2", "undo1"} {
+		data, err := db.Get([]byte(undo))
+		if err != nil {
+			t.Fatal(err)
+		}
+		records, err := Decode(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		batch := db.NewBatch()
+		Undo(batch, records)
+		if err := batch.Write(); err != nil {
+			t.Fatal(err)
+		}
+		if undo == "undo2" {
+			if a, _ := db.Get([]byte("a")); a[0] != 2 {
+				t.Fatal("a not restored", a)
+			}
+			if ok, _ := db.Has([]byte("b")); !ok {
+				t.Fatal("b not restored")
+			}
+		}
+	}
+	if a, _ := db.Get([]byte("a")); a[0] != 1 {
+		t.Fatal("a not restored", a)
+	}
+	if ok, _ := db.Has([]byte("b")); ok {
+		t.Fatal("b not removed")
+	}
+}
+
+func TestFindFork(t *testing.T) {
+	hash := func(num uint64, fork byte) *common.Hash {
+		return &common.Hash{byte(num), fork}
+	}
+	indexed := func(num uint64) *common.Hash {
+		if num < 3 {
+			return nil
+		}
+		return hash(num, 0)
+	}
+	canonical := func(num uint64) *common.Hash {
+		if num > 6 {
+			return hash(num, 1)
+		}
+		return hash(num, 0)
+	}
+	if fork, reorged := FindFork(9, indexed, canonical); !reorged || fork != 6 {
+		t.Fatal(fork, reorged)
+	}
+	if _, reorged := FindFork(6, indexed, canonical); reorged {
+		t.Fatal("reorged on the canonical chain")
+	}
+	if fork, reorged := FindFork(9, indexed, func(uint64) *common.Hash { return nil }); !reorged || fork != 2 {
+		t.Fatal("stopped at", fork, reorged)
+	}
+}
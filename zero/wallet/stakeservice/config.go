@@ -0,0 +1,105 @@
+package stakeservice
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/btcsuite/btcutil/base58"
+	"github.com/sero-cash/go-czero-import/c_type"
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/common/address"
+)
+
+// RenewRule buys shares for one account from its stake income and when its
+// tickets run low.
+type RenewRule struct {
+	// Pk is the base58 pk of the account.
+	Pk string
+	// Pool is the hex id of the pool the shares join, empty buys solo shares.
+	Pool string
+	// Vote is the base58 PKr voting the shares, the main PKr by default.
+	Vote string
+	// MinIncome buys shares once the stake income received since the last
+	// purchase reaches it, nil disables it.
+	MinIncome *big.Int
+	// MinTickets tops the unselected tickets of the account up to it, zero
+	// disables it.
+	MinTickets uint32
+	// Keep SERO stay in the account, MaxAmount caps the value of a purchase.
+	Keep      *big.Int
+	MaxAmount *big.Int
+	// MaxPrice skips the purchases with a higher average share price, nil
+	// allows MaxPriceRise percent above the current price.
+	MaxPrice     *big.Int
+	MaxPriceRise uint64
+}
+
+// Config enables the share renewal of the accounts in Renew and sets where
+// the index starts.
+type Config struct {
+	Renew []RenewRule
+	// DryRun writes the purchases to the audit log without sending them.
+	DryRun bool
+	// IndexFrom is the first block the shares and pools are indexed from,
+	// zero starts at the first block with stake records.
+	IndexFrom uint64
+	// RecordProofs keeps the state proofs of the shares written by the
+	// indexed blocks, stake_proveShare serves them once the state is pruned.
+	// Only the blocks indexed while their state is retained are proved.
+	RecordProofs bool
+}
+
+const defaultMaxPriceRise = 5
+
+type renewRule struct {
+	RenewRule
+	pk   c_type.Uint512
+	pool *common.Hash
+	vote *c_type.PKr
+}
+
+func (self *Config) parse() (rules []renewRule, err error) {
+	seen := map[c_type.Uint512]bool{}
+	for _, each := range self.Renew {
+		rule := renewRule{RenewRule: each}
+		var pk address.PKAddress
+		if err = pk.UnmarshalText([]byte(each.Pk)); err != nil {
+			return nil, fmt.Errorf("renew pk %q: %v", each.Pk, err)
+		}
+		rule.pk = pk.ToUint512()
+		if seen[rule.pk] {
+			return nil, fmt.Errorf("renew pk %v given twice", each.Pk)
+		}
+		seen[rule.pk] = true
+		if each.Pool != "" {
+			if len(common.FromHex(each.Pool)) != common.HashLength {
+				return nil, fmt.Errorf("invalid renew pool %q", each.Pool)
+			}
+			pool := common.HexToHash(each.Pool)
+			rule.pool = &pool
+		}
+		if each.Vote != "" {
+			out := base58.Decode(each.Vote)
+			if err = address.ValidPkr(out); err != nil {
+				return nil, fmt.Errorf("renew vote of %v: %v", each.Pk, err)
+			}
+			var pkr c_type.PKr
+			copy(pkr[:], out)
+			rule.vote = &pkr
+		}
+		if (each.MinIncome == nil || each.MinIncome.Sign() <= 0) && each.MinTickets == 0 {
+			return nil, fmt.Errorf("renew of %v needs MinIncome or MinTickets", each.Pk)
+		}
+		if rule.MaxPriceRise == 0 {
+			rule.MaxPriceRise = defaultMaxPriceRise
+		}
+		rules = append(rules, rule)
+	}
+	return
+}
+
+// Validate checks the accounts, pools and vote addresses of the rules.
+func (self *Config) Validate() error {
+	_, err := self.parse()
+	return err
+}
@@ -0,0 +1,415 @@
+package stakeservice
+
+import (
+	"bytes"
+	"errors"
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	"github.com/btcsuite/btcutil/base58"
+	"github.com/sero-cash/go-czero-import/c_type"
+	"github.com/sero-cash/go-czero-import/seroparam"
+	"github.com/sero-cash/go-czero-import/superzk"
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/log"
+	"github.com/sero-cash/go-sero/serodb"
+	"github.com/sero-cash/go-sero/zero/stake"
+	"github.com/sero-cash/go-sero/zero/utils"
+	"github.com/sero-cash/go-sero/zero/wallet/reorg"
+)
+
+const (
+	// defaultIndexFrom is the first block with stake records on the mainnet.
+	defaultIndexFrom = 1300000
+	indexBatchSize   = 10000
+)
+
+var (
+	headKey    = []byte("INDEXHEAD")
+	hashPrefix = []byte("HASH")
+	undoPrefix = []byte("UNDO")
+)
+
+// "HASH" + num => hash of the indexed block
+func hashKey(num uint64) []byte {
+	return append(append([]byte{}, hashPrefix...), utils.EncodeNumber(num)...)
+}
+
+// "UNDO" + num => [reorg.Record] restoring the index before num
+func undoKey(num uint64) []byte {
+	return append(append([]byte{}, undoPrefix...), utils.EncodeNumber(num)...)
+}
+
+// undoBatch files the undo records of each block it indexes with the hash of
+// the block.
+type undoBatch struct {
+	*reorg.Journal
+}
+
+func newUndoBatch(db *serodb.LDBDatabase) *undoBatch {
+	return &undoBatch{reorg.NewJournal(db, db.NewBatch())}
+}
+
+// commit files the undo records of the block with its hash and forgets the
+// block leaving the reorg depth.
+func (self *undoBatch) commit(num uint64, hash common.Hash) error {
+	if err := self.Commit(undoKey(num)); err != nil {
+		return err
+	}
+	self.Batch.Put(hashKey(num), hash[:])
+	if stale, ok := reorg.Stale(num); ok {
+		self.Batch.Delete(undoKey(stale))
+		self.Batch.Delete(hashKey(stale))
+	}
+	return nil
+}
+
+type blockRecords struct {
+	hash   common.Hash
+	shares []*stake.Share
+	pools  []*stake.StakePool
+}
+
+// fetchRecords reads the stake records of the blocks from from to to, not
+// included, with a worker per cpu. It stops before the first missing block.
+func (self *StakeService) fetchRecords(from, to uint64) []blockRecords {
+	blocks := make([]blockRecords, to-from)
+	found := make([]bool, to-from)
+	nums := make(chan uint64)
+	var wg sync.WaitGroup
+	for i := 0; i < runtime.NumCPU(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for num := range nums {
+				header := self.bc.GetHeaderByNumber(num)
+				if header == nil {
+					continue
+				}
+				shares, pools := stake.GetBlockRecords(self.bc.GetDB(), header.Hash(), num)
+				blocks[num-from] = blockRecords{header.Hash(), shares, pools}
+				found[num-from] = true
+			}
+		}()
+	}
+	for num := from; num < to; num++ {
+		nums <- num
+	}
+	close(nums)
+	wg.Wait()
+	for i, ok := range found {
+		if !ok {
+			return blocks[:i]
+		}
+	}
+	return blocks
+}
+
+// indexFrom is the first block the shares and pools are indexed from.
+func (self *StakeService) indexFrom() uint64 {
+	if self.config.IndexFrom > 0 {
+		return self.config.IndexFrom
+	}
+	if seroparam.Is_Dev() {
+		return 0
+	}
+	return defaultIndexFrom
+}
+
+// loadHead reads the next block of the index. An index kept before the head
+// was stored goes on from its least advanced account.
+func (self *StakeService) loadHead() uint64 {
+	if value, err := self.db.Get(headKey); err == nil {
+		return utils.DecodeNumber(value)
+	}
+	head := uint64(0)
+	iterator := self.db.NewIteratorWithPrefix(numPrefix)
+	for iterator.Next() {
+		if len(iterator.Key()) != len(numPrefix)+64 {
+			continue
+		}
+		if num := utils.DecodeNumber(iterator.Value()); head == 0 || num < head {
+			head = num
+		}
+	}
+	iterator.Release()
+	if from := self.indexFrom(); head < from {
+		head = from
+	}
+	return head
+}
+
+// indexedNumber is the next block to index, false before the first block.
+func (self *StakeService) indexedNumber() (uint64, bool) {
+	head := atomic.LoadUint64(&self.head)
+	return head, head > 0
+}
+
+type indexedAccount struct {
+	*Account
+	from uint64
+}
+
+// indexShares indexes the shares, pools and events of the confirmed blocks
+// after the head, with the shares of the accounts not behind it.
+func (self *StakeService) indexShares() {
+	start := atomic.LoadUint64(&self.head)
+	current := self.bc.CurrentHeader().Number.Uint64()
+	if current < seroparam.DefaultConfirmedBlock() {
+		return
+	}
+	end := current - seroparam.DefaultConfirmedBlock() + 1
+	if end > start+indexBatchSize {
+		end = start + indexBatchSize
+	}
+	if end <= start {
+		return
+	}
+	blocks := self.fetchRecords(start, end)
+	if len(blocks) == 0 {
+		return
+	}
+
+	var accounts []indexedAccount
+	self.accounts.Range(func(key, value interface{}) bool {
+		if num, ok := self.numbers.Load(key); ok && num.(uint64) >= start {
+			accounts = append(accounts, indexedAccount{value.(*Account), num.(uint64)})
+		}
+		return true
+	})
+
+	sharesCount := 0
+	poolsCount := 0
+	eventBlocks := 0
+	batch := newUndoBatch(self.db)
+	indexed := map[common.Hash]*stake.StakePool{}
+	var changes []PoolChangeEvent
+	for i, block := range blocks {
+		num := start + uint64(i)
+		if self.indexEvents(batch, block.hash, num) {
+			eventBlocks++
+		}
+		self.recordProofs(batch, block.hash, num, block.shares)
+		for _, share := range block.shares {
+			batch.Put(sharekey(share.Id()), share.State())
+			batch.Put(pkrShareKey(share.PKr, share.Id()), share.State())
+			for _, account := range accounts {
+				if account.from <= num && superzk.IsMyPKr(account.tk, &share.PKr) {
+					batch.Batch.Put(pkShareKey(account.pk, share.Id()), share.State())
+				}
+			}
+		}
+
+		for _, pool := range block.pools {
+			id := common.BytesToHash(pool.Id())
+			prev, ok := indexed[id]
+			if !ok {
+				prev = self.indexedPool(pool.Id())
+			}
+			if change := self.indexPoolChange(batch, prev, pool, num); change != nil {
+				changes = append(changes, PoolChangeEvent{Type: PoolChangeApplied, PoolId: id, Change: change})
+			}
+			indexed[id] = pool
+			batch.Put(poolKey(pool.Id()), pool.State())
+		}
+		if err := batch.commit(num, block.hash); err != nil {
+			log.Error("StakeIndex encode undo", "block", num, "err", err)
+			return
+		}
+		sharesCount += len(block.shares)
+		poolsCount += len(block.pools)
+	}
+
+	next := start + uint64(len(blocks))
+	for _, account := range accounts {
+		if account.from < next {
+			batch.Batch.Put(numKey(*account.pk), utils.EncodeNumber(next))
+		}
+	}
+	batch.Batch.Put(headKey, utils.EncodeNumber(next))
+	if err := batch.Write(); err != nil {
+		log.Error("StakeIndex", "blockNumber", next, "err", err)
+		return
+	}
+	atomic.StoreUint64(&self.head, next)
+	for _, account := range accounts {
+		if account.from < next {
+			self.numbers.Store(*account.pk, next)
+		}
+	}
+	log.Info("StakeIndex", "blockNumber", next, "sharesCount", sharesCount, "poolsCount", poolsCount, "eventBlocks", eventBlocks)
+	for _, change := range changes {
+		self.feed.Send(change)
+	}
+}
+
+// catchUp indexes the shares of an account behind the head, the account
+// joins indexShares once it reaches it.
+func (self *StakeService) catchUp(account *Account) {
+	value, ok := self.numbers.Load(*account.pk)
+	if !ok {
+		return
+	}
+	start, head := value.(uint64), atomic.LoadUint64(&self.head)
+	if start >= head {
+		return
+	}
+	end := start + indexBatchSize
+	if end > head {
+		end = head
+	}
+	blocks := self.fetchRecords(start, end)
+	if len(blocks) == 0 {
+		return
+	}
+	sharesCount := 0
+	batch := self.db.NewBatch()
+	for _, block := range blocks {
+		for _, share := range block.shares {
+			if superzk.IsMyPKr(account.tk, &share.PKr) {
+				batch.Put(pkShareKey(account.pk, share.Id()), share.State())
+				sharesCount++
+			}
+		}
+	}
+	next := start + uint64(len(blocks))
+	batch.Put(numKey(*account.pk), utils.EncodeNumber(next))
+	if err := batch.Write(); err != nil {
+		log.Error("StakeIndex account", "blockNumber", next, "err", err)
+		return
+	}
+	self.numbers.Store(*account.pk, next)
+	log.Info("StakeIndex account", "pk", base58.Encode(account.pk[:]), "blockNumber", next, "sharesCount", sharesCount)
+}
+
+func (self *StakeService) indexedHash(num uint64) *common.Hash {
+	value, err := self.db.Get(hashKey(num))
+	if err != nil || len(value) != common.HashLength {
+		return nil
+	}
+	hash := common.BytesToHash(value)
+	return &hash
+}
+
+// checkReorg compares the hashes of the indexed blocks with the canonical
+// chain and rolls the index back to the last block both agree on.
+func (self *StakeService) checkReorg() {
+	head := atomic.LoadUint64(&self.head)
+	if head == 0 {
+		return
+	}
+	top := head - 1
+	fork, reorged := reorg.FindFork(top, self.indexedHash, func(num uint64) *common.Hash {
+		if header := self.bc.GetHeaderByNumber(num); header != nil {
+			hash := header.Hash()
+			return &hash
+		}
+		return nil
+	})
+	if reorged {
+		log.Warn("StakeIndex detected reorg", "top", top, "fork", fork)
+		if err := self.rollback(fork); err != nil {
+			log.Error("StakeIndex rollback", "fork", fork, "err", err)
+		}
+	}
+}
+
+// rollback restores the index as it was after the block fork from the undo
+// records of the blocks above it. The shares the accounts hold follow the
+// shares they were restored from.
+func (self *StakeService) rollback(fork uint64) error {
+	head := atomic.LoadUint64(&self.head)
+	batch := self.db.NewBatch()
+	restored := map[string]reorg.Record{}
+	for num := head; num > fork+1; num-- {
+		value, err := self.db.Get(undoKey(num - 1))
+		if err != nil {
+			continue
+		}
+		records, err := reorg.Decode(value)
+		if err != nil {
+			return err
+		}
+		reorg.Undo(batch, records)
+		for j := len(records) - 1; j >= 0; j-- {
+			if bytes.HasPrefix(records[j].Key, sharePrefix) {
+				restored[string(records[j].Key)] = records[j]
+			}
+		}
+		batch.Delete(undoKey(num - 1))
+		batch.Delete(hashKey(num - 1))
+	}
+
+	for key, record := range restored {
+		id := []byte(key)[len(sharePrefix):]
+		self.accounts.Range(func(_, value interface{}) bool {
+			key := pkShareKey(value.(*Account).pk, id)
+			if ok, _ := self.db.Has(key); !ok {
+				return true
+			}
+			if record.Existed {
+				batch.Put(key, record.Value)
+			} else {
+				batch.Delete(key)
+			}
+			return true
+		})
+	}
+
+	next := fork + 1
+	var moved []c_type.Uint512
+	self.numbers.Range(func(key, value interface{}) bool {
+		if value.(uint64) > next {
+			pk := key.(c_type.Uint512)
+			batch.Put(numKey(pk), utils.EncodeNumber(next))
+			moved = append(moved, pk)
+		}
+		return true
+	})
+	batch.Put(headKey, utils.EncodeNumber(next))
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	atomic.StoreUint64(&self.head, next)
+	for _, pk := range moved {
+		self.numbers.Store(pk, next)
+	}
+	log.Info("StakeIndex rolled back", "fork", fork, "shares", len(restored))
+	return nil
+}
+
+// Reindex drops the shares of the account created from the block from on and
+// indexes its blocks again from there, the index job catches it up.
+func (self *StakeService) Reindex(pk c_type.Uint512, from uint64) error {
+	self.indexLock.Lock()
+	defer self.indexLock.Unlock()
+	value, ok := self.numbers.Load(pk)
+	if !ok {
+		return errors.New("not found Pk")
+	}
+	if value.(uint64) < from {
+		return errors.New("from is above the indexed number")
+	}
+
+	batch := self.db.NewBatch()
+	dropped := 0
+	iterator := self.db.NewIteratorWithPrefix(pk[:])
+	for iterator.Next() {
+		if len(iterator.Key()) != len(pk)+common.HashLength {
+			continue
+		}
+		if share := self.getShareByHash(iterator.Value()); share == nil || share.BlockNumber >= from {
+			batch.Delete(common.CopyBytes(iterator.Key()))
+			dropped++
+		}
+	}
+	iterator.Release()
+	batch.Put(numKey(pk), utils.EncodeNumber(from))
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	self.numbers.Store(pk, from)
+	log.Info("StakeIndex reindex", "pk", base58.Encode(pk[:]), "from", from, "dropped", dropped)
+	return nil
+}
@@ -0,0 +1,67 @@
+package stakeservice
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/sero-cash/go-czero-import/c_type"
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/serodb"
+	"github.com/sero-cash/go-sero/zero/utils"
+)
+
+func TestRollback(t *testing.T) {
+	dir, err := ioutil.TempDir("", "stakeservice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	db, err := serodb.NewLDBDatabase(dir, 16, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	service := &StakeService{db: db}
+	pk := c_type.Uint512{1}
+	service.accounts.Store(pk, &Account{pk: &pk})
+	service.numbers.Store(pk, uint64(8))
+
+	id := common.Hash{2}.Bytes()
+	batch := newUndoBatch(db)
+	for num, state := range [][]byte{{5}, {6}, {7}} {
+		batch.Put(sharekey(id), state)
+		batch.Batch.Put(pkShareKey(&pk, id), state)
+		batch.commit(uint64(5+num), common.Hash{byte(5 + num)})
+	}
+	batch.Batch.Put(headKey, utils.EncodeNumber(8))
+	if err := batch.Write(); err != nil {
+		t.Fatal(err)
+	}
+	service.head = 8
+
+	check := func(want []byte) {
+		for _, key := range [][]byte{sharekey(id), pkShareKey(&pk, id)} {
+			value, err := db.Get(key)
+			if want == nil && err == nil || want != nil && !bytes.Equal(value, want) {
+				t.Fatalf("key %x is %x, want %x", key, value, want)
+			}
+		}
+	}
+	check([]byte{7})
+	if err := service.rollback(5); err != nil {
+		t.Fatal(err)
+	}
+	check([]byte{5})
+	if head, _ := service.indexedNumber(); head != 6 || service.indexedHash(6) != nil || *service.indexedHash(5) != (common.Hash{5}) {
+		t.Fatal("head not rolled back", head)
+	}
+	if num, _ := service.numbers.Load(pk); num.(uint64) != 6 {
+		t.Fatal("account not rolled back", num)
+	}
+	if err := service.rollback(4); err != nil {
+		t.Fatal(err)
+	}
+	check(nil)
+}
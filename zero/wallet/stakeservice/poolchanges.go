@@ -0,0 +1,318 @@
+package stakeservice
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+
+	"github.com/sero-cash/go-czero-import/c_type"
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/event"
+	"github.com/sero-cash/go-sero/log"
+	"github.com/sero-cash/go-sero/params"
+	"github.com/sero-cash/go-sero/rlp"
+	"github.com/sero-cash/go-sero/serodb"
+	"github.com/sero-cash/go-sero/zero/stake"
+	"github.com/sero-cash/go-sero/zero/txs/assets"
+	"github.com/sero-cash/go-sero/zero/txs/stx"
+	"github.com/sero-cash/go-sero/zero/txtool/prepare"
+	"github.com/sero-cash/go-sero/zero/utils"
+	"github.com/sero-cash/go-sero/zero/wallet/exchange"
+)
+
+// The prefixes must not start with poolPrefix, StakePools walks it.
+var (
+	poolHistoryPrefix  = []byte("HISTPOOL")
+	poolSchedulePrefix = []byte("SCHEDPOOL")
+)
+
+func poolHistoryKey(id common.Hash, num uint64) []byte {
+	return append(append(append([]byte{}, poolHistoryPrefix...), id[:]...), utils.EncodeNumber(num)...)
+}
+
+func poolScheduleKey(id common.Hash, at uint64) []byte {
+	return append(append(append([]byte{}, poolSchedulePrefix...), id[:]...), utils.EncodeNumber(at)...)
+}
+
+// PoolChange is a change of the fee rate or the vote PKr of a stake pool.
+type PoolChange struct {
+	Block   uint64
+	TxHash  *common.Hash `rlp:"nil"`
+	OldFee  uint16
+	NewFee  uint16
+	OldVote c_type.PKr
+	NewVote c_type.PKr
+}
+
+const (
+	SchedulePending   = "pending"
+	ScheduleSubmitted = "submitted"
+	ScheduleFailed    = "failed"
+	ScheduleCancelled = "cancelled"
+)
+
+// ScheduledChange changes the fee rate or the vote PKr of a pool once the
+// chain reaches At. It is kept by the node of the operator, which sends the
+// change from the account Pk.
+type ScheduledChange struct {
+	PoolId common.Hash
+	Pk     c_type.Uint512
+	PKr    c_type.PKr
+	At     uint64
+	Fee    *uint32     `rlp:"nil"`
+	Vote   *c_type.PKr `rlp:"nil"`
+	Status string
+	TxHash *common.Hash `rlp:"nil"`
+	Error  string
+}
+
+const (
+	PoolChangeScheduled = "scheduled"
+	PoolChangeCancelled = "cancelled"
+	PoolChangeSubmitted = "submitted"
+	PoolChangeFailed    = "failed"
+	PoolChangeApplied   = "applied"
+)
+
+// PoolChangeEvent tells the subscribers about a scheduled or an applied
+// change of a pool, Change is set when applied.
+type PoolChangeEvent struct {
+	Type      string
+	PoolId    common.Hash
+	Scheduled *ScheduledChange `json:",omitempty"`
+	Change    *PoolChange      `json:",omitempty"`
+}
+
+// SubscribePoolChanges delivers the pool change events to ch.
+func (self *StakeService) SubscribePoolChanges(ch chan<- PoolChangeEvent) event.Subscription {
+	return self.feed.Subscribe(ch)
+}
+
+// indexPoolChange records the change of the pool against its last indexed
+// version, prev is nil for a new pool.
+func (self *StakeService) indexPoolChange(batch serodb.Batch, prev, pool *stake.StakePool, num uint64) *PoolChange {
+	if prev == nil || (prev.Fee == pool.Fee && prev.VotePKr == pool.VotePKr) {
+		return nil
+	}
+	id := common.BytesToHash(pool.Id())
+	change := &PoolChange{
+		Block:   num,
+		TxHash:  self.poolChangeTx(id, num),
+		OldFee:  prev.Fee,
+		NewFee:  pool.Fee,
+		OldVote: prev.VotePKr,
+		NewVote: pool.VotePKr,
+	}
+	data, err := rlp.EncodeToBytes(change)
+	if err != nil {
+		log.Error("StakeIndex encode pool change", "block", num, "err", err)
+		return nil
+	}
+	batch.Put(poolHistoryKey(id, num), data)
+	return change
+}
+
+// poolChangeTx finds the last tx of the block registering the pool again.
+func (self *StakeService) poolChangeTx(id common.Hash, num uint64) (hash *common.Hash) {
+	block := self.bc.GetBlockByNumber(num)
+	if block == nil {
+		return
+	}
+	receipts := self.bc.GetReceiptsByHash(block.Hash())
+	for i, tx := range block.Transactions() {
+		if i >= len(receipts) || tx.GetZZSTX().Desc_Cmd.RegistPool == nil {
+			continue
+		}
+		if receipts[i].PoolId != nil && *receipts[i].PoolId == id {
+			txHash := tx.Hash()
+			hash = &txHash
+		}
+	}
+	return
+}
+
+// indexedPool returns the pool as the index last saw it.
+func (self *StakeService) indexedPool(id []byte) *stake.StakePool {
+	hash, err := self.db.Get(poolKey(id))
+	if err != nil {
+		return nil
+	}
+	ret := stake.StakePoolDB.GetObject(self.bc.GetDB(), hash, &stake.StakePool{})
+	if ret == nil {
+		return nil
+	}
+	return ret.(*stake.StakePool)
+}
+
+// PoolChangeHistory returns the indexed fee and vote changes of the pool,
+// the oldest first.
+func (self *StakeService) PoolChangeHistory(id common.Hash) (changes []PoolChange) {
+	iterator := self.db.NewIteratorWithPrefix(append(append([]byte{}, poolHistoryPrefix...), id[:]...))
+	defer iterator.Release()
+	for iterator.Next() {
+		var change PoolChange
+		if err := rlp.Decode(bytes.NewReader(iterator.Value()), &change); err != nil {
+			log.Error("Invalid pool change RLP", "id", id, "err", err)
+			continue
+		}
+		changes = append(changes, change)
+	}
+	return
+}
+
+// ScheduledChanges returns the changes scheduled for the pool.
+func (self *StakeService) ScheduledChanges(id common.Hash) (changes []ScheduledChange) {
+	iterator := self.db.NewIteratorWithPrefix(append(append([]byte{}, poolSchedulePrefix...), id[:]...))
+	defer iterator.Release()
+	for iterator.Next() {
+		var change ScheduledChange
+		if err := rlp.Decode(bytes.NewReader(iterator.Value()), &change); err == nil {
+			changes = append(changes, change)
+		}
+	}
+	return
+}
+
+func (self *StakeService) getScheduled(id common.Hash, at uint64) (change *ScheduledChange) {
+	value, err := self.db.Get(poolScheduleKey(id, at))
+	if err != nil {
+		return
+	}
+	change = &ScheduledChange{}
+	if rlp.DecodeBytes(value, change) != nil {
+		return nil
+	}
+	return
+}
+
+func (self *StakeService) putScheduled(change *ScheduledChange) error {
+	data, err := rlp.EncodeToBytes(change)
+	if err != nil {
+		return err
+	}
+	return self.db.Put(poolScheduleKey(change.PoolId, change.At), data)
+}
+
+// SchedulePoolChange adds the change, a pending change of the pool at the
+// same block takes the fields it sets.
+func (self *StakeService) SchedulePoolChange(change ScheduledChange) (*ScheduledChange, error) {
+	if change.Fee == nil && change.Vote == nil {
+		return nil, errors.New("nothing to change")
+	}
+	if change.At <= self.bc.CurrentHeader().Number.Uint64() {
+		return nil, errors.New("block of the change has passed")
+	}
+	self.scheduleMu.Lock()
+	defer self.scheduleMu.Unlock()
+	if old := self.getScheduled(change.PoolId, change.At); old != nil && old.Status == SchedulePending {
+		if change.Fee == nil {
+			change.Fee = old.Fee
+		}
+		if change.Vote == nil {
+			change.Vote = old.Vote
+		}
+	}
+	change.Status, change.TxHash, change.Error = SchedulePending, nil, ""
+	if err := self.putScheduled(&change); err != nil {
+		return nil, err
+	}
+	self.feed.Send(PoolChangeEvent{Type: PoolChangeScheduled, PoolId: change.PoolId, Scheduled: &change})
+	return &change, nil
+}
+
+// CancelPoolChange cancels the pending change of the pool at the block.
+func (self *StakeService) CancelPoolChange(id common.Hash, at uint64) error {
+	self.scheduleMu.Lock()
+	defer self.scheduleMu.Unlock()
+	change := self.getScheduled(id, at)
+	if change == nil || change.Status != SchedulePending {
+		return errors.New("no pending change at the block")
+	}
+	change.Status = ScheduleCancelled
+	if err := self.putScheduled(change); err != nil {
+		return err
+	}
+	self.feed.Send(PoolChangeEvent{Type: PoolChangeCancelled, PoolId: id, Scheduled: change})
+	return nil
+}
+
+// applySchedules sends the pending changes whose block has come.
+func (self *StakeService) applySchedules() {
+	head := self.bc.CurrentHeader().Number.Uint64()
+	var due []*ScheduledChange
+	iterator := self.db.NewIteratorWithPrefix(poolSchedulePrefix)
+	for iterator.Next() {
+		change := &ScheduledChange{}
+		if rlp.DecodeBytes(iterator.Value(), change) == nil && change.Status == SchedulePending && change.At <= head {
+			due = append(due, change)
+		}
+	}
+	iterator.Release()
+	if len(due) == 0 {
+		return
+	}
+
+	self.scheduleMu.Lock()
+	defer self.scheduleMu.Unlock()
+	for _, change := range due {
+		if current := self.getScheduled(change.PoolId, change.At); current == nil || current.Status != SchedulePending {
+			continue
+		}
+		typ := PoolChangeSubmitted
+		hash, err := self.sendPoolChange(change)
+		if err != nil {
+			typ = PoolChangeFailed
+			change.Status, change.Error = ScheduleFailed, err.Error()
+			log.Error("Scheduled pool change", "pool", change.PoolId, "at", change.At, "err", err)
+		} else {
+			change.Status, change.TxHash = ScheduleSubmitted, &hash
+			log.Info("Scheduled pool change", "pool", change.PoolId, "at", change.At, "tx", hash)
+		}
+		self.putScheduled(change)
+		self.feed.Send(PoolChangeEvent{Type: typ, PoolId: change.PoolId, Scheduled: change})
+	}
+}
+
+func (self *StakeService) sendPoolChange(change *ScheduledChange) (hash common.Hash, e error) {
+	ex := exchange.CurrentExchange()
+	if ex == nil {
+		e = errors.New("exchange mode no start")
+		return
+	}
+	statedb, err := self.bc.State()
+	if err != nil {
+		e = err
+		return
+	}
+	pool := stake.NewStakeState(statedb).GetStakePool(change.PoolId)
+	if pool == nil || pool.Closed {
+		e = errors.New("pool not exist or pool is closed")
+		return
+	}
+
+	cmd := stx.RegistPoolCmd{Vote: pool.VotePKr, FeeRate: uint32(pool.Fee)}
+	if change.Fee != nil {
+		cmd.FeeRate = *change.Fee
+	}
+	if change.Vote != nil {
+		cmd.Vote = *change.Vote
+	}
+	fee := new(big.Int).Mul(big.NewInt(stakeTxGas), big.NewInt(params.Gta))
+	refund := change.PKr
+	param := prepare.PreTxParam{}
+	param.From = change.Pk
+	param.RefundTo = &refund
+	param.Fee = assets.Token{Currency: utils.CurrencyToUint256("SERO"), Value: utils.U256(*fee)}
+	param.GasPrice = big.NewInt(params.Gta)
+	param.Cmds.RegistPool = &cmd
+
+	pretx, gtx, err := ex.GenTxWithSign(param)
+	if err != nil {
+		e = err
+		return
+	}
+	if e = ex.CommitTx(pretx, gtx); e != nil {
+		return
+	}
+	return common.BytesToHash(gtx.Hash[:]), nil
+}
@@ -0,0 +1,49 @@
+package stakeservice
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/sero-cash/go-czero-import/c_type"
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/serodb"
+	"github.com/sero-cash/go-sero/zero/stake"
+)
+
+func TestPoolChanges(t *testing.T) {
+	dir, err := ioutil.TempDir("", "stakeservice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	db, err := serodb.NewLDBDatabase(dir, 16, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	service := &StakeService{db: db}
+
+	pool := &stake.StakePool{PKr: c_type.PKr{1}, Fee: 3000}
+	batch := db.NewBatch()
+	if service.indexPoolChange(batch, nil, pool, 10) != nil || service.indexPoolChange(batch, pool, pool, 11) != nil {
+		t.Fatal("change without a change")
+	}
+	id := common.BytesToHash(pool.Id())
+	fee := uint32(4000)
+	for _, at := range []uint64{300, 200} {
+		if err := service.putScheduled(&ScheduledChange{PoolId: id, At: at, Fee: &fee, Status: SchedulePending}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	changes := service.ScheduledChanges(id)
+	if len(changes) != 2 || changes[0].At != 200 || *changes[1].Fee != 4000 || changes[1].Vote != nil {
+		t.Fatalf("scheduled changes %+v", changes)
+	}
+
+	iterator := db.NewIteratorWithPrefix(poolPrefix)
+	defer iterator.Release()
+	if iterator.Next() {
+		t.Fatal("schedule listed as a pool")
+	}
+}
@@ -0,0 +1,78 @@
+package stakeservice
+
+import (
+	"errors"
+
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/log"
+	"github.com/sero-cash/go-sero/rlp"
+	"github.com/sero-cash/go-sero/zero/stake"
+	"github.com/sero-cash/go-sero/zero/utils"
+)
+
+var proofPrefix = []byte("PROOF")
+
+// "PROOF" + id + num => stake.StakeProof of the share at the block num
+func proofKey(id []byte, num uint64) []byte {
+	key := append(append([]byte{}, proofPrefix...), id...)
+	return append(key, utils.EncodeNumber(num)...)
+}
+
+// recordProofs stores the proofs of the shares written by the block, the
+// state of the block has to be retained still.
+func (self *StakeService) recordProofs(batch *undoBatch, hash common.Hash, num uint64, shares []*stake.Share) {
+	if !self.config.RecordProofs || len(shares) == 0 {
+		return
+	}
+	header := self.bc.GetHeader(hash, num)
+	if header == nil {
+		return
+	}
+	state, err := self.bc.StateAt(header)
+	if err != nil {
+		log.Debug("StakeIndex no state to prove", "block", num, "err", err)
+		return
+	}
+	stakeState := stake.NewStakeState(state)
+	for _, share := range shares {
+		proof, err := stakeState.ProveShare(common.BytesToHash(share.Id()))
+		if err != nil {
+			log.Error("StakeIndex prove share", "block", num, "err", err)
+			continue
+		}
+		data, err := rlp.EncodeToBytes(proof)
+		if err != nil {
+			log.Error("StakeIndex encode proof", "block", num, "err", err)
+			continue
+		}
+		batch.Put(proofKey(share.Id(), num), data)
+	}
+}
+
+// RecordsProofs tells whether the indexed blocks record the share proofs.
+func (self *StakeService) RecordsProofs() bool {
+	return self.config.RecordProofs
+}
+
+// ShareProof returns the recorded proof of the share at the last block not
+// after num that wrote it, with the number of that block.
+func (self *StakeService) ShareProof(id common.Hash, num uint64) (proof *stake.StakeProof, at uint64, e error) {
+	iterator := self.db.NewIteratorWithPrefix(append(append([]byte{}, proofPrefix...), id[:]...))
+	defer iterator.Release()
+	var data []byte
+	for iterator.Next() {
+		key := iterator.Key()
+		block := utils.DecodeNumber(key[len(key)-8:])
+		if block > num {
+			break
+		}
+		at, data = block, common.CopyBytes(iterator.Value())
+	}
+	if data == nil {
+		e = errors.New("no proof recorded for the share")
+		return
+	}
+	proof = &stake.StakeProof{}
+	e = rlp.DecodeBytes(data, proof)
+	return
+}
@@ -0,0 +1,55 @@
+package stakeservice
+
+import (
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/sero-cash/go-czero-import/c_type"
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/rlp"
+	"github.com/sero-cash/go-sero/serodb"
+	"github.com/sero-cash/go-sero/zero/stake"
+)
+
+func TestShareProof(t *testing.T) {
+	dir, err := ioutil.TempDir("", "stakeservice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	db, err := serodb.NewLDBDatabase(dir, 16, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	service := &StakeService{db: db}
+
+	id := common.Hash{2}
+	batch := newUndoBatch(db)
+	for _, num := range []uint64{5, 9} {
+		data, err := rlp.EncodeToBytes(&stake.StakeProof{Share: &stake.Share{PKr: c_type.PKr{1}, Value: big.NewInt(1), Num: uint32(num)}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		batch.Put(proofKey(id[:], num), data)
+	}
+	batch.Put(proofKey(common.Hash{3}.Bytes(), 6), []byte{})
+	if err := batch.Write(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := service.ShareProof(id, 4); err == nil {
+		t.Fatal("proof before the share")
+	}
+	for num, want := range map[uint64]uint64{5: 5, 8: 5, 9: 9, 100: 9} {
+		proof, at, err := service.ShareProof(id, num)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if at != want || proof.Share.Num != uint32(want) {
+			t.Fatalf("proof at %v is from %v, want %v", num, at, want)
+		}
+	}
+}
@@ -0,0 +1,299 @@
+package stakeservice
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/sero-cash/go-czero-import/c_type"
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/log"
+	"github.com/sero-cash/go-sero/params"
+	"github.com/sero-cash/go-sero/rlp"
+	"github.com/sero-cash/go-sero/zero/stake"
+	"github.com/sero-cash/go-sero/zero/txs/assets"
+	"github.com/sero-cash/go-sero/zero/txs/stx"
+	"github.com/sero-cash/go-sero/zero/txtool"
+	"github.com/sero-cash/go-sero/zero/txtool/prepare"
+	"github.com/sero-cash/go-sero/zero/utils"
+	"github.com/sero-cash/go-sero/zero/wallet/exchange"
+)
+
+const (
+	// renewCooldown blocks pass after a purchase before the next, so the
+	// new shares are indexed before the tickets are counted again.
+	renewCooldown = 100
+)
+
+// stakeIncomeTx is the tx hash of the outs payIncome pays.
+var stakeIncomeTx = *common.BytesToHash([]byte{2}).HashToUint256()
+
+var (
+	renewStatePrefix = []byte("RENEWSTATE")
+	renewLogPrefix   = []byte("RENEWLOG")
+)
+
+func renewStateKey(pk c_type.Uint512) []byte {
+	return append(append([]byte{}, renewStatePrefix...), pk[:]...)
+}
+
+func renewLogKey(pk c_type.Uint512, at uint64) []byte {
+	return append(append(append([]byte{}, renewLogPrefix...), pk[:]...), utils.EncodeNumber(at)...)
+}
+
+type renewState struct {
+	Block   uint64
+	Pending *big.Int
+	Bought  uint64
+	Skipped string
+}
+
+// RenewRecord is an entry of the audit log of the share renewal.
+type RenewRecord struct {
+	Time     uint64
+	Block    uint64
+	Reason   string
+	Amount   *big.Int
+	Shares   uint32
+	AvgPrice *big.Int
+	Pool     *common.Hash    `rlp:"nil"`
+	TxHash   *c_type.Uint256 `rlp:"nil"`
+	DryRun   bool
+	Error    string
+}
+
+func (self *StakeService) getRenewState(pk c_type.Uint512) (state renewState) {
+	if value, err := self.db.Get(renewStateKey(pk)); err == nil {
+		rlp.DecodeBytes(value, &state)
+	}
+	if state.Pending == nil {
+		state.Pending = new(big.Int)
+	}
+	return
+}
+
+func (self *StakeService) putRenewState(pk c_type.Uint512, state renewState) {
+	if data, err := rlp.EncodeToBytes(&state); err == nil {
+		self.db.Put(renewStateKey(pk), data)
+	}
+}
+
+func (self *StakeService) audit(pk c_type.Uint512, record RenewRecord) {
+	data, err := rlp.EncodeToBytes(&record)
+	if err != nil {
+		return
+	}
+	self.db.Put(renewLogKey(pk, uint64(time.Now().UnixNano())), data)
+	log.Info("Stake renew", "reason", record.Reason, "amount", record.Amount, "shares", record.Shares, "dryRun", record.DryRun, "err", record.Error)
+}
+
+// RenewLog returns the last count entries of the audit log of the account,
+// the newest first.
+func (self *StakeService) RenewLog(pk c_type.Uint512, count int) (records []RenewRecord) {
+	iterator := self.db.NewIteratorWithPrefix(append(append([]byte{}, renewLogPrefix...), pk[:]...))
+	defer iterator.Release()
+	for ok := iterator.Last(); ok && len(records) < count; ok = iterator.Prev() {
+		var record RenewRecord
+		if err := rlp.DecodeBytes(iterator.Value(), &record); err == nil {
+			records = append(records, record)
+		}
+	}
+	return
+}
+
+// renewWallet is the part of the exchange the renewal uses.
+type renewWallet interface {
+	GetCurrencyNumber(pk c_type.Uint512) uint64
+	GetRecordsByPk(pk *c_type.Uint512, begin, end uint64) ([]exchange.Utxo, error)
+	GetMaxAvailable(pk c_type.Uint512, currency string) *big.Int
+	GenTxWithSign(param prepare.PreTxParam) (*txtool.GTxParam, *txtool.GTx, error)
+	CommitTx(txParam *txtool.GTxParam, tx *txtool.GTx) error
+}
+
+func stakeIncome(utxos []exchange.Utxo) *big.Int {
+	income := new(big.Int)
+	for _, utxo := range utxos {
+		if utxo.TxHash == stakeIncomeTx && utxo.Asset.Tkn != nil && utxo.Asset.Tkn.Currency == utils.CurrencyToUint256("SERO") {
+			income.Add(income, utxo.Asset.Tkn.Value.ToIntRef())
+		}
+	}
+	return income
+}
+
+// renewAmount decides what a rule buys with the pending income and the
+// unselected tickets of the account.
+func renewAmount(rule *renewRule, pending *big.Int, tickets uint32, price *big.Int) (reason string, amount *big.Int) {
+	amount = new(big.Int)
+	if rule.MinIncome != nil && rule.MinIncome.Sign() > 0 && pending.Cmp(rule.MinIncome) >= 0 {
+		reason, amount = "income", new(big.Int).Set(pending)
+	}
+	if rule.MinTickets > 0 && tickets < rule.MinTickets {
+		topup := new(big.Int).Mul(price, big.NewInt(int64(rule.MinTickets-tickets)))
+		if topup.Cmp(amount) > 0 {
+			reason, amount = "tickets", topup
+		}
+	}
+	if rule.MaxAmount != nil && amount.Cmp(rule.MaxAmount) > 0 {
+		amount = new(big.Int).Set(rule.MaxAmount)
+	}
+	return
+}
+
+// priceAllowed checks the average price of a purchase against the rule.
+func priceAllowed(rule *renewRule, avgPrice, price *big.Int) bool {
+	max := rule.MaxPrice
+	if max == nil {
+		max = new(big.Int).Div(new(big.Int).Mul(price, big.NewInt(int64(100+rule.MaxPriceRise))), big.NewInt(100))
+	}
+	return avgPrice.Cmp(max) <= 0
+}
+
+func (self *StakeService) renew() {
+	ex := exchange.CurrentExchange()
+	if ex == nil {
+		log.Error("Stake renew needs the exchange")
+		return
+	}
+	statedb, err := self.bc.State()
+	if err != nil {
+		log.Error("Stake renew", "err", err)
+		return
+	}
+	stakeState := stake.NewStakeState(statedb)
+	for i := range self.renewRules {
+		self.renewAccount(ex, stakeState, &self.renewRules[i])
+	}
+}
+
+// renewAccount buys the shares a rule asks for. A dry run only audits the
+// purchase: the income is still counted, but Bought and Pending are saved as
+// they were before it.
+func (self *StakeService) renewAccount(ex renewWallet, stakeState *stake.StakeState, rule *renewRule) {
+	state := self.getRenewState(rule.pk)
+	current := ex.GetCurrencyNumber(rule.pk)
+	if state.Block == 0 {
+		state.Block = current
+	}
+	if current > state.Block {
+		utxos, err := ex.GetRecordsByPk(&rule.pk, state.Block+1, current+1)
+		if err != nil {
+			log.Error("Stake renew records", "err", err)
+			return
+		}
+		state.Pending.Add(state.Pending, stakeIncome(utxos))
+		state.Block = current
+	}
+	defer func() {
+		self.putRenewState(rule.pk, state)
+	}()
+	if state.Bought > 0 && current < state.Bought+renewCooldown {
+		return
+	}
+
+	tickets := uint32(0)
+	for _, share := range self.SharesByPk(rule.pk) {
+		if share.Status == stake.STATUS_VALID {
+			tickets += share.Num
+		}
+	}
+	price := stakeState.CurrentPrice()
+	reason, amount := renewAmount(rule, state.Pending, tickets, price)
+	if reason == "" {
+		return
+	}
+	record := RenewRecord{
+		Time:   uint64(time.Now().Unix()),
+		Block:  current,
+		Reason: reason,
+		Pool:   rule.pool,
+		DryRun: self.config.DryRun,
+	}
+	skip := func(why string) {
+		if state.Skipped != why {
+			record.Amount = amount
+			record.Error = why
+			self.audit(rule.pk, record)
+		}
+		state.Skipped = why
+	}
+
+	fee := new(big.Int).Mul(big.NewInt(stakeTxGas), big.NewInt(params.Gta))
+	available := new(big.Int).Sub(ex.GetMaxAvailable(rule.pk, "SERO"), fee)
+	if rule.Keep != nil {
+		available.Sub(available, rule.Keep)
+	}
+	if available.Sign() < 0 {
+		available.SetInt64(0)
+	}
+	if amount.Cmp(available) > 0 {
+		amount = available
+	}
+	if amount.Cmp(price) < 0 {
+		skip("not enough funds")
+		return
+	}
+	if rule.pool != nil {
+		if pool := stakeState.GetStakePool(*rule.pool); pool == nil || pool.Closed {
+			skip("pool closed")
+			return
+		}
+	}
+	num, avgPrice, _ := stakeState.CaleAvgPrice(amount)
+	if !priceAllowed(rule, avgPrice, price) {
+		skip("price too high")
+		return
+	}
+	state.Skipped = ""
+	record.Amount, record.Shares, record.AvgPrice = amount, num, avgPrice
+
+	if self.config.DryRun {
+		self.audit(rule.pk, record)
+		return
+	}
+	hash, err := self.buyShares(ex, rule, amount, fee)
+	if err != nil {
+		record.Error = err.Error()
+		self.audit(rule.pk, record)
+		return
+	}
+	record.TxHash = &hash
+	self.audit(rule.pk, record)
+	state.Bought = current
+	if amount.Cmp(state.Pending) >= 0 {
+		state.Pending = new(big.Int)
+	} else {
+		state.Pending.Sub(state.Pending, amount)
+	}
+}
+
+func (self *StakeService) buyShares(ex renewWallet, rule *renewRule, amount, fee *big.Int) (hash c_type.Uint256, e error) {
+	account, err := self.accountManager.FindAccountByPk(rule.pk)
+	if err != nil {
+		e = err
+		return
+	}
+	vote := account.GetPkr(nil)
+	if rule.vote != nil {
+		vote = *rule.vote
+	}
+	refund := account.GetPkr(nil)
+
+	param := prepare.PreTxParam{}
+	param.From = rule.pk
+	param.RefundTo = &refund
+	param.Fee = assets.Token{Currency: utils.CurrencyToUint256("SERO"), Value: utils.U256(*fee)}
+	param.GasPrice = big.NewInt(params.Gta)
+	param.Cmds.BuyShare = &stx.BuyShareCmd{Value: utils.U256(*amount), Vote: vote}
+	if rule.pool != nil {
+		param.Cmds.BuyShare.Pool = rule.pool.HashToUint256()
+	}
+
+	pretx, gtx, err := ex.GenTxWithSign(param)
+	if err != nil {
+		e = err
+		return
+	}
+	if e = ex.CommitTx(pretx, gtx); e != nil {
+		return
+	}
+	return gtx.Hash, nil
+}
@@ -0,0 +1,143 @@
+package stakeservice
+
+import (
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/sero-cash/go-czero-import/c_type"
+	"github.com/sero-cash/go-sero/core/state"
+	"github.com/sero-cash/go-sero/serodb"
+	"github.com/sero-cash/go-sero/zero/stake"
+	"github.com/sero-cash/go-sero/zero/txs/assets"
+	"github.com/sero-cash/go-sero/zero/txtool"
+	"github.com/sero-cash/go-sero/zero/txtool/prepare"
+	"github.com/sero-cash/go-sero/zero/utils"
+	"github.com/sero-cash/go-sero/zero/wallet/exchange"
+)
+
+func TestRenewAmount(t *testing.T) {
+	price := big.NewInt(10)
+	rule := &renewRule{RenewRule: RenewRule{MinIncome: big.NewInt(50), MinTickets: 4, MaxPriceRise: defaultMaxPriceRise}}
+	for _, test := range []struct {
+		pending int64
+		tickets uint32
+		reason  string
+		amount  int64
+	}{
+		{40, 4, "", 0},
+		{60, 4, "income", 60},
+		{60, 0, "income", 60},
+		{20, 1, "tickets", 30},
+	} {
+		reason, amount := renewAmount(rule, big.NewInt(test.pending), test.tickets, price)
+		if reason != test.reason || amount.Int64() != test.amount {
+			t.Fatalf("pending %v tickets %v: got %q %v, want %q %v", test.pending, test.tickets, reason, amount, test.reason, test.amount)
+		}
+	}
+	rule.MaxAmount = big.NewInt(25)
+	if _, amount := renewAmount(rule, big.NewInt(60), 4, price); amount.Int64() != 25 {
+		t.Fatalf("amount %v above MaxAmount", amount)
+	}
+
+	if !priceAllowed(rule, big.NewInt(105), big.NewInt(100)) || priceAllowed(rule, big.NewInt(106), big.NewInt(100)) {
+		t.Fatal("price rise not capped at 5%")
+	}
+	rule.MaxPrice = big.NewInt(101)
+	if priceAllowed(rule, big.NewInt(102), big.NewInt(100)) {
+		t.Fatal("price above MaxPrice allowed")
+	}
+
+	for _, config := range []Config{
+		{Renew: []RenewRule{{Pk: "x", MinTickets: 1}}},
+	} {
+		if config.Validate() == nil {
+			t.Fatalf("invalid config %+v accepted", config)
+		}
+	}
+}
+
+// testWallet is at block 10 with the stake income of records.
+type testWallet struct {
+	records   []exchange.Utxo
+	available *big.Int
+	signed    int
+}
+
+func (self *testWallet) GetCurrencyNumber(pk c_type.Uint512) uint64 {
+	return 10
+}
+
+func (self *testWallet) GetRecordsByPk(pk *c_type.Uint512, begin, end uint64) ([]exchange.Utxo, error) {
+	return self.records, nil
+}
+
+func (self *testWallet) GetMaxAvailable(pk c_type.Uint512, currency string) *big.Int {
+	return new(big.Int).Set(self.available)
+}
+
+func (self *testWallet) GenTxWithSign(param prepare.PreTxParam) (*txtool.GTxParam, *txtool.GTx, error) {
+	self.signed++
+	return nil, nil, errors.New("not signed")
+}
+
+func (self *testWallet) CommitTx(txParam *txtool.GTxParam, tx *txtool.GTx) error {
+	return nil
+}
+
+func TestRenewAccount(t *testing.T) {
+	dir, err := ioutil.TempDir("", "stakeservice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	db, err := serodb.NewLDBDatabase(dir, 16, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	service := &StakeService{db: db, config: Config{DryRun: true}}
+	statedb, _ := state.New(state.NewDatabase(serodb.NewMemDatabase()), nil)
+	stakeState := stake.NewStakeState(statedb)
+
+	sero := func(n int64) *big.Int {
+		return new(big.Int).Mul(big.NewInt(n), big.NewInt(1000000000000000000))
+	}
+	income := exchange.Utxo{TxHash: stakeIncomeTx, Asset: assets.Asset{Tkn: &assets.Token{
+		Currency: utils.CurrencyToUint256("SERO"),
+		Value:    utils.U256(*sero(5)),
+	}}}
+	wallet := &testWallet{records: []exchange.Utxo{income}, available: sero(100)}
+	rule := &renewRule{RenewRule: RenewRule{MinIncome: sero(3), MaxPriceRise: defaultMaxPriceRise}, pk: c_type.Uint512{1}}
+
+	// the income is counted from the first block seen
+	service.putRenewState(rule.pk, renewState{Block: 5})
+	service.renewAccount(wallet, stakeState, rule)
+	records := service.RenewLog(rule.pk, 10)
+	if len(records) != 1 || !records[0].DryRun || records[0].Amount.Cmp(sero(5)) != 0 || records[0].Shares == 0 {
+		t.Fatalf("dry run audited %+v", records)
+	}
+	if wallet.signed != 0 {
+		t.Fatal("dry run signed a tx")
+	}
+	if state := service.getRenewState(rule.pk); state.Block != 10 || state.Bought != 0 || state.Pending.Cmp(sero(5)) != 0 {
+		t.Fatalf("dry run saved %+v", state)
+	}
+	service.renewAccount(wallet, stakeState, rule)
+	if records := service.RenewLog(rule.pk, 10); len(records) != 2 || records[0].Amount.Cmp(sero(5)) != 0 {
+		t.Fatalf("second dry run audited %+v", records)
+	}
+
+	service.config.DryRun = false
+	wallet.available = new(big.Int)
+	service.renewAccount(wallet, stakeState, rule)
+	state := service.getRenewState(rule.pk)
+	if state.Block != 10 || state.Bought != 0 || state.Pending.Cmp(sero(5)) != 0 || state.Skipped != "not enough funds" {
+		t.Fatalf("renewal state %+v", state)
+	}
+	if records := service.RenewLog(rule.pk, 10); len(records) != 3 || records[0].Error != "not enough funds" {
+		t.Fatalf("skip audited %+v", records)
+	}
+}
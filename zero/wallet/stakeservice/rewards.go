@@ -0,0 +1,170 @@
+package stakeservice
+
+import (
+	"bytes"
+	"math/big"
+
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/log"
+	"github.com/sero-cash/go-sero/rlp"
+	"github.com/sero-cash/go-sero/serodb"
+	"github.com/sero-cash/go-sero/zero/stake"
+	"github.com/sero-cash/go-sero/zero/utils"
+)
+
+// DefaultStatsWindows are about a day, a week and a month of blocks.
+var DefaultStatsWindows = []uint64{5760, 40320, 172800}
+
+const secondsPerYear = 365 * 24 * 3600
+
+var rewardPrefix = []byte("REWARD")
+
+func rewardKey(id common.Hash, num uint64, idx uint32) []byte {
+	key := append(append([]byte{}, rewardPrefix...), id[:]...)
+	return append(append(key, utils.EncodeNumber(num)...), utils.EncodeNumber32(idx)...)
+}
+
+// BlockEvent is a stake reward event with the block that made it.
+type BlockEvent struct {
+	Block uint64
+	stake.RewardEvent
+}
+
+// indexEvents files the recorded events of the block under their share and
+// pool, false when the node did not record the block. The remedies paid to
+// the miners belong to no share and are left out.
+func (self *StakeService) indexEvents(batch serodb.Batch, hash common.Hash, num uint64) bool {
+	events, ok := stake.GetBlockEvents(self.bc.GetDB(), hash)
+	if !ok {
+		return false
+	}
+	for i, event := range events {
+		if event.Kind == stake.EVENT_REMEDY {
+			continue
+		}
+		data, err := rlp.EncodeToBytes(&event)
+		if err != nil {
+			log.Error("StakeIndex encode event", "block", num, "err", err)
+			continue
+		}
+		batch.Put(rewardKey(event.Id, num, uint32(i)), data)
+		if event.PoolId != nil {
+			batch.Put(rewardKey(*event.PoolId, num, uint32(i)), data)
+		}
+	}
+	return true
+}
+
+// RewardHistory returns the indexed events of a share or a pool between the
+// blocks from and to, both included.
+func (self *StakeService) RewardHistory(id common.Hash, from, to uint64) (events []BlockEvent) {
+	prefix := append(append([]byte{}, rewardPrefix...), id[:]...)
+	iterator := self.db.NewIteratorWithPrefix(prefix)
+	defer iterator.Release()
+	for ok := iterator.Seek(rewardKey(id, from, 0)); ok; ok = iterator.Next() {
+		key := iterator.Key()
+		num := utils.DecodeNumber(key[len(prefix) : len(prefix)+8])
+		if num > to {
+			break
+		}
+		event := BlockEvent{Block: num}
+		if err := rlp.Decode(bytes.NewReader(iterator.Value()), &event.RewardEvent); err != nil {
+			log.Error("Invalid stake event RLP", "id", id, "block", num, "err", err)
+			continue
+		}
+		events = append(events, event)
+	}
+	return
+}
+
+// PoolStats sums the events of a pool over a window of blocks.
+type PoolStats struct {
+	Window    uint64
+	From      uint64
+	To        uint64
+	PoolVotes uint64
+	SoloVotes uint64
+	Missed    uint64
+	Expired   uint64
+	Rewards   *big.Int
+	Fees      *big.Int
+	// MissRate is the part of the selected tickets the pool did not vote.
+	MissRate float64
+	// APR annualizes the share rewards of the window over the value the
+	// pool holds now.
+	APR float64
+}
+
+func summarize(events []BlockEvent) (stats PoolStats) {
+	stats.Rewards = new(big.Int)
+	stats.Fees = new(big.Int)
+	for _, event := range events {
+		switch event.Kind {
+		case stake.EVENT_POOL:
+			stats.PoolVotes += uint64(event.Count)
+			stats.Rewards.Add(stats.Rewards, event.Amount)
+		case stake.EVENT_SOLO:
+			stats.SoloVotes += uint64(event.Count)
+			stats.Rewards.Add(stats.Rewards, event.Amount)
+		case stake.EVENT_POOLFEE:
+			stats.Fees.Add(stats.Fees, event.Amount)
+		case stake.EVENT_MISSED:
+			stats.Missed += uint64(event.Count)
+		case stake.EVENT_EXPIRED:
+			stats.Expired += uint64(event.Count)
+		}
+	}
+	if selected := stats.PoolVotes + stats.SoloVotes + stats.Missed; selected > 0 {
+		stats.MissRate = float64(stats.SoloVotes+stats.Missed) / float64(selected)
+	}
+	return
+}
+
+func annualize(rewards, value *big.Int, seconds uint64) float64 {
+	if value.Sign() == 0 || seconds == 0 {
+		return 0
+	}
+	rate, _ := new(big.Float).Quo(new(big.Float).SetInt(rewards), new(big.Float).SetInt(value)).Float64()
+	return rate * secondsPerYear / float64(seconds)
+}
+
+// poolValue is what the valid shares of the pool paid for the tickets not
+// voted yet.
+func (self *StakeService) poolValue(poolId common.Hash) *big.Int {
+	value := new(big.Int)
+	for _, share := range self.Shares() {
+		if share.PoolId == nil || *share.PoolId != poolId || share.Status != stake.STATUS_VALID {
+			continue
+		}
+		value.Add(value, new(big.Int).Mul(share.Value, big.NewInt(int64(share.Num+share.WillVoteNum))))
+	}
+	return value
+}
+
+// PoolStats summarizes the pool over each window, the windows end at the
+// last indexed block.
+func (self *StakeService) PoolStats(poolId common.Hash, windows []uint64) (stats []PoolStats) {
+	if len(windows) == 0 {
+		windows = DefaultStatsWindows
+	}
+	end, ok := self.indexedNumber()
+	if !ok || end == 0 {
+		return
+	}
+	end--
+	value := self.poolValue(poolId)
+	for _, window := range windows {
+		from := uint64(0)
+		if window < end {
+			from = end - window
+		}
+		each := summarize(self.RewardHistory(poolId, from+1, end))
+		each.Window, each.From, each.To = window, from+1, end
+		fromHeader, toHeader := self.bc.GetHeaderByNumber(from), self.bc.GetHeaderByNumber(end)
+		if fromHeader != nil && toHeader != nil && toHeader.Time.Cmp(fromHeader.Time) > 0 {
+			each.APR = annualize(each.Rewards, value, new(big.Int).Sub(toHeader.Time, fromHeader.Time).Uint64())
+		}
+		stats = append(stats, each)
+	}
+	return
+}
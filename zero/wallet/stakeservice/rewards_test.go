@@ -0,0 +1,63 @@
+package stakeservice
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/sero-cash/go-sero/common"
+	"github.com/sero-cash/go-sero/serodb"
+	"github.com/sero-cash/go-sero/zero/stake"
+)
+
+func TestSummarizePoolEvents(t *testing.T) {
+	pool := &common.Hash{9}
+	event := func(kind stake.EventKind, amount int64, count uint32) BlockEvent {
+		return BlockEvent{1, stake.RewardEvent{Kind: kind, Id: common.Hash{1}, PoolId: pool, Amount: big.NewInt(amount), Count: count}}
+	}
+	events := []BlockEvent{
+		event(stake.EVENT_POOL, 90, 1),
+		event(stake.EVENT_POOLFEE, 10, 1),
+		event(stake.EVENT_POOL, 90, 1),
+		event(stake.EVENT_POOLFEE, 10, 1),
+		event(stake.EVENT_POOL, 90, 1),
+		event(stake.EVENT_POOLFEE, 10, 1),
+		event(stake.EVENT_SOLO, 60, 1),
+		event(stake.EVENT_MISSED, 500, 2),
+		event(stake.EVENT_EXPIRED, 250, 1),
+	}
+
+	db := serodb.NewMemDatabase()
+	raw := []stake.RewardEvent{}
+	for _, each := range events {
+		raw = append(raw, each.RewardEvent)
+	}
+	coinbase := common.Address{7}
+	raw = append(raw, stake.RewardEvent{Kind: stake.EVENT_REMEDY, Amount: big.NewInt(30), Count: 1, Coinbase: &coinbase})
+	if err := stake.WriteBlockEvents(db, common.Hash{1}, raw); err != nil {
+		t.Fatal(err)
+	}
+	decoded, ok := stake.GetBlockEvents(db, common.Hash{1})
+	if !ok || len(decoded) != len(raw) || *decoded[0].PoolId != *pool || decoded[7].Count != 2 || *decoded[9].Coinbase != coinbase || decoded[9].PoolId != nil {
+		t.Fatalf("events not round tripped: %+v", decoded)
+	}
+	if _, ok := stake.GetBlockEvents(db, common.Hash{2}); ok {
+		t.Fatal("got events of an unrecorded block")
+	}
+
+	stats := summarize(events)
+	if stats.PoolVotes != 3 || stats.SoloVotes != 1 || stats.Missed != 2 || stats.Expired != 1 {
+		t.Fatalf("unexpected counts %+v", stats)
+	}
+	if stats.Rewards.Int64() != 330 || stats.Fees.Int64() != 30 {
+		t.Fatalf("unexpected amounts %v %v", stats.Rewards, stats.Fees)
+	}
+	if stats.MissRate != 0.5 {
+		t.Fatalf("miss rate %v, want 0.5", stats.MissRate)
+	}
+	if apr := annualize(big.NewInt(1), big.NewInt(100), secondsPerYear/2); apr != 0.02 {
+		t.Fatalf("apr %v, want 0.02", apr)
+	}
+	if apr := annualize(big.NewInt(1), new(big.Int), 10); apr != 0 {
+		t.Fatalf("apr %v without value", apr)
+	}
+}
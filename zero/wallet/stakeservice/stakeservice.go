@@ -6,12 +6,9 @@ import (
 
 	"github.com/robfig/cron"
 	"github.com/sero-cash/go-sero/common"
-	"github.com/sero-cash/go-sero/common/math"
 	"github.com/sero-cash/go-sero/zero/utils"
 
 	"github.com/sero-cash/go-czero-import/c_type"
-	"github.com/sero-cash/go-czero-import/seroparam"
-	"github.com/sero-cash/go-czero-import/superzk"
 	"github.com/sero-cash/go-sero/accounts"
 	"github.com/sero-cash/go-sero/core"
 	"github.com/sero-cash/go-sero/event"
@@ -33,6 +30,13 @@ type StakeService struct {
 
 	accounts sync.Map
 	numbers  sync.Map
+	// head is the next block of the shares and pools index.
+	head      uint64
+	indexLock sync.Mutex
+
+	config     Config
+	renewRules []renewRule
+	scheduleMu sync.Mutex
 
 	feed    event.Feed
 	updater event.Subscription        // Wallet update subscriptions for all backends
@@ -41,13 +45,16 @@ type StakeService struct {
 	lock    sync.RWMutex
 }
 
+// stakeTxGas is the gas of the share and pool txs the service sends.
+const stakeTxGas = 25000
+
 var current_StakeService *StakeService
 
 func CurrentStakeService() *StakeService {
 	return current_StakeService
 }
 
-func NewStakeService(dbpath string, bc *core.BlockChain, accountManager *accounts.Manager) *StakeService {
+func NewStakeService(dbpath string, bc *core.BlockChain, accountManager *accounts.Manager, config Config) *StakeService {
 	update := make(chan accounts.WalletEvent, 1)
 	updater := accountManager.Subscribe(update)
 
@@ -56,6 +63,7 @@ func NewStakeService(dbpath string, bc *core.BlockChain, accountManager *account
 		accountManager: accountManager,
 		update:         update,
 		updater:        updater,
+		config:         config,
 	}
 	current_StakeService = stakeService
 
@@ -67,11 +75,19 @@ func NewStakeService(dbpath string, bc *core.BlockChain, accountManager *account
 
 	stakeService.numbers = sync.Map{}
 	stakeService.accounts = sync.Map{}
+	stakeService.head = stakeService.loadHead()
 	for _, w := range accountManager.Wallets() {
 		stakeService.initWallet(w)
 	}
 
 	AddJob("0/10 * * * * ?", stakeService.stakeIndex)
+	AddJob("5/10 * * * * ?", stakeService.applySchedules)
+	if rules, err := config.parse(); err != nil {
+		log.Error("Stake renew disabled", "err", err)
+	} else if len(rules) > 0 {
+		stakeService.renewRules = rules
+		AddJob("0 0/10 * * * ?", stakeService.renew)
+	}
 	go stakeService.updateAccount()
 	return stakeService
 }
@@ -139,80 +155,14 @@ func (self *StakeService) GetBlockRecords(blockNumber uint64) (shares []*stake.S
 }
 
 func (self *StakeService) stakeIndex() {
-	start := uint64(math.MaxUint64)
-	self.numbers.Range(func(key, value interface{}) bool {
-		num := value.(uint64)
-		if start > num {
-			start = num
-		}
-		return true
-	})
-	if start == uint64(math.MaxUint64) {
-		return
-	}
-	if !seroparam.Is_Dev() && start < 1300000 {
-		start = 1300000
-	}
-
-	header := self.bc.CurrentHeader()
-	sharesCount := 0
-	poolsCount := 0
-	batch := self.db.NewBatch()
-	blocNumber := start
-	for blocNumber+seroparam.DefaultConfirmedBlock() <= header.Number.Uint64() {
-		shares, pools := self.GetBlockRecords(blocNumber)
-		for _, share := range shares {
-			batch.Put(sharekey(share.Id()), share.State())
-			batch.Put(pkrShareKey(share.PKr, share.Id()), share.State())
-			if accountKey, ok := self.ownPkr(share.PKr); ok {
-				batch.Put(pkShareKey(accountKey, share.Id()), share.State())
-			}
-		}
-
-		for _, pool := range pools {
-			batch.Put(poolKey(pool.Id()), pool.State())
-		}
-		sharesCount += len(shares)
-		poolsCount += len(pools)
-		blocNumber++
-		if blocNumber-start >= 10000 {
-			break
-		}
-	}
-	if blocNumber == start {
-		return
-	}
-
-	self.numbers.Range(func(key, value interface{}) bool {
-		pk := key.(c_type.Uint512)
-		batch.Put(numKey(pk), utils.EncodeNumber(blocNumber))
-		return true
-	})
-	err := batch.Write()
-	if err == nil {
-		self.numbers.Range(func(key, value interface{}) bool {
-			pk := key.(c_type.Uint512)
-			self.numbers.Store(pk, blocNumber)
-			return true
-		})
-		log.Info("StakeIndex", "blockNumber", blocNumber, "sharesCount", sharesCount, "poolsCount", poolsCount)
-	}
-}
-
-func (self *StakeService) ownPkr(pkr c_type.PKr) (pk *c_type.Uint512, ok bool) {
-	var account *Account
+	self.indexLock.Lock()
+	defer self.indexLock.Unlock()
+	self.checkReorg()
+	self.indexShares()
 	self.accounts.Range(func(key, value interface{}) bool {
-		a := value.(*Account)
-		if superzk.IsMyPKr(a.tk, &pkr) {
-			account = a
-			return false
-		}
+		self.catchUp(value.(*Account))
 		return true
 	})
-	if account != nil {
-		return account.pk.NewRef(), true
-	}
-	return
 }
 
 func (self *StakeService) updateAccount() {
@@ -255,21 +205,24 @@ func (self *StakeService) initWallet(w accounts.Wallet) {
 		account.version = w.Accounts()[0].Version
 		self.accounts.Store(*account.pk, &account)
 
-		var num uint64
-		if num = self.starNum(account.pk); num < w.Accounts()[0].At {
+		num, stored := self.starNum(account.pk)
+		if num < w.Accounts()[0].At {
 			num = w.Accounts()[0].At
 		}
+		if from := self.indexFrom(); !stored && num < from {
+			num = from
+		}
 		self.numbers.Store(*account.pk, num)
 		log.Info("Add PK", "pk", w.Accounts()[0].Address, "At", num)
 	}
 }
 
-func (self *StakeService) starNum(pk *c_type.Uint512) uint64 {
+func (self *StakeService) starNum(pk *c_type.Uint512) (uint64, bool) {
 	value, err := self.db.Get(numKey(*pk))
 	if err != nil {
-		return 0
+		return 0, false
 	}
-	return utils.DecodeNumber(value)
+	return utils.DecodeNumber(value), true
 }
 
 var (
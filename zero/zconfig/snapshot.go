@@ -52,3 +52,13 @@ func Init_RecordShareNum() {
 func RecordShareNum() bool {
 	return recordBlockShareNumber
 }
+
+var recordStakeEvents bool
+
+func Init_RecordStakeEvents() {
+	recordStakeEvents = true
+}
+
+func RecordStakeEvents() bool {
+	return recordStakeEvents
+}